@@ -5,85 +5,165 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
-	"syscall"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+)
 
-	"github.com/fsnotify/fsnotify"
+const (
+	defaultMaxAttempts = 10
+	defaultDebounce    = 250 * time.Millisecond
 )
 
-const defaultMaxAttempts = 10
+// UnlimitedAttempts may be supplied to WithMaxAttempts to have the
+// AutoReloader attempt to reload the application until it succeeds.
+const UnlimitedAttempts = -1
 
 type onReloadFunc func()
 
-// Logger defines an interface for logging info and fatal errors out of
-// the autoreloader process.
-type Logger interface {
-	// Info is intended to log an informational message
-	Info(string)
+// AutoReloader provides functionality for reloading an application.
+type AutoReloader struct {
+	cmd          string
+	cmds         []string
+	label        string
+	logger       Logger
+	verbosity    Verbosity
+	maxAttempts  int
+	debounce     time.Duration
+	onReload     onReloadFunc
+	pool         *WatcherPool
+	pollInterval time.Duration
+	historySize  int
+	historyFile  string
+	sdNotify     bool
+	exitCode     *int
 
-	// Error is intended to log an error message
-	Error(string, error)
-}
+	requireNewerMtime bool
+	changeDetector    ChangeDetector
+	execStrategy      ExecStrategy
+	blueGreen         *BlueGreenConfig
 
-type defaultLogger struct{}
+	replacementTimeout  time.Duration
+	procSelfExeFallback bool
+	exit                func(int)
+	execve              func(string, []string, []string) error
+	openWatcher         func() (watcher, error)
+	disableChecks       []DisableCheck
+	inheritFDs          []int
 
-func (l *defaultLogger) Info(msg string) {
-	log.Println(msg)
+	state *state
 }
 
-func (l *defaultLogger) Error(msg string, err error) {
-	log.Printf("%s: %v\n", msg, err)
-}
+// state holds the mutable lifecycle state of an AutoReloader. It is
+// shared by all copies of the AutoReloader returned by New.
+type state struct {
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	done      chan struct{}
+	requests  chan struct{}
+	watcher   watcher
+	watchPath string
+	paths     map[string]Action
+	hooks     []*hook
+	history   []ReloadInfo
+	handoff   map[string]string
+	plugins   map[string]func(string)
+	contents  map[string][]byte
+	reloading bool
+	snapshots map[string]fileSnapshot
+	logger    atomic.Value
 
-type noopLogger struct{}
-
-func (l *noopLogger) Info(msg string)             {} // nolint: unparam
-func (l *noopLogger) Error(msg string, err error) {} // nolint: unparam
+	// commandPaths are the executables watched in addition to
+	// watchPath.
+	commandPaths []string
+}
 
-// AutoReloader provides functionality for reloading an application.
-type AutoReloader struct {
-	cmd         string
-	logger      Logger
-	maxAttempts int
-	onReload    onReloadFunc
+// Option configures an AutoReloader.
+type Option func(*AutoReloader)
 
-	ctx    context.Context
-	cancel context.CancelFunc
+// New creates a new AutoReloader with the supplied options. Options
+// that cannot be combined are not reported; use NewE to validate the
+// configuration.
+func New(opts ...Option) AutoReloader {
+	autoReloader := newAutoReloader(opts)
+	autoReloader.setup()
+	return *autoReloader
 }
 
-type option func(*AutoReloader)
-
-// New creates a new AutoReloader with the supplied options.
-func New(opts ...option) AutoReloader {
-	ctx, cancel := context.WithCancel(context.TODO())
+// newAutoReloader creates an AutoReloader with the defaults and applies
+// the supplied options.
+func newAutoReloader(opts []Option) *AutoReloader {
 	autoReloader := &AutoReloader{
-		logger:      &defaultLogger{},
-		maxAttempts: defaultMaxAttempts,
-		onReload:    func() {},
-		ctx:         ctx,
-		cancel:      cancel,
+		logger:       &defaultLogger{},
+		maxAttempts:  defaultMaxAttempts,
+		debounce:     defaultDebounce,
+		historySize:  defaultHistorySize,
+		onReload:     func() {},
+		exit:         os.Exit,
+		execve:       execve,
+		execStrategy: DirectExec(),
+
+		replacementTimeout: defaultReplacementTimeout,
+		state: &state{
+			paths:    map[string]Action{},
+			plugins:  map[string]func(string){},
+			contents: map[string][]byte{},
+		},
 	}
 	for _, opt := range opts {
 		opt(autoReloader)
 	}
-	return *autoReloader
+	return autoReloader
+}
+
+// setup prepares the state of a configured AutoReloader.
+func (ar AutoReloader) setup() {
+	ar.state.logger.Store(loggerValue{ar.logger})
+	ar.loadHistory()
+	ar.rememberExecuted()
 }
 
 // WithCommand defines the command executable that AutoReloader should
 // watch. By default, this will be the currently running command.
-func WithCommand(cmd string) option {
+func WithCommand(cmd string) Option {
 	return func(autoReloader *AutoReloader) {
 		autoReloader.cmd = cmd
 	}
 }
 
+// WithCommands defines several command executables that AutoReloader
+// should watch. The first is treated as the command supplied to
+// WithCommand. A change to any of them reloads the application, and
+// changes to several of them within the debounce window cause a single
+// reload. Start exits if any of the commands cannot be found.
+func WithCommands(cmds ...string) Option {
+	return func(autoReloader *AutoReloader) {
+		if len(cmds) == 0 {
+			return
+		}
+		autoReloader.cmd = cmds[0]
+		autoReloader.cmds = append([]string(nil), cmds[1:]...)
+	}
+}
+
+// WithName defines a name that identifies the AutoReloader in log
+// messages, reload history and status. This is useful when running
+// several AutoReloaders in one process. By default, this is the base name
+// of the watched command.
+func WithName(name string) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.label = name
+	}
+}
+
 // WithLogger defines the logger that the AutoReloader will use. By
 // default, it will log using the built-in log package. When a nil value
 // is supplied for the logger, logging will be disabled.
-func WithLogger(logger Logger) option {
+func WithLogger(logger Logger) Option {
 	if logger == nil {
 		logger = &noopLogger{}
 	}
@@ -94,9 +174,11 @@ func WithLogger(logger Logger) option {
 
 // WithMaxAttempts defines how many times the AutoReloader should
 // attempt to reload the application. By default, this is 10. If the
-// supplied maxAttempts is less than 1, it will be treated as 1.
-func WithMaxAttempts(maxAttempts int) option {
-	if maxAttempts < 1 {
+// supplied maxAttempts is UnlimitedAttempts, the AutoReloader will keep
+// attempting until it succeeds. Otherwise, if the supplied maxAttempts
+// is less than 1, it will be treated as 1.
+func WithMaxAttempts(maxAttempts int) Option {
+	if maxAttempts < 1 && maxAttempts != UnlimitedAttempts {
 		maxAttempts = 1
 	}
 	return func(autoReloader *AutoReloader) {
@@ -104,10 +186,25 @@ func WithMaxAttempts(maxAttempts int) option {
 	}
 }
 
+// WithDebounce defines how long the AutoReloader waits for the
+// executable to stop changing before reloading the application. It is
+// also the interval between attempts. By default, this is 250
+// milliseconds.
+func WithDebounce(debounce time.Duration) Option {
+	if debounce < 0 {
+		debounce = 0
+	}
+	return func(autoReloader *AutoReloader) {
+		autoReloader.debounce = debounce
+	}
+}
+
 // WithOnReload defines a callback that is executed just prior to
 // reloading the application. This is useful for gracefully shutting
-// down your application.
-func WithOnReload(onReload onReloadFunc) option {
+// down your application. The callback runs before any hooks registered
+// with AddOnReload. If the callback panics, the panic is logged and the
+// reload is aborted.
+func WithOnReload(onReload onReloadFunc) Option {
 	if onReload == nil {
 		onReload = func() {}
 	}
@@ -116,90 +213,454 @@ func WithOnReload(onReload onReloadFunc) option {
 	}
 }
 
+// WithExitInsteadOfExec causes the AutoReloader to exit the process with
+// the supplied code instead of re-executing it. This is useful when the
+// application runs under a supervisor, such as systemd, runit or
+// docker-compose, that restarts it when it exits. The callback defined by
+// WithOnReload, and any hooks, run before exiting, so the application can
+// still shut down gracefully.
+func WithExitInsteadOfExec(code int) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.exitCode = &code
+	}
+}
+
+// WithExitFunc defines the function that the AutoReloader calls to exit
+// the process, either on a fatal error or due to WithExitInsteadOfExec.
+// By default, this is os.Exit. The function is not expected to return;
+// tests may supply a function that panics or calls runtime.Goexit to
+// intercept the exit.
+func WithExitFunc(exit func(code int)) Option {
+	if exit == nil {
+		exit = os.Exit
+	}
+	return func(autoReloader *AutoReloader) {
+		autoReloader.exit = exit
+	}
+}
+
 // Start launches a goroutine that periodically checks if the modified
 // time of the command has changed. If so, the binary is re-executed
 // with the same arguments. This is a developer convenience and not
 // intended to be started in a production environment.
+//
+// Calling Start on an AutoReloader that is already running has no
+// effect. An AutoReloader that has been stopped may be started again, in
+// which case a new watcher is created using the same options.
 func (ar AutoReloader) Start() {
-	cmd := ar.cmd
-	if cmd == "" {
-		cmd = os.Args[0]
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	if ar.state.cancel != nil {
+		return
+	}
+	if reason, disabled := ar.disabled(); disabled {
+		ar.log().Info(fmt.Sprintf("Autoreload disabled: %s", reason))
+		return
+	}
+	if !platformSupported || !ar.canReload() {
+		ar.log().Error("Autoreload disabled", ErrUnsupportedPlatform)
+		return
 	}
 
-	watchPath := mustLookPath(ar.logger, cmd)
-	execPath := mustLookPath(ar.logger, os.Args[0])
+	watchPath, commandPaths := ar.mustResolveCommands()
+	execPath := ar.mustResolvePath(os.Args[0])
+	if err := ar.execStrategy.Cleanup(execPath); err != nil {
+		ar.log().Error("Failed to clean up previous executables", err)
+	}
 
-	watcher, err := fsnotify.NewWatcher()
-	must(ar.logger, err, "Failed to create file watcher")
-	must(ar.logger, watcher.Add(watchPath), "Failed to watch file")
+	watcher, err := ar.newWatcher()
+	ar.must(err, "Failed to create file watcher")
+	ar.must(watcher.Add(watchPath), "Failed to watch file")
+	for _, path := range commandPaths {
+		ar.must(watcher.Add(path), fmt.Sprintf("Failed to watch file: %s", path))
+	}
+	for path := range ar.state.paths {
+		ar.must(watcher.Add(path), fmt.Sprintf("Failed to watch path: %s", path))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	requests := make(chan struct{}, 1)
+	done := make(chan struct{})
+	ar.state.cancel = cancel
+	ar.state.done = done
+	ar.state.requests = requests
+	ar.state.watcher = watcher
+	ar.state.watchPath = watchPath
+	ar.state.commandPaths = commandPaths
+	ar.snapshotPaths()
+	ar.readContents()
 
 	go func() {
-		for {
-			select {
-			case <-watcher.Events:
-				ar.logger.Info("Executable changed; reloading process")
-				for i := 0; i < ar.maxAttempts; i++ {
-					sleep(250*time.Millisecond, watcher.Events)
-					if i == 0 {
-						ar.onReload()
-					}
-					tryExec(ar.logger, execPath, os.Args, os.Environ())
+		defer close(done)
+		ar.watch(ctx, watcher, execPath, requests)
+	}()
+}
+
+// newWatcher creates the watcher used by the AutoReloader: a polling
+// watcher if a poll interval was supplied, otherwise a subscription to
+// the WatcherPool if one was supplied or to the pool shared by the
+// process. Tests replace it with openWatcher.
+func (ar AutoReloader) newWatcher() (watcher, error) {
+	if ar.openWatcher != nil {
+		return ar.openWatcher()
+	}
+	if ar.pollInterval > 0 {
+		return newPollWatcher(ar.pollInterval), nil
+	}
+	if ar.pool != nil {
+		return ar.pool.subscribe()
+	}
+	return defaultPool.subscribe()
+}
+
+func (ar AutoReloader) watch(ctx context.Context, watcher watcher, execPath string, requests <-chan struct{}) {
+	defer func() {
+		if watcher != nil {
+			watcher.Close()
+		}
+	}()
+	for {
+		select {
+		case event, ok := <-watcher.Events():
+			if ctx.Err() != nil {
+				return
+			}
+			if !ok {
+				watcher.Close()
+				if watcher = ar.replaceWatcher(ctx.Done()); watcher == nil {
+					return
 				}
-				fatal(ar.logger, "Failed to reload process", errors.New("max attempts reached"))
-			case err := <-watcher.Errors:
-				must(ar.logger, err, "Error watching file")
-			case <-ar.ctx.Done():
+				continue
+			}
+			ar.debug(fmt.Sprintf("Received event: %s", event))
+			ar.safely("Panic while handling event", func() {
+				ar.handle(event, watcher, execPath)
+			})
+			ar.takeSnapshots()
+		case err, ok := <-watcher.Errors():
+			if ctx.Err() != nil {
+				return
+			}
+			if !ok {
+				watcher.Close()
+				if watcher = ar.replaceWatcher(ctx.Done()); watcher == nil {
+					return
+				}
+				continue
+			}
+			ar.safely("Panic while handling error", func() {
+				ar.handleError(err, watcher, execPath)
+			})
+			ar.takeSnapshots()
+		case <-requests:
+			if ctx.Err() != nil {
 				return
 			}
+			ar.safely("Panic while reloading", func() {
+				ar.reload(watcher, execPath, "", true)
+			})
+		case <-ctx.Done():
+			return
 		}
-	}()
+	}
 }
 
-// Stop will stop the autoreloader from watching the executable and reloading it.
+func (ar AutoReloader) handle(event fileEvent, watcher watcher, execPath string) {
+	switch ar.actionFor(event.Name) {
+	case ActionReload:
+		ar.reload(watcher, execPath, event.Name, false)
+	case ActionLog:
+		ar.log().Info(fmt.Sprintf("Path changed: %s", event.Name))
+	case ActionPlugin:
+		ar.rebuildPlugin(event.Name, watcher, execPath)
+	}
+}
+
+// reload re-executes the process in response to the change of path, or
+// to an explicit request when forced. Unless forced, the reload is
+// skipped if the change, once settled, does not warrant one. If the
+// executable does not become valid or a reload hook panics, the reload
+// is aborted and the AutoReloader continues watching.
+func (ar AutoReloader) reload(watcher watcher, execPath string, path string, forced bool) {
+	ar.setReloading(true)
+	defer ar.setReloading(false)
+
+	events := watcher.Events()
+	var paths []string
+	if forced {
+		ar.log().Info("Reload requested; reloading process")
+	} else {
+		ar.debug(fmt.Sprintf("Change detected: %s", path))
+		paths = ar.settledPaths(path, ar.sleep(ar.debounce, events))
+		changed := false
+		for _, path := range paths {
+			if ar.shouldReload(path) {
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
+		ar.log().Info("Executable changed; reloading process")
+	}
+
+	if err := ar.waitForValidExecutable(execPath, events); err != nil {
+		ar.abort(path, err)
+		return
+	}
+	if err := ar.prepareInheritFDs(); err != nil {
+		ar.abort(path, err)
+		return
+	}
+	from, to := currentBuildInfo(), ReadBuildInfo(execPath)
+	if to != nil {
+		ar.log().Info(fmt.Sprintf("Reloading from %s to %s", from, to))
+	}
+	if ar.blueGreen != nil {
+		kill, err := ar.startBlueGreen(execPath, paths)
+		if err != nil {
+			ar.abort(path, err)
+			return
+		}
+		if !ar.runHooks() {
+			kill()
+			ar.abort(path, errors.New("reload hook panicked"))
+			return
+		}
+		ar.remember(ReloadInfo{
+			Time: time.Now(), Path: path, Paths: paths, Outcome: ReloadExited, FromBuild: from, ToBuild: to,
+		})
+		ar.log().Info("New process is ready; exiting")
+		ar.exit(0)
+		return
+	}
+	if !ar.runHooks() {
+		ar.abort(path, errors.New("reload hook panicked"))
+		return
+	}
+	if ar.exitCode != nil {
+		ar.remember(ReloadInfo{
+			Time: time.Now(), Path: path, Paths: paths, Outcome: ReloadExited, FromBuild: from, ToBuild: to,
+		})
+		ar.notify("STOPPING=1")
+		ar.log().Info(fmt.Sprintf("Exiting with code %d", *ar.exitCode))
+		ar.exit(*ar.exitCode)
+		return
+	}
+	if !ar.waitForExecutable(execPath, events) {
+		ar.execSelf(execPath)
+		ar.fail(ReloadInfo{Path: path, Err: fmt.Errorf("executable was not replaced: %s", execPath)})
+		return
+	}
+	ar.notify("RELOADING=1")
+
+	executed := ReloadInfo{Path: path, Paths: paths, Outcome: ReloadExecuted, FromBuild: from, ToBuild: to}
+	var lastErr error
+	for i := 0; ar.maxAttempts == UnlimitedAttempts || i < ar.maxAttempts; i++ {
+		if i > 0 {
+			ar.sleep(ar.debounce, events)
+			// The executable may have been removed or rewritten since the
+			// last attempt; wait until it is complete again.
+			if lastErr = verifyExecutable(execPath); lastErr != nil {
+				ar.debug(fmt.Sprintf("Executable not ready: %v", lastErr))
+				continue
+			}
+		}
+		ar.debug(fmt.Sprintf("Reload attempt %d", i+1))
+		argv0, err := ar.execStrategy.Prepare(execPath)
+		if err != nil {
+			ar.log().Error("Failed to prepare executable", err)
+			lastErr = err
+			continue
+		}
+		executed.Time = time.Now()
+		executed.Attempts++
+		lastErr = ar.tryExec(argv0, execArgs(execPath), ar.executedEnv(executed))
+		if lastErr != nil && !retryableExecError(lastErr) {
+			ar.fail(ReloadInfo{
+				Path: path, Paths: paths, Attempts: executed.Attempts, Err: fmt.Errorf("syscall.Exec: %s: %w", argv0, lastErr),
+				FromBuild: from, ToBuild: to,
+			})
+			return
+		}
+	}
+	ar.fail(ReloadInfo{
+		Path: path, Paths: paths, Attempts: executed.Attempts, Err: fmt.Errorf("max attempts reached: %w", lastErr),
+		FromBuild: from, ToBuild: to,
+	})
+}
+
+// abort records and logs a reload that was abandoned.
+func (ar AutoReloader) abort(path string, err error) {
+	ar.remember(ReloadInfo{Time: time.Now(), Path: path, Outcome: ReloadAborted, Err: err})
+	ar.log().Error("Reload aborted", err)
+}
+
+// fail records a reload that could not be completed and exits.
+func (ar AutoReloader) fail(info ReloadInfo) {
+	info.Time = time.Now()
+	info.Outcome = ReloadFailed
+	ar.remember(info)
+	ar.notify("STOPPING=1")
+	ar.fatal("Failed to reload process", info.Err)
+}
+
+func (ar AutoReloader) setReloading(reloading bool) {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	ar.state.reloading = reloading
+}
+
+// Stop will stop the autoreloader from watching the executable and
+// reloading it. A reload that is already in progress is not
+// interrupted; Stop waits for it to finish, so it must not be called
+// from a reload hook. Once Stop returns, no change or request leads to
+// a reload. Calling Stop on an AutoReloader that is not running has no
+// effect.
 func (ar AutoReloader) Stop() {
-	ar.cancel()
+	ar.state.mu.Lock()
+	if ar.state.cancel == nil {
+		ar.state.mu.Unlock()
+		return
+	}
+	ar.state.cancel()
+	done := ar.state.done
+	ar.state.cancel = nil
+	ar.state.done = nil
+	ar.state.requests = nil
+	ar.state.watcher = nil
+	ar.state.mu.Unlock()
+	<-done
+}
+
+// name returns the name that identifies the AutoReloader.
+func (ar AutoReloader) name() string {
+	if ar.label != "" {
+		return ar.label
+	}
+	return filepath.Base(ar.command())
+}
+
+// command returns the command that the AutoReloader watches.
+func (ar AutoReloader) command() string {
+	if ar.cmd == "" {
+		return os.Args[0]
+	}
+	return ar.cmd
 }
 
-func must(logger Logger, err error, msg string) {
+// execArgs returns the arguments for the re-executed process. A relative
+// argv[0] is replaced with the resolved executable path, so that the new
+// process can find itself even if the working directory has changed.
+func execArgs(execPath string) []string {
+	args := append([]string{}, os.Args...)
+	if !filepath.IsAbs(args[0]) && strings.ContainsRune(args[0], filepath.Separator) {
+		args[0] = execPath
+	}
+	return args
+}
+
+func (ar AutoReloader) must(err error, msg string) {
 	if err != nil {
-		fatal(logger, msg, err)
+		ar.fatal(msg, err)
 	}
 }
 
-func mustLookPath(logger Logger, name string) string {
-	path, err := exec.LookPath(name)
+// mustResolveCommands resolves the watched commands, exiting with a list
+// of every command that cannot be found.
+func (ar AutoReloader) mustResolveCommands() (string, []string) {
+	var missing []string
+	resolve := func(name string) string {
+		path, err := resolvePath(name)
+		if err != nil {
+			missing = append(missing, name)
+		}
+		return path
+	}
+	watchPath := resolve(ar.command())
+	var commandPaths []string
+	for _, cmd := range ar.cmds {
+		commandPaths = append(commandPaths, resolve(cmd))
+	}
+	if len(missing) > 0 {
+		ar.fatal("Cannot find executables", fmt.Errorf("not found: %s", strings.Join(missing, ", ")))
+	}
+	return watchPath, commandPaths
+}
+
+// mustResolvePath resolves the executable to an absolute path without
+// symlinks, so that the path remains valid if the working directory
+// changes.
+func (ar AutoReloader) mustResolvePath(name string) string {
+	path, err := resolvePath(name)
 	if err != nil {
-		fatal(logger, fmt.Sprintf("Cannot find executable: %s", name), err)
+		ar.fatal(fmt.Sprintf("Cannot find executable: %s", name), err)
 	}
 	return path
 }
 
+func resolvePath(name string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
 // sleep pauses the current goroutine for at least duration d, swallowing
-// all fsnotify events received in the interim.
-func sleep(d time.Duration, events chan fsnotify.Event) {
-	timer := time.After(d)
+// all fsnotify events received in the interim. It returns the swallowed
+// events.
+func (ar AutoReloader) sleep(d time.Duration, events <-chan fileEvent) []fileEvent {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	var swallowed []fileEvent
 	for {
 		select {
-		case <-events:
-		case <-timer:
-			return
+		case event := <-events:
+			ar.debug(fmt.Sprintf("Ignoring event during reload: %s", event))
+			swallowed = append(swallowed, event)
+		case <-timer.C:
+			return swallowed
 		}
 	}
 }
 
-func tryExec(logger Logger, argv0 string, argv []string, envv []string) {
-	if err := syscall.Exec(argv0, argv, envv); err != nil {
-		if errno, ok := err.(syscall.Errno); ok {
-			if errno == syscall.ETXTBSY {
-				return
-			}
+// settledPaths returns the path that triggered a reload followed by the
+// other reloading paths that changed while it settled.
+func (ar AutoReloader) settledPaths(path string, events []fileEvent) []string {
+	paths := []string{path}
+	for _, event := range events {
+		if ar.actionFor(event.Name) != ActionReload {
+			continue
+		}
+		seen := false
+		for _, p := range paths {
+			seen = seen || p == event.Name
 		}
-		fatal(logger, fmt.Sprintf("syscall.Exec: %s", argv0), err)
+		if !seen {
+			paths = append(paths, event.Name)
+		}
+	}
+	return paths
+}
+
+// tryExec replaces the process with the executable. It only returns if
+// the exec failed, with the error, which retryableExecError tells apart
+// from failures that a later attempt cannot fix.
+func (ar AutoReloader) tryExec(argv0 string, argv []string, envv []string) error {
+	err := ar.execve(argv0, argv, envv)
+	if err == nil {
+		ar.exit(0)
+		return nil
 	}
-	os.Exit(0)
+	ar.debug(fmt.Sprintf("syscall.Exec: %s: %v", argv0, err))
+	return err
 }
 
-func fatal(logger Logger, msg string, err error) {
-	logger.Error(msg, err)
-	os.Exit(1)
+func (ar AutoReloader) fatal(msg string, err error) {
+	ar.log().Error(msg, err)
+	ar.exit(1)
 }
@@ -0,0 +1,196 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestStartAfterStop checks that an AutoReloader that was stopped
+// ignores changes, and reloads on a change once started again.
+func TestStartAfterStop(t *testing.T) {
+	h := newTestReloader(t)
+	h.Start()
+	h.Stop()
+	if h.Status().Running {
+		t.Fatal("running after Stop")
+	}
+	h.write("v2")
+	h.quiet()
+
+	h.Start()
+	if !h.Status().Running {
+		t.Fatal("not running after starting again")
+	}
+	h.write("v3")
+	call := h.exec()
+	if call.err != nil {
+		t.Fatal(call.err)
+	}
+	if want, _ := resolvePath(os.Args[0]); call.argv0 != want {
+		t.Errorf("exec of %s, want %s", call.argv0, want)
+	}
+}
+
+// TestCopyExecCleansUpOnStart checks that an AutoReloader using CopyExec
+// removes the stale copies when it starts and executes a fresh copy.
+func TestCopyExecCleansUpOnStart(t *testing.T) {
+	execPath, err := resolvePath(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	stale := filepath.Join(dir, CopyExec(dir).(copyExec).prefix(execPath)+"1")
+	if err := ioutil.WriteFile(stale, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestReloader(t, WithExecStrategy(CopyExec(dir)))
+	h.Start()
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale copy %s was not removed: %v", stale, err)
+	}
+	h.write("v2")
+	if call := h.exec(); filepath.Dir(call.argv0) != dir {
+		t.Errorf("exec of %s, want a copy in %s", call.argv0, dir)
+	}
+}
+
+// chdir changes the working directory until the end of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// TestRelativeCommandAfterChdir checks that a command given as a
+// relative path is still watched, and the process re-executed by its
+// absolute path, after the working directory changes.
+func TestRelativeCommandAfterChdir(t *testing.T) {
+	h := newTestReloader(t)
+	chdir(t, filepath.Dir(h.cmd))
+	args := os.Args
+	os.Args = append([]string{"./" + filepath.Base(h.cmd)}, args[1:]...)
+	t.Cleanup(func() { os.Args = args })
+
+	h.AutoReloader = New(WithCommand("./"+filepath.Base(h.cmd)), WithLogger(h.logger),
+		WithDebounce(20*time.Millisecond), WithWatcherPool(NewWatcherPool()), h.fake)
+	t.Cleanup(h.Stop)
+	h.Start()
+	chdir(t, t.TempDir())
+
+	h.write("v2")
+	call := h.exec()
+	if call.argv0 != h.cmd || call.argv[0] != h.cmd {
+		t.Errorf("exec of %s as %s, want %s", call.argv0, call.argv[0], h.cmd)
+	}
+	if status := h.Status(); status.Paths[0].Path != h.cmd {
+		t.Errorf("watching %s, want %s", status.Paths[0].Path, h.cmd)
+	}
+}
+
+func TestExecArgs(t *testing.T) {
+	args := os.Args
+	t.Cleanup(func() { os.Args = args })
+	tests := []struct {
+		arg0 string
+		want string
+	}{
+		{"./bin/app", "/src/bin/app"},
+		{"bin/app", "/src/bin/app"},
+		{"/src/bin/app", "/src/bin/app"},
+		{"app", "app"},
+	}
+	for _, tt := range tests {
+		os.Args = []string{tt.arg0, "-flag", "value"}
+		got := execArgs("/src/bin/app")
+		if want := []string{tt.want, "-flag", "value"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("execArgs with argv[0] %s = %q, want %q", tt.arg0, got, want)
+		}
+	}
+}
+
+// TestExecRetriesENOENT checks that an exec failing with ENOENT, as when
+// the executable is being replaced, is retried until it succeeds.
+func TestExecRetriesENOENT(t *testing.T) {
+	h := newTestReloader(t)
+	h.failExecs(syscall.ENOENT, syscall.ENOENT)
+	h.Start()
+
+	h.write("v2")
+	for i, want := range []error{syscall.ENOENT, syscall.ENOENT, nil} {
+		if call := h.exec(); call.err != want {
+			t.Fatalf("attempt %d: exec error = %v, want %v", i+1, call.err, want)
+		}
+	}
+	h.quiet()
+}
+
+// TestExecFatalError checks that an exec failing in a way that cannot
+// succeed later exits without retrying.
+func TestExecFatalError(t *testing.T) {
+	h := newTestReloader(t)
+	h.failExecs(syscall.E2BIG)
+	h.Start()
+
+	h.write("v2")
+	if call := h.exec(); call.err != syscall.E2BIG {
+		t.Fatalf("exec error = %v, want E2BIG", call.err)
+	}
+	if code := h.exit(); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	h.quiet()
+}
+
+// TestCommands checks that a change to a command other than the first
+// reloads.
+func TestCommands(t *testing.T) {
+	worker := filepath.Join(t.TempDir(), "worker")
+	if err := ioutil.WriteFile(worker, []byte("v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestReloader(t)
+	h.AutoReloader = New(WithCommands(h.cmd, worker), WithLogger(h.logger),
+		WithDebounce(20*time.Millisecond), WithWatcherPool(NewWatcherPool()), h.fake)
+	t.Cleanup(h.Stop)
+	h.Start()
+
+	if err := ioutil.WriteFile(worker, []byte("v2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+}
+
+// TestCommandsMissing checks that Start exits, naming every command it
+// cannot find.
+func TestCommandsMissing(t *testing.T) {
+	dir := t.TempDir()
+	worker, helper := filepath.Join(dir, "worker"), filepath.Join(dir, "helper")
+	h := newTestReloader(t)
+	h.AutoReloader = New(WithCommands(h.cmd, worker, helper), WithLogger(h.logger), h.fake)
+	t.Cleanup(h.Stop)
+	go h.Start()
+
+	if code := h.exit(); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	if want := "Cannot find executables: not found: " + worker + ", " + helper; !h.logger.contains(want) {
+		t.Errorf("log does not contain %q", want)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agschwender/autoreload"
+)
+
+// binaryInfo describes an executable, to report how it changed between
+// restarts and to tell whether it did.
+type binaryInfo struct {
+	build   *autoreload.BuildInfo
+	file    os.FileInfo
+	size    int64
+	modTime time.Time
+}
+
+// readBinaryInfo describes the executable at path.
+func readBinaryInfo(path string) binaryInfo {
+	b := binaryInfo{build: autoreload.ReadBuildInfo(path)}
+	if info, err := os.Stat(path); err == nil {
+		b.file, b.size, b.modTime = info, info.Size(), info.ModTime()
+	}
+	return b
+}
+
+// linkTarget returns the file that the symlink at path refers to, or ""
+// if it is not a symlink. The target of a dangling symlink is the path
+// it names, so that the target is noticed once created.
+func linkTarget(path string) string {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return ""
+	}
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		return target
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return ""
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return target
+}
+
+// unchanged reports whether the executable at path is still the file
+// described, with the same size and modification time.
+func (b binaryInfo) unchanged(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && b.file != nil && os.SameFile(b.file, info) &&
+		info.Size() == b.size && info.ModTime().Equal(b.modTime)
+}
+
+// diff describes how the executable changed, such as
+// "abc1234 → def5678 (+2 commits, worktree dirty)", or returns "" if it
+// was not replaced. Commits are counted with git in dir. Executables
+// without build information or revisions are compared by size and
+// modification time.
+func (b binaryInfo) diff(to binaryInfo, dir string) string {
+	if b.size == to.size && b.modTime.Equal(to.modTime) {
+		return ""
+	}
+	if b.build == nil || to.build == nil || b.build.Revision == "" && to.build.Revision == "" {
+		return fmt.Sprintf("%d → %d bytes (%+d), modified %v later",
+			b.size, to.size, to.size-b.size, to.modTime.Sub(b.modTime).Round(time.Second))
+	}
+
+	from, into := b.build, to.build
+	var notes []string
+	switch {
+	case from.Revision != "" && from.Revision == into.Revision:
+		notes = append(notes, "same revision")
+	case from.Revision != "" && into.Revision != "":
+		if n, ok := countCommits(dir, from.Revision, into.Revision); ok {
+			notes = append(notes, plural(n, "commit"))
+		}
+		if !into.Time.IsZero() {
+			notes = append(notes, "committed "+into.Time.Format(time.RFC3339))
+		}
+	}
+	if into.Modified {
+		notes = append(notes, "worktree dirty")
+	}
+	if from.Version != into.Version && tagged(from) && tagged(into) {
+		notes = append(notes, fmt.Sprintf("version %s → %s", from.Version, into.Version))
+	}
+	if from.GoVersion != into.GoVersion {
+		notes = append(notes, fmt.Sprintf("%s → %s", from.GoVersion, into.GoVersion))
+	}
+
+	s := fmt.Sprintf("%s → %s", shortBuild(from), shortBuild(into))
+	if len(notes) > 0 {
+		s += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return s
+}
+
+// shortBuild returns the short revision of the build, or its version if
+// the revision is unknown.
+func shortBuild(b *autoreload.BuildInfo) string {
+	switch {
+	case len(b.Revision) > 7:
+		return b.Revision[:7]
+	case b.Revision != "":
+		return b.Revision
+	case b.Version != "":
+		return b.Version
+	default:
+		return "unknown"
+	}
+}
+
+// tagged reports whether the build has a version other than one derived
+// from its revision.
+func tagged(b *autoreload.BuildInfo) bool {
+	if b.Version == "" || b.Version == "(devel)" {
+		return false
+	}
+	return b.Revision == "" || !strings.Contains(b.Version, shortBuild(b))
+}
+
+// plural formats the signed count of the noun, such as "+2 commits".
+func plural(n int, noun string) string {
+	if n != 1 && n != -1 {
+		noun += "s"
+	}
+	return fmt.Sprintf("%+d %s", n, noun)
+}
+
+// countCommits counts the commits from one revision to another, which is
+// negative if the other revision is older.
+func countCommits(dir, from, to string) (int, bool) {
+	count := func(revs string) (int, bool) {
+		cmd := exec.Command("git", "rev-list", "--count", revs)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			return 0, false
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+		return n, err == nil
+	}
+	if n, ok := count(from + ".." + to); ok && n > 0 {
+		return n, true
+	}
+	n, ok := count(to + ".." + from)
+	return -n, ok
+}
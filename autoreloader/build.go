@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// maxBuildErrors caps how much of the error output of a build is kept.
+const maxBuildErrors = 64 * 1024
+
+// build is a run of the build command.
+type build struct {
+	cmd     *exec.Cmd
+	started time.Time
+	out     *lineWriter
+	errs    *lineWriter
+	done    chan error
+
+	stderr bytes.Buffer
+}
+
+// startBuild runs the build command through the shell in the directory.
+// Its output is written with a prefix, and the result is sent on done
+// once it exits.
+func startBuild(command, dir string) (*build, error) {
+	b := &build{
+		out:  newPrefixWriter(stdout, "[build] ", styleBuild),
+		errs: newPrefixWriter(stderr, "[build] ", styleBuild),
+		done: make(chan error, 1),
+	}
+	b.cmd = exec.Command("/bin/sh", "-c", command)
+	b.cmd.Dir = dir
+	b.cmd.Stdout = b.out
+	b.cmd.Stderr = io.MultiWriter(b.errs, &limitedWriter{w: &b.stderr, n: maxBuildErrors})
+	// Run the build in its own process group, so that canceling it also
+	// stops the compilers it runs.
+	setProcessGroup(b.cmd)
+	if err := b.cmd.Start(); err != nil {
+		return nil, err
+	}
+	b.started = time.Now()
+	go func() {
+		err := b.cmd.Wait()
+		b.out.flush()
+		b.errs.flush()
+		b.done <- err
+	}()
+	return b, nil
+}
+
+// cancel stops the build and waits for it to exit.
+func (b *build) cancel() {
+	killGroup(b.cmd)
+	<-b.done
+}
+
+// firstError returns the first line of the error output of the build.
+func (b *build) firstError() string {
+	line := strings.TrimSpace(b.stderr.String())
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return line
+}
+
+// failure describes a failed build.
+func (b *build) failure(err error) error {
+	if first := b.firstError(); first != "" {
+		return fmt.Errorf("%v: %s", err, first)
+	}
+	return err
+}
+
+// limitedWriter writes at most n bytes to w, discarding the rest.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(b []byte) (int, error) {
+	if len(b) > l.n {
+		l.w.Write(b[:l.n])
+		l.n = 0
+		return len(b), nil
+	}
+	l.n -= len(b)
+	return l.w.Write(b)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// clearMode defines what is cleared before the command is restarted.
+type clearMode int
+
+const (
+	// clearNone clears nothing.
+	clearNone clearMode = iota
+
+	// clearScreen clears the screen.
+	clearScreen
+
+	// clearFull clears the screen and the scrollback.
+	clearFull
+)
+
+func (m *clearMode) String() string {
+	switch *m {
+	case clearScreen:
+		return "screen"
+	case clearFull:
+		return "full"
+	default:
+		return ""
+	}
+}
+
+func (m *clearMode) Set(s string) error {
+	switch s {
+	case "true", "screen":
+		*m = clearScreen
+	case "full":
+		*m = clearFull
+	case "false":
+		*m = clearNone
+	default:
+		return fmt.Errorf("must be screen or full")
+	}
+	return nil
+}
+
+func (m *clearMode) IsBoolFlag() bool { return true }
+
+// clear clears the terminal, if stdout is one.
+func (m clearMode) clear() {
+	if m == clearNone || !isTerminal(os.Stdout) {
+		return
+	}
+	seq := "\x1b[H\x1b[2J"
+	if m == clearFull {
+		seq += "\x1b[3J"
+	}
+	os.Stdout.WriteString(seq)
+}
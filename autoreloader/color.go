@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// colors reports whether output is colored. It is never set in JSON log
+// mode.
+var colors bool
+
+// style is an ANSI color that tells apart the output of the wrapper, the
+// build and the command.
+type style string
+
+const (
+	styleNone    style = ""
+	styleWrapper style = "\x1b[36m"
+	styleBuild   style = "\x1b[35m"
+	styleStderr  style = "\x1b[31m"
+
+	styleReset = "\x1b[0m"
+)
+
+// nameStyles tell apart the processes of a Procfile.
+var nameStyles = []style{"\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m", "\x1b[92m", "\x1b[93m", "\x1b[94m"}
+
+// paint colors the text, other than a trailing newline, if output is
+// colored.
+func (s style) paint(text string) string {
+	if !colors || s == styleNone {
+		return text
+	}
+	if strings.HasSuffix(text, "\n") {
+		return string(s) + text[:len(text)-1] + styleReset + "\n"
+	}
+	return string(s) + text + styleReset
+}
+
+// colorMode defines when output is colored.
+type colorMode int
+
+const (
+	// colorAuto colors output if stdout is a terminal and NO_COLOR is
+	// not set.
+	colorAuto colorMode = iota
+
+	// colorAlways colors output.
+	colorAlways
+
+	// colorNever does not color output.
+	colorNever
+)
+
+func (m *colorMode) String() string {
+	switch *m {
+	case colorAlways:
+		return "always"
+	case colorNever:
+		return "never"
+	default:
+		return "auto"
+	}
+}
+
+func (m *colorMode) Set(s string) error {
+	switch s {
+	case "auto":
+		*m = colorAuto
+	case "always":
+		*m = colorAlways
+	case "never":
+		*m = colorNever
+	default:
+		return fmt.Errorf("must be auto, always or never")
+	}
+	return nil
+}
+
+// colored reports whether output is colored.
+func (cfg *config) colored() bool {
+	switch {
+	case cfg.logFormat == formatJSON || cfg.color == colorNever:
+		return false
+	case processName != "":
+		// The output of the wrapper of a process of a Procfile is
+		// colored if that of the wrapper of them all is.
+		return os.Getenv(procfileColorEnv) != ""
+	case cfg.color == colorAlways:
+		return true
+	case os.Getenv("NO_COLOR") != "":
+		return false
+	default:
+		return isTerminal(os.Stdout)
+	}
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultDelay is how long changes must settle before the command is
+// restarted.
+const defaultDelay = 250 * time.Millisecond
+
+// config holds the options of the wrapper.
+type config struct {
+	killSignal  syscall.Signal
+	killTimeout time.Duration
+	delay       time.Duration
+	watch       []string
+	triggers    []string
+	ignore      []string
+	exts        []string
+	debugEvents bool
+	verbose     bool
+	quiet       bool
+	build       string
+	goPkg       string
+	tty         bool
+	env         []string
+	envFiles    []string
+	expandEnv   expandMode
+	command     []string
+	shell       string
+
+	procfile        string
+	procfileEntries []procfileEntry
+	exitOnFailure   bool
+
+	processGroup  bool
+	includeHidden bool
+
+	signalOnly  signalValue
+	signalRules signalRules
+
+	once        bool
+	onceTimeout time.Duration
+
+	restartOnExit bool
+	backoffBase   time.Duration
+	backoffMax    time.Duration
+	maxCrashes    int
+
+	crashTail int
+
+	stdoutTarget outputTarget
+	stderrTarget outputTarget
+
+	maxRestarts   int
+	restartWindow time.Duration
+
+	noRestartOnError bool
+	infantMortality  time.Duration
+
+	exitPolicy   exitPolicy
+	exitOnChange exitOnChange
+	poll         pollValue
+	notify       bool
+
+	webhook        string
+	webhookHeaders []string
+
+	validate    string
+	preHooks    []string
+	postHooks   []string
+	hookTimeout time.Duration
+
+	maxAttempts   int
+	retryInterval time.Duration
+	retryMaxWait  time.Duration
+
+	chdir           string
+	waitFirstChange bool
+	clear           clearMode
+
+	waitTCP       tcpProbe
+	failOnUnready bool
+	overlap       bool
+
+	waitPortFree   tcpProbe
+	failOnPortBusy bool
+
+	color colorMode
+
+	init    bool
+	daemon  bool
+	pidfile string
+	socket  string
+	logFile string
+
+	metricsAddr string
+
+	logFileOnly bool
+	logMaxSize  sizeValue
+	logKeep     int
+
+	logFormat       logFormat
+	wrapChildOutput bool
+}
+
+// parseConfig parses the wrapper flags, which precede the command to
+// run and its arguments. Parsing stops at "--" or the first argument
+// that is not a flag, so the arguments of the command are passed
+// verbatim, even those that look like wrapper flags. Errors are reported
+// to standard error along with the usage.
+func parseConfig(args []string) (*config, error) {
+	cfg := &config{killSignal: syscall.SIGTERM}
+
+	fs := flag.NewFlagSet("autoreloader", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: autoreloader [flags] [--] command [args...]\n       autoreloader --go package [flags] [--] [args...]\n       autoreloader -c 'command string' [flags]\n       autoreloader -f Procfile [flags]\n\nArguments after the command, or after --, are passed to it unchanged.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cfg.goPkg, "go", "", "Go main package, such as ./cmd/server, to build and run with the arguments; its module is watched for changes to Go sources, go.mod, go.sum and embedded files, which rebuild it")
+	fs.StringVar(&cfg.shell, "c", "", "command string run by $SHELL -c, or sh -c, such as 'bin/server 2>&1 | tee out.log', in its own process group; the shell is not watched, so give the paths to watch with --watch, unless the first word of the string is a path, which is then watched")
+	fs.StringVar(&cfg.procfile, "f", "", "Procfile whose processes, each a line such as 'api: ./bin/api --port 8000', are supervised together, each restarted when its own executable changes; lines such as 'api.watch: ./templates' and 'api.ignore: *.tmp' add paths to watch and globs to ignore for a process")
+	fs.BoolVar(&cfg.exitOnFailure, "exit-on-failure", false, "with -f, stop every process and exit with the code of the first one that exits with a non-zero code")
+	fs.Var(&cfg.signalOnly, "signal-only", "signal, such as HUP, sent to the command when a watched path changes, rather than restarting it")
+	fs.Var(&cfg.signalRules, "on", "PATH=SIGNAL, such as ./configs=HUP, to watch the path and send the signal to the command when a path beneath it changes, rather than restarting it; may be repeated")
+	fs.Var((*signalValue)(&cfg.killSignal), "kill-signal", "signal sent to the command to stop it for a reload, such as TERM, INT or USR2")
+	fs.Var((*stringsValue)(&cfg.watch), "watch", "additional file or directory to watch, recursively for directories; may be repeated")
+	fs.Var((*stringsValue)(&cfg.ignore), "ignore", "glob of paths, relative to the watched directory, whose changes are ignored, such as '**/*_test.go'; may be repeated")
+	fs.Var((*stringsValue)(&cfg.triggers), "trigger-file", "file that restarts the command when it is written or created, such as tmp/restart.txt; it need not exist, but its directory must; may be repeated")
+	fs.Var((*extsValue)(&cfg.exts), "ext", "comma-separated extensions, such as 'py,yaml', of the files within watched directories whose changes restart the command; may be repeated")
+	fs.BoolVar(&cfg.includeHidden, "include-hidden", false, "watch the files and directories within watched directories whose names start with a dot, which are otherwise ignored")
+	fs.Var(&cfg.poll, "poll", "check the watched paths for changes at an interval, 500ms by default, rather than relying on file system events, which mounted volumes may not deliver; use --poll=interval to set it")
+	fs.BoolVar(&cfg.debugEvents, "debug-events", false, "log every watch event and the pattern that ignored it, if any")
+	fs.StringVar(&cfg.build, "build", "", "shell command that builds the command before each restart; the command is then only restarted if the build succeeds; {file}, {dir}, {base} and {ext} are replaced by the first changed path, its directory, base name and extension, and {files} by all changed paths, each quoted for the shell")
+	fs.Var((*stringsValue)(&cfg.env), "env", "KEY=VALUE variable set in the environment of the command; may be repeated")
+	fs.Var((*stringsValue)(&cfg.envFiles), "env-file", "dotenv file whose variables are set in the environment of the command; it is watched, so changes restart the command; may be repeated")
+	fs.Var(&cfg.expandEnv, "expand-env", "expand $VAR and ${VAR} in the arguments of the command, the --build, --pre, --post and --validate commands and the --env values from the environment of the command on every start, with $$ for a literal $; unset variables expand to nothing with a warning, or fail the start with --expand-env=strict")
+	noProcessGroup := fs.Bool("no-process-group", false, "only signal the command itself, rather than its process group, when stopping it")
+	fs.BoolVar(&cfg.once, "once", false, "restart the command only once, on the first change, then exit with its exit code once it exits or --once-timeout passes; changes are then ignored")
+	fs.DurationVar(&cfg.onceTimeout, "once-timeout", 0, "with --once, how long the restarted command runs before it is stopped and the wrapper exits with 0, or 0 to wait for it to exit")
+	fs.BoolVar(&cfg.restartOnExit, "restart-on-exit", false, "restart the command when it exits on its own, not just when it changes")
+	fs.DurationVar(&cfg.backoffBase, "backoff", 500*time.Millisecond, "delay before restarting the command after its first exit; it doubles with every consecutive exit")
+	fs.DurationVar(&cfg.backoffMax, "max-backoff", 30*time.Second, "maximum delay before restarting the command after an exit; the delay is reset once the command stays up this long")
+	fs.IntVar(&cfg.maxCrashes, "max-crashes", 0, "number of consecutive exits after which the command is no longer restarted, or 0 for no limit")
+	fs.IntVar(&cfg.maxRestarts, "max-restarts", 0, "number of restarts caused by changes within --per after which restarts pause until the window allows another, keeping the latest change, or 0 for no limit")
+	fs.DurationVar(&cfg.restartWindow, "per", time.Minute, "window of --max-restarts")
+	fs.Var(&cfg.stdoutTarget, "stdout", "where the standard output of the command is written: - for that of the autoreloader, null to discard it, or a file it is appended to, as it is, without styles")
+	fs.Var(&cfg.stderrTarget, "stderr", "where the error output of the command is written, like --stdout")
+	fs.IntVar(&cfg.crashTail, "crash-tail", 50, "number of last lines of the error output of the command shown when it fails, or 0 for none")
+	fs.BoolVar(&cfg.noRestartOnError, "no-restart-on-error", false, "if the command fails soon after it was restarted due to a change, report its errors and wait for the next change rather than restart or exit")
+	fs.DurationVar(&cfg.infantMortality, "infant-mortality", 2*time.Second, "how soon after a restart a failure of the command counts for --no-restart-on-error")
+	fs.StringVar(&cfg.webhook, "webhook", "", "URL to POST a JSON event to when the command is restarted or crashes, or a build fails")
+	fs.Var((*stringsValue)(&cfg.webhookHeaders), "webhook-header", "KEY=VALUE header of the --webhook requests, such as for authorization; may be repeated")
+	fs.Var(&cfg.exitOnChange, "exit-on-change", "rather than restart the command when it changes, stop it and exit with the code, 0 by default, for a supervisor such as systemd to restart the wrapper; use --exit-on-change=code to set it")
+	fs.Var(&cfg.exitPolicy, "exit-code", "exit code of the wrapper: child to use that of the last process, zero-on-signal to exit with 0 when stopped by a signal, or a fixed code")
+	fs.BoolVar(&cfg.notify, "notify", false, "send a desktop notification when the command is restarted or a build fails")
+	fs.Var((*stringsValue)(&cfg.preHooks), "pre", "shell command run before the command is restarted; if it fails, the running command is kept; may be repeated; see --build for placeholders")
+	fs.Var((*stringsValue)(&cfg.postHooks), "post", "shell command run after the command is restarted; may be repeated")
+	fs.StringVar(&cfg.validate, "validate", "", "shell command that must succeed before the command is restarted, with {file} replaced by its executable; it is restarted only once its executable is a valid one and this passes, or else the running process is kept")
+	fs.DurationVar(&cfg.hookTimeout, "hook-timeout", time.Minute, "how long a --pre, --post or --validate command may run before it is killed")
+	fs.IntVar(&cfg.maxAttempts, "max-attempts", 10, "how many times starting the command is attempted while its executable is busy or missing, as it is during a build, or 0 to keep trying")
+	fs.DurationVar(&cfg.retryInterval, "retry-interval", 250*time.Millisecond, "delay between attempts to start the command")
+	fs.DurationVar(&cfg.retryMaxWait, "retry-max-wait", 0, "how long to keep attempting to start the command, or 0 for no limit")
+	fs.BoolVar(&cfg.waitFirstChange, "wait-first-change", false, "only start the command once its executable, or with --build a watched path, changes for the first time; the executable need not exist yet")
+	fs.StringVar(&cfg.chdir, "chdir", "", "working directory of the command, the build and the hooks; the command and watched paths are still relative to the current directory")
+	fs.Var(&cfg.clear, "clear", "clear the terminal before the command is restarted; use --clear=full to also clear the scrollback")
+	fs.Var(&cfg.waitTCP, "wait-tcp", "host:port[,timeout] that the command accepts connections on once it is ready; a restart is only complete, and --post commands run, once it does, waiting up to the timeout, 30s by default")
+	fs.BoolVar(&cfg.failOnUnready, "fail-on-unready", false, "stop the command and exit if it is not ready within the --wait-tcp timeout")
+	fs.BoolVar(&cfg.overlap, "overlap", false, "start the new process before stopping the old one, which is only stopped once the new one is ready according to --wait-tcp; if it is not, the new one is killed instead. The command must tolerate two instances, such as by listening with SO_REUSEPORT")
+	fs.Var(&cfg.waitPortFree, "wait-port-free", "host:port[,timeout] that the command listens on; after stopping the command, wait up to the timeout, 30s by default, for it to be free before starting the command again")
+	fs.BoolVar(&cfg.failOnPortBusy, "fail-on-port-busy", false, "exit rather than start the command if the --wait-port-free address is still in use after the timeout")
+	fs.BoolVar(&cfg.tty, "tty", false, "run the command under a pseudo-terminal, as if it were run interactively")
+	fs.DurationVar(&cfg.delay, "delay", defaultDelay, "how long changes must settle before the command is restarted")
+	fs.BoolVar(&cfg.init, "init", os.Getpid() == 1, "act as the init process of a container, reaping orphaned processes and relaying signals to the wrapper; the default when running as PID 1")
+	fs.BoolVar(&cfg.daemon, "daemon", false, "run in the background, detached from the terminal, with all output appended to --log-file")
+	fs.StringVar(&cfg.pidfile, "pidfile", "", "file to write the process ID of the autoreloader to; another autoreloader is refused while the process is running")
+	fs.StringVar(&cfg.socket, "socket", "", "control socket that the status, stop and restart subcommands connect to; by default, it is next to the pidfile, if any")
+	fs.StringVar(&cfg.metricsAddr, "metrics-addr", "", "address, such as :9090, to serve metrics of the restarts, builds and uptime of the command at /metrics on, for Prometheus")
+	fs.StringVar(&cfg.logFile, "log-file", "", "file that the output of the autoreloader and the command is also appended to; with --daemon, output is otherwise discarded")
+	fs.BoolVar(&cfg.logFileOnly, "log-file-only", false, "only write output to --log-file, not to the terminal")
+	fs.Var(&cfg.logMaxSize, "log-max-size", "size, such as 10MB, beyond which --log-file is rotated, or 0 to never rotate it")
+	fs.IntVar(&cfg.logKeep, "log-keep", 3, "number of rotated log files kept")
+	fs.Var(&cfg.color, "color", "when to color the output of the wrapper, the build and the errors of the command: auto, if stdout is a terminal and NO_COLOR is not set, always or never")
+	noColor := fs.Bool("no-color", false, "do not color output; same as --color=never")
+	fs.Var(&cfg.logFormat, "log-format", "format of the wrapper's log: text, or json for a JSON object per line")
+	fs.BoolVar(&cfg.wrapChildOutput, "wrap-child-output", false, "with --log-format=json, wrap each line of output of the command in a JSON object")
+	fs.BoolVar(&cfg.verbose, "verbose", false, "log every watch event, the signals sent and timings")
+	fs.BoolVar(&cfg.quiet, "quiet", false, "only log errors; the output of the command is not affected")
+	fs.DurationVar(&cfg.killTimeout, "kill-timeout", 10*time.Second, "how long to wait for the command to stop before sending SIGKILL")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg.processGroup = !*noProcessGroup
+	if *noColor {
+		cfg.color = colorNever
+	}
+	cfg.command = fs.Args()
+	if cfg.shell != "" {
+		if len(cfg.command) > 0 || cfg.goPkg != "" || cfg.procfile != "" {
+			return nil, usageError(fs, "-c cannot be combined with a command, --go or -f")
+		}
+		if *noProcessGroup {
+			return nil, usageError(fs, "-c cannot be combined with --no-process-group, as the shell is stopped along with its process group")
+		}
+		if len(cfg.watch) == 0 {
+			words, err := splitWords(cfg.shell)
+			if err != nil || len(words) == 0 || !strings.ContainsRune(words[0], filepath.Separator) && !strings.ContainsRune(words[0], '/') {
+				return nil, usageError(fs, "-c requires --watch, unless the first word of the command string is a path to watch")
+			}
+			cfg.watch = append(cfg.watch, words[0])
+		}
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "sh"
+		}
+		cfg.command = []string{shell, "-c", cfg.shell}
+	}
+	if cfg.procfile != "" {
+		if len(cfg.command) > 0 {
+			return nil, usageError(fs, "-f cannot be combined with a command")
+		}
+		if cfg.goPkg != "" || cfg.build != "" || cfg.tty || cfg.daemon || cfg.pidfile != "" || cfg.socket != "" || cfg.logFile != "" || cfg.metricsAddr != "" {
+			return nil, usageError(fs, "-f cannot be combined with --go, --build, --tty, --daemon, --pidfile, --socket, --log-file or --metrics-addr")
+		}
+		var err error
+		if cfg.procfileEntries, err = readProcfile(cfg.procfile); err != nil {
+			return nil, usageError(fs, "invalid -f: %v", err)
+		}
+		if name := os.Getenv(procfileEnv); name != "" {
+			if err := cfg.useProcfileEntry(name); err != nil {
+				return nil, usageError(fs, "invalid -f: %v", err)
+			}
+		}
+	} else if cfg.exitOnFailure {
+		return nil, usageError(fs, "--exit-on-failure requires -f")
+	}
+	if len(cfg.command) == 0 && cfg.goPkg == "" && cfg.procfile == "" {
+		return nil, usageError(fs, "must supply a command to autoreload")
+	}
+
+	if cfg.quiet && cfg.verbose {
+		return nil, usageError(fs, "--quiet and --verbose cannot be combined")
+	}
+	if cfg.wrapChildOutput && cfg.logFormat != formatJSON {
+		return nil, usageError(fs, "--wrap-child-output requires --log-format=json")
+	}
+	if (cfg.stdoutTarget.redirected() || cfg.stderrTarget.redirected()) && cfg.tty {
+		return nil, usageError(fs, "--stdout and --stderr cannot be combined with --tty")
+	}
+	for _, t := range []outputTarget{cfg.stdoutTarget, cfg.stderrTarget} {
+		if !t.redirected() || t == outputNull {
+			continue
+		}
+		f, err := os.OpenFile(string(t), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, usageError(fs, "invalid output file: %v", err)
+		}
+		f.Close()
+	}
+	if cfg.wrapChildOutput && cfg.tty {
+		return nil, usageError(fs, "--wrap-child-output cannot be combined with --tty")
+	}
+	if cfg.backoffBase <= 0 || cfg.backoffMax < cfg.backoffBase {
+		return nil, usageError(fs, "invalid backoff: --backoff must be positive and not exceed --max-backoff")
+	}
+	if cfg.hookTimeout <= 0 {
+		return nil, usageError(fs, "invalid hook timeout %v: must be positive", cfg.hookTimeout)
+	}
+	if cfg.maxAttempts < 0 || cfg.retryInterval <= 0 || cfg.retryMaxWait < 0 {
+		return nil, usageError(fs, "invalid retries: --max-attempts and --retry-max-wait must not be negative and --retry-interval must be positive")
+	}
+	if cfg.delay < 0 {
+		return nil, usageError(fs, "invalid delay %v: must not be negative", cfg.delay)
+	}
+
+	if cfg.init && cfg.daemon {
+		return nil, usageError(fs, "--init cannot be combined with --daemon")
+	}
+	if cfg.daemon && cfg.tty {
+		return nil, usageError(fs, "--daemon cannot be combined with --tty")
+	}
+	if cfg.logFileOnly && cfg.logFile == "" {
+		return nil, usageError(fs, "--log-file-only requires --log-file")
+	}
+	if cfg.maxRestarts < 0 || cfg.restartWindow <= 0 {
+		return nil, usageError(fs, "invalid restart limit: --max-restarts must not be negative and --per must be positive")
+	}
+	if cfg.crashTail < 0 {
+		return nil, usageError(fs, "invalid --crash-tail %d: must not be negative", cfg.crashTail)
+	}
+	if cfg.logKeep < 0 {
+		return nil, usageError(fs, "invalid --log-keep %d: must not be negative", cfg.logKeep)
+	}
+	if cfg.failOnUnready && cfg.waitTCP.addr == "" {
+		return nil, usageError(fs, "--fail-on-unready requires --wait-tcp")
+	}
+	if cfg.overlap && (cfg.tty || cfg.waitPortFree.addr != "") {
+		return nil, usageError(fs, "--overlap cannot be combined with --tty or --wait-port-free")
+	}
+	if cfg.once && (cfg.restartOnExit || cfg.maxRestarts > 0 || cfg.noRestartOnError || cfg.exitOnChange.enabled) {
+		return nil, usageError(fs, "--once cannot be combined with --restart-on-exit, --max-restarts, --no-restart-on-error or --exit-on-change")
+	}
+	if cfg.onceTimeout < 0 || cfg.onceTimeout > 0 && !cfg.once {
+		return nil, usageError(fs, "invalid --once-timeout %v: must not be negative and requires --once", cfg.onceTimeout)
+	}
+	if cfg.failOnPortBusy && cfg.waitPortFree.addr == "" {
+		return nil, usageError(fs, "--fail-on-port-busy requires --wait-port-free")
+	}
+
+	if cfg.signalOnly != 0 && (cfg.build != "" || cfg.goPkg != "") {
+		return nil, usageError(fs, "--signal-only cannot be combined with --build or --go")
+	}
+	if cfg.goPkg != "" && cfg.build != "" {
+		return nil, usageError(fs, "--go cannot be combined with --build")
+	}
+	if cfg.build != "" && len(cfg.watch) == 0 {
+		return nil, usageError(fs, "--build requires --watch, since the built executable is not watched")
+	}
+
+	for _, header := range cfg.webhookHeaders {
+		if i := strings.IndexByte(header, '='); i <= 0 {
+			return nil, usageError(fs, "invalid --webhook-header %q: must be KEY=VALUE", header)
+		}
+	}
+	if len(cfg.webhookHeaders) > 0 && cfg.webhook == "" {
+		return nil, usageError(fs, "--webhook-header requires --webhook")
+	}
+	for _, kv := range cfg.env {
+		if !strings.Contains(kv, "=") || !validEnvKey(envKey(kv)) {
+			return nil, usageError(fs, "invalid --env %q: must be KEY=VALUE", kv)
+		}
+	}
+	for _, path := range cfg.envFiles {
+		if _, err := readEnvFile(path); err != nil {
+			return nil, usageError(fs, "invalid --env-file: %v", err)
+		}
+	}
+
+	if cfg.chdir != "" {
+		if info, err := os.Stat(cfg.chdir); err != nil || !info.IsDir() {
+			return nil, usageError(fs, "invalid --chdir %q: not a directory", cfg.chdir)
+		}
+	}
+
+	var missing []string
+	for _, path := range cfg.watch {
+		if _, err := os.Stat(path); err != nil {
+			missing = append(missing, path)
+		}
+	}
+	for _, rule := range cfg.signalRules {
+		if _, err := os.Stat(rule.path); err != nil {
+			missing = append(missing, rule.path)
+		}
+	}
+	for _, path := range cfg.triggers {
+		if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+			missing = append(missing, filepath.Dir(path))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, usageError(fs, "watched paths do not exist: %s", strings.Join(missing, ", "))
+	}
+	return cfg, nil
+}
+
+// logLevel returns the level of the wrapper's logger.
+func (cfg *config) logLevel() logLevel {
+	switch {
+	case cfg.quiet:
+		return levelQuiet
+	case cfg.verbose:
+		return levelVerbose
+	default:
+		return levelDefault
+	}
+}
+
+// usageError reports the error along with the usage and returns it.
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	fmt.Fprintln(fs.Output(), err)
+	fs.Usage()
+	return err
+}
+
+// stringsValue is a flag.Value that collects every value of a repeated
+// flag.
+type stringsValue []string
+
+func (v *stringsValue) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *stringsValue) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+// extsValue is a flag.Value that collects comma-separated file
+// extensions, each with a leading dot.
+type extsValue []string
+
+func (v *extsValue) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *extsValue) Set(s string) error {
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.TrimSpace(ext)
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		*v = append(*v, ext)
+	}
+	return nil
+}
+
+// pollValue is a flag.Value for the poll interval that may also be given
+// without a value, for the default interval.
+type pollValue time.Duration
+
+// defaultPollInterval is the poll interval when none is given.
+const defaultPollInterval = 500 * time.Millisecond
+
+func (v *pollValue) String() string {
+	if *v == 0 {
+		return ""
+	}
+	return time.Duration(*v).String()
+}
+
+func (v *pollValue) Set(s string) error {
+	switch s {
+	case "true":
+		*v = pollValue(defaultPollInterval)
+	case "false":
+		*v = 0
+	default:
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("must be a positive duration")
+		}
+		*v = pollValue(d)
+	}
+	return nil
+}
+
+func (v *pollValue) IsBoolFlag() bool { return true }
+
+func mustParseConfig() *config {
+	cfg, err := parseConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	return cfg
+}
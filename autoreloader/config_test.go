@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestParseConfigCommand checks that flags are only parsed up to the
+// command or --, and that everything after is passed to the command
+// unchanged.
+func TestParseConfigCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command []string
+		delay   time.Duration
+		verbose bool
+	}{
+		{[]string{"./server", "--port", "8000"}, []string{"./server", "--port", "8000"}, defaultDelay, false},
+		{[]string{"./server", "--verbose"}, []string{"./server", "--verbose"}, defaultDelay, false},
+		{[]string{"--delay=1s", "./server", "--help"}, []string{"./server", "--help"}, time.Second, false},
+		{[]string{"--verbose", "--", "./server", "--help"}, []string{"./server", "--help"}, defaultDelay, true},
+		{[]string{"--", "./server", "--", "-delay=1s"}, []string{"./server", "--", "-delay=1s"}, defaultDelay, false},
+	}
+	for _, tt := range tests {
+		cfg, err := parseConfig(tt.args)
+		if err != nil {
+			t.Errorf("parsing %q: %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(cfg.command, tt.command) {
+			t.Errorf("parsing %q: command = %q, want %q", tt.args, cfg.command, tt.command)
+		}
+		if cfg.delay != tt.delay || cfg.verbose != tt.verbose {
+			t.Errorf("parsing %q: delay = %v, verbose = %v, want %v, %v", tt.args, cfg.delay, cfg.verbose, tt.delay, tt.verbose)
+		}
+	}
+}
+
+// TestParseConfigGoArgs checks that with --go, the arguments after --
+// are those of the built command.
+func TestParseConfigGoArgs(t *testing.T) {
+	cfg, err := parseConfig([]string{"--go", "./cmd/server", "--", "--help"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.goPkg != "./cmd/server" || !reflect.DeepEqual(cfg.command, []string{"--help"}) {
+		t.Errorf("package = %s, arguments = %q, want ./cmd/server, [--help]", cfg.goPkg, cfg.command)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// console writes the output of the wrapper, and that of the command, the
+// build and the hooks, to the terminal, and to the log file of --log-file,
+// one write at a time from a single goroutine, so that no line is
+// interleaved with another mid-way. When a source leaves its line
+// incomplete, such as a prompt, and another source writes, the line is
+// ended first.
+type console struct {
+	writes chan consoleWrite
+
+	mu          sync.Mutex
+	logFile     io.Writer
+	logFileOnly bool
+}
+
+// consoleWrite is a write to the console, which is done once written.
+type consoleWrite struct {
+	stream *consoleStream
+	b      []byte
+	done   chan struct{}
+}
+
+// consoleStream is a source of output of the console, written to the
+// terminal by f.
+type consoleStream struct {
+	c *console
+	f *os.File
+}
+
+var stdio = newConsole()
+
+// stdout and stderr are where the wrapper writes its log and the output
+// of the build and the hooks. The output of the command is written to
+// sources of their own, so that the line of one is ended before the
+// other writes.
+var stdout, stderr = stdio.stream(os.Stdout), stdio.stream(os.Stderr)
+
+func newConsole() *console {
+	c := &console{writes: make(chan consoleWrite)}
+	go c.run()
+	return c
+}
+
+// stream returns a new source of output written to the terminal by f.
+func (c *console) stream(f *os.File) *consoleStream {
+	return &consoleStream{c: c, f: f}
+}
+
+// setLogFile also writes the output to the log file, or only to it.
+func (c *console) setLogFile(w io.Writer, only bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logFile, c.logFileOnly = w, only
+}
+
+func (c *console) run() {
+	var last *consoleStream
+	midLine := false
+	for w := range c.writes {
+		if midLine && w.stream != last {
+			c.write(last.f, []byte("\n"))
+		}
+		c.write(w.stream.f, w.b)
+		midLine = w.b[len(w.b)-1] != '\n'
+		last = w.stream
+		close(w.done)
+	}
+}
+
+func (c *console) write(f *os.File, b []byte) {
+	c.mu.Lock()
+	logFile, only := c.logFile, c.logFileOnly
+	c.mu.Unlock()
+	if !only {
+		f.Write(b)
+	}
+	if logFile != nil {
+		logFile.Write(b)
+	}
+}
+
+// source returns a new source of output written to the same terminal
+// stream.
+func (s *consoleStream) source() *consoleStream {
+	return s.c.stream(s.f)
+}
+
+// Write writes b once the writes before it are written. Errors of the
+// terminal and the log file are ignored, as there is nowhere to report
+// them.
+func (s *consoleStream) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	done := make(chan struct{})
+	s.c.writes <- consoleWrite{stream: s, b: b, done: done}
+	<-done
+	return len(b), nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestConsoleEndsIncompleteLine checks that the incomplete line of one
+// source is ended before another source writes.
+func TestConsoleEndsIncompleteLine(t *testing.T) {
+	var out bytes.Buffer
+	c := newConsole()
+	c.setLogFile(&out, true)
+	a, b := c.stream(os.Stdout), c.stream(os.Stdout)
+
+	a.Write([]byte("prompt> "))
+	b.Write([]byte("line\n"))
+	a.Write([]byte("answer\n"))
+
+	if want := "prompt> \nline\nanswer\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+// TestConsoleConcurrentWriters checks that no line of one source is
+// interleaved with that of another, however many write at once.
+func TestConsoleConcurrentWriters(t *testing.T) {
+	const (
+		sources = 8
+		lines   = 200
+	)
+	var out bytes.Buffer
+	c := newConsole()
+	c.setLogFile(&out, true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < sources; i++ {
+		s := c.stream(os.Stdout)
+		letter := string(rune('a' + i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				// Write whole lines and lines in pieces alike.
+				if j%2 == 0 {
+					s.Write([]byte(strings.Repeat(letter, 16) + "\n"))
+				} else {
+					s.Write([]byte(strings.Repeat(letter, 8)))
+					s.Write([]byte(strings.Repeat(letter, 8) + "\n"))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	counts := map[byte]int{}
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if line == "" || strings.Trim(line, line[:1]) != "" {
+			t.Fatalf("line %q mixes the output of several sources", line)
+		}
+		if len(line) != 8 && len(line) != 16 {
+			t.Fatalf("line %q was split mid-write", line)
+		}
+		counts[line[0]] += len(line)
+	}
+	for i := 0; i < sources; i++ {
+		if got, want := counts[byte('a'+i)], lines*16; got != want {
+			t.Errorf("source %c wrote %d bytes, want %d", 'a'+i, got, want)
+		}
+	}
+}
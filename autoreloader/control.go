@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// controlTimeout is how long a connection to the control socket may take
+// to send its request.
+const controlTimeout = 5 * time.Second
+
+// controlStatus is the status of the wrapper reported over the control
+// socket.
+type controlStatus struct {
+	PID               int        `json:"pid"`
+	Running           bool       `json:"running"`
+	StartTime         time.Time  `json:"start_time"`
+	Uptime            string     `json:"uptime"`
+	Generation        int        `json:"generation"`
+	LastRestartReason string     `json:"last_restart_reason,omitempty"`
+	LastRestartTime   *time.Time `json:"last_restart_time,omitempty"`
+	WatchPaths        []string   `json:"watch_paths"`
+
+	// The restarts of the command, counted across restarts of the wrapper
+	// with --pidfile, and the uptime of the process the last one replaced.
+	Restarts       int    `json:"restarts"`
+	PreviousUptime string `json:"previous_uptime,omitempty"`
+
+	// The signals sent to the command for changes, rather than
+	// restarting it.
+	Signals          int        `json:"signals"`
+	LastSignal       string     `json:"last_signal,omitempty"`
+	LastSignalReason string     `json:"last_signal_reason,omitempty"`
+	LastSignalTime   *time.Time `json:"last_signal_time,omitempty"`
+
+	// The restarts caused by changes within the window of --per, and
+	// until when they are paused once there are more than --max-restarts.
+	RecentRestarts int        `json:"recent_restarts"`
+	MaxRestarts    int        `json:"max_restarts,omitempty"`
+	RestartWindow  string     `json:"restart_window,omitempty"`
+	ThrottledUntil *time.Time `json:"throttled_until,omitempty"`
+}
+
+// controlRequest is a request over the control socket that the
+// supervisor acts on, such as to restart the command.
+type controlRequest struct {
+	command string
+	reply   chan controlReply
+}
+
+// controlReply is the answer of the supervisor to a controlRequest.
+type controlReply struct {
+	Generation int    `json:"generation,omitempty"`
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// statusBoard holds what the control socket reports about the command,
+// so that it can answer without involving the supervisor.
+type statusBoard struct {
+	mu         sync.Mutex
+	proc       *process
+	reason     string
+	restarted  time.Time
+	watchPaths []string
+
+	restarts       int
+	previousUptime time.Duration
+
+	// The metrics of the restarts, builds and readiness of the command
+	// since the wrapper started.
+	restartCauses   map[string]int
+	buildsSucceeded int
+	buildsFailed    int
+	buildTime       time.Duration
+	readies         int
+	readyTime       time.Duration
+
+	signals      int
+	lastSignal   syscall.Signal
+	signalReason string
+	signalTime   time.Time
+
+	recentRestarts int
+	maxRestarts    int
+	restartWindow  time.Duration
+	throttledUntil time.Time
+}
+
+// started records that the command was started, for the reason if it was
+// restarted.
+func (b *statusBoard) started(proc *process, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.proc = proc
+	if proc.generation > 1 {
+		b.reason, b.restarted = reason, proc.started
+	}
+}
+
+// counted records a restart for the cause, the number of restarts, and
+// the uptime of the process that the restart replaced.
+func (b *statusBoard) counted(cause string, restarts int, previousUptime time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.restartCauses == nil {
+		b.restartCauses = map[string]int{}
+	}
+	b.restartCauses[cause]++
+	b.restarts, b.previousUptime = restarts, previousUptime
+}
+
+// built records a build that took the duration.
+func (b *statusBoard) built(ok bool, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ok {
+		b.buildsSucceeded++
+	} else {
+		b.buildsFailed++
+	}
+	b.buildTime += d
+}
+
+// readied records how long a restarted process took to become ready.
+func (b *statusBoard) readied(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.readies++
+	b.readyTime += d
+}
+
+// signaled records that the signal was sent to the command for the
+// reason, rather than restarting it.
+func (b *statusBoard) signaled(sig syscall.Signal, reason string, at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.signals++
+	b.lastSignal, b.signalReason, b.signalTime = sig, reason, at
+}
+
+// throttled records the number of recent restarts, and until when
+// restarts are paused, if they are.
+func (b *statusBoard) throttled(recent int, until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recentRestarts, b.throttledUntil = recent, until
+}
+
+// status returns the status of the command.
+func (b *statusBoard) status() controlStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := controlStatus{WatchPaths: b.watchPaths, LastRestartReason: b.reason, Restarts: b.restarts, RecentRestarts: b.recentRestarts}
+	if b.previousUptime > 0 {
+		st.PreviousUptime = round(b.previousUptime).String()
+	}
+	if !b.restarted.IsZero() {
+		t := b.restarted
+		st.LastRestartTime = &t
+	}
+	if b.signals > 0 {
+		t := b.signalTime
+		st.Signals, st.LastSignal, st.LastSignalReason, st.LastSignalTime = b.signals, "SIG"+signalName(b.lastSignal), b.signalReason, &t
+	}
+	if b.maxRestarts > 0 {
+		st.MaxRestarts, st.RestartWindow = b.maxRestarts, b.restartWindow.String()
+	}
+	if !b.throttledUntil.IsZero() {
+		t := b.throttledUntil
+		st.ThrottledUntil = &t
+	}
+	if b.proc == nil {
+		return st
+	}
+	st.PID = b.proc.pid()
+	st.StartTime = b.proc.started
+	st.Generation = b.proc.generation
+	select {
+	case <-b.proc.done:
+		st.Uptime = b.proc.uptime().Round(time.Second).String()
+	default:
+		st.Running = true
+		st.Uptime = time.Since(b.proc.started).Round(time.Second).String()
+	}
+	return st
+}
+
+// controlServer answers requests on the control socket. Status requests
+// are answered from the board, and others are passed to the supervisor.
+type controlServer struct {
+	ln       net.Listener
+	path     string
+	board    *statusBoard
+	requests chan<- controlRequest
+	handlers sync.WaitGroup
+	done     chan struct{}
+}
+
+// serveControl listens on the control socket at path. A socket left
+// behind by a wrapper that is no longer running is replaced.
+func serveControl(path string, board *statusBoard, requests chan<- controlRequest) (*controlServer, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another autoreloader is listening on %s", path)
+	}
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on control socket: %w", err)
+	}
+	c := &controlServer{ln: ln, path: path, board: board, requests: requests, done: make(chan struct{})}
+	go c.serve()
+	return c, nil
+}
+
+func (c *controlServer) serve() {
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			return
+		}
+		c.handlers.Add(1)
+		go func() {
+			defer c.handlers.Done()
+			c.handle(conn)
+		}()
+	}
+}
+
+// handle answers the request of a connection, which is a command on a
+// line of its own, with a line of JSON.
+func (c *controlServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(controlTimeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	switch command := strings.TrimSpace(line); command {
+	case "status":
+		writeJSONLine(conn, c.board.status())
+	case "restart", "stop":
+		writeJSONLine(conn, c.request(command))
+	default:
+		writeJSONLine(conn, controlReply{Error: fmt.Sprintf("unknown command %q", command)})
+	}
+}
+
+// request passes the command to the supervisor and waits for its reply.
+// The supervisor always replies once it received the request.
+func (c *controlServer) request(command string) controlReply {
+	req := controlRequest{command: command, reply: make(chan controlReply, 1)}
+	timer := time.NewTimer(controlTimeout)
+	defer timer.Stop()
+	select {
+	case c.requests <- req:
+	case <-timer.C:
+		return controlReply{Error: "busy; try again"}
+	case <-c.done:
+		return controlReply{Error: "stopping"}
+	}
+	return <-req.reply
+}
+
+// close stops listening, waits for the requests being answered and
+// removes the socket.
+func (c *controlServer) close() {
+	c.ln.Close()
+	close(c.done)
+	c.handlers.Wait()
+	os.Remove(c.path)
+}
+
+// controlSocket returns the path of the control socket: that of --socket,
+// or one next to the pidfile. It is empty if neither is given.
+func (cfg *config) controlSocket() string {
+	switch {
+	case cfg.socket != "":
+		return cfg.socket
+	case cfg.pidfile != "":
+		return strings.TrimSuffix(cfg.pidfile, ".pid") + ".sock"
+	default:
+		return ""
+	}
+}
+
+// requestControl sends the command to the wrapper listening on the
+// control socket and decodes its answer into v.
+func requestControl(path, command string, timeout time.Duration, v interface{}) error {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return fmt.Errorf("autoreloader is not running: cannot connect to %s", path)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := fmt.Fprintln(conn, command); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("autoreloader did not answer within %v: %w", timeout, err)
+	}
+	var failure struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(line, &failure) == nil && failure.Error != "" {
+		return fmt.Errorf("autoreloader: %s", failure.Error)
+	}
+	return json.Unmarshal(line, v)
+}
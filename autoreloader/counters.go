@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// counters are the counters of the wrapper that persist across its
+// restarts, such as those of a daemon, in a file next to the pidfile.
+// They are reset when the command differs.
+type counters struct {
+	Command  []string `json:"command"`
+	Restarts int      `json:"restarts"`
+}
+
+// countersFile returns the path of the file that the counters persist
+// in, or "" if they do not persist.
+func (cfg *config) countersFile() string {
+	if cfg.pidfile == "" {
+		return ""
+	}
+	return strings.TrimSuffix(cfg.pidfile, ".pid") + ".state"
+}
+
+// loadCounters returns the counters persisted by a previous run of the
+// wrapper with the same command, if any.
+func loadCounters(cfg *config) counters {
+	c := counters{Command: cfg.command}
+	path := cfg.countersFile()
+	if path == "" {
+		return c
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var saved counters
+	if err := json.Unmarshal(b, &saved); err != nil || !reflect.DeepEqual(saved.Command, cfg.command) {
+		return c
+	}
+	return saved
+}
+
+// save persists the counters, if they persist.
+func (c counters) save(cfg *config) {
+	path := cfg.countersFile()
+	if path == "" {
+		return
+	}
+	b, err := json.Marshal(c)
+	if err == nil {
+		tmp := path + ".tmp"
+		if err = ioutil.WriteFile(tmp, b, 0644); err == nil {
+			err = os.Rename(tmp, path)
+		}
+	}
+	if err != nil {
+		logger.debugf("Failed to save the counters: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// daemonEnv marks the wrapper that --daemon re-executed in the
+// background.
+const daemonEnv = "AUTORELOADER_DAEMON"
+
+// daemonized reports whether the wrapper runs in the background.
+func daemonized() bool {
+	return os.Getenv(daemonEnv) != ""
+}
+
+// daemonize re-executes the wrapper in the background, detached from the
+// terminal in a new session, and exits. In the background, output is
+// only written to the log file, if any.
+func daemonize(cfg *config) {
+	if pid, running := runningPid(cfg.pidfile); running {
+		log.Fatalf("autoreloader is already running as process %d, according to %s", pid, cfg.pidfile)
+	}
+
+	out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		log.Fatalf("Cannot open %s: %v", os.DevNull, err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Cannot find the autoreloader executable: %v", err)
+	}
+
+	cmd := exec.Command(self, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.Stdout = out
+	cmd.Stderr = out
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		log.Fatalf("Cannot start autoreloader in the background: %v", err)
+	}
+	fmt.Printf("autoreloader running in the background as process %d\n", cmd.Process.Pid)
+	os.Exit(0)
+}
+
+// runningPid returns the process ID in the pidfile and whether that
+// process is running. A pidfile left behind by a process that is no
+// longer running is stale.
+func runningPid(pidfile string) (int, bool) {
+	if pidfile == "" {
+		return 0, false
+	}
+	b, err := ioutil.ReadFile(pidfile)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, processRunning(pid)
+}
+
+// writePidfile writes the process ID of the wrapper to the pidfile,
+// replacing a stale one. It fails if another wrapper is running.
+func writePidfile(pidfile string) error {
+	if pid, running := runningPid(pidfile); running {
+		return fmt.Errorf("autoreloader is already running as process %d, according to %s", pid, pidfile)
+	}
+	os.Remove(pidfile)
+	f, err := os.OpenFile(pidfile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot write pidfile: %w", err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+	return err
+}
+
+// removePidfile removes the pidfile if it still holds the process ID of
+// the wrapper.
+func removePidfile(pidfile string) {
+	if pid, _ := runningPid(pidfile); pid == os.Getpid() {
+		os.Remove(pidfile)
+	}
+}
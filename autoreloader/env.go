@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// environ returns the environment of the command: the environment of
+// the wrapper, overridden by the env files and then by the --env
+// variables. The env files are read on every call, so that changes to
+// them apply to the next process. With --expand-env, the variables in
+// the --env values are expanded from the environment they override.
+func (cfg *config) environ() ([]string, error) {
+	env := os.Environ()
+	for _, path := range cfg.envFiles {
+		vars, err := readEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		env = mergeEnv(env, vars)
+	}
+	vars := cfg.env
+	if cfg.expandEnv != expandOff {
+		vars = make([]string, len(cfg.env))
+		for i, kv := range cfg.env {
+			value, err := cfg.expand(kv[len(envKey(kv))+1:], env)
+			if err != nil {
+				return nil, fmt.Errorf("--env %s: %v", envKey(kv), err)
+			}
+			vars[i] = envKey(kv) + "=" + value
+		}
+	}
+	return mergeEnv(env, vars), nil
+}
+
+// args returns the arguments of the command, with their variables
+// expanded from the environment with --expand-env.
+func (cfg *config) args(env []string) ([]string, error) {
+	args := cfg.command[1:]
+	if cfg.expandEnv == expandOff {
+		return args, nil
+	}
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		var err error
+		if expanded[i], err = cfg.expand(arg, env); err != nil {
+			return nil, fmt.Errorf("argument %q: %v", arg, err)
+		}
+	}
+	return expanded, nil
+}
+
+// expandMode defines whether variables are expanded, with --expand-env.
+type expandMode int
+
+const (
+	// expandOff leaves variables as they are.
+	expandOff expandMode = iota
+
+	// expandWarn expands variables, warning about those that are
+	// unset, which expand to nothing.
+	expandWarn
+
+	// expandStrict expands variables, failing for those that are
+	// unset.
+	expandStrict
+)
+
+func (m *expandMode) String() string {
+	switch *m {
+	case expandWarn:
+		return "true"
+	case expandStrict:
+		return "strict"
+	default:
+		return "false"
+	}
+}
+
+func (m *expandMode) Set(s string) error {
+	switch s {
+	case "true", "warn":
+		*m = expandWarn
+	case "strict":
+		*m = expandStrict
+	case "false":
+		*m = expandOff
+	default:
+		return fmt.Errorf("must be true, warn, strict or false")
+	}
+	return nil
+}
+
+func (m *expandMode) IsBoolFlag() bool { return true }
+
+// expand expands $VAR and ${VAR} in s from the environment, and $$ to a
+// literal $, with --expand-env.
+func (cfg *config) expand(s string, env []string) (string, error) {
+	if cfg.expandEnv == expandOff {
+		return s, nil
+	}
+	var unset []string
+	expanded := os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		for i := len(env) - 1; i >= 0; i-- {
+			if envKey(env[i]) == name {
+				return env[i][len(name)+1:]
+			}
+		}
+		unset = append(unset, name)
+		return ""
+	})
+	if len(unset) == 0 {
+		return expanded, nil
+	}
+	if cfg.expandEnv == expandStrict {
+		return "", fmt.Errorf("%s not set", strings.Join(unset, ", "))
+	}
+	logger.infof("WARNING: %s not set; expanding %q to %q", strings.Join(unset, ", "), s, expanded)
+	return expanded, nil
+}
+
+// mergeEnv returns env with the variables set, replacing any existing
+// values.
+func mergeEnv(env []string, vars []string) []string {
+	if len(vars) == 0 {
+		return env
+	}
+	set := map[string]bool{}
+	for _, kv := range vars {
+		set[envKey(kv)] = true
+	}
+	merged := make([]string, 0, len(env)+len(vars))
+	for _, kv := range env {
+		if !set[envKey(kv)] {
+			merged = append(merged, kv)
+		}
+	}
+	return append(merged, vars...)
+}
+
+func envKey(kv string) string {
+	if i := strings.IndexByte(kv, '='); i >= 0 {
+		return kv[:i]
+	}
+	return kv
+}
+
+// readEnvFile reads a dotenv file of KEY=VALUE lines. Blank lines,
+// comments starting with # and an export prefix are permitted. Values
+// may be double quoted, allowing escapes, or single quoted, taken
+// literally; only a comment may follow the closing quote.
+func readEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var vars []string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		kv, ok, err := parseEnvLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if ok {
+			vars = append(vars, kv)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return vars, nil
+}
+
+// parseEnvLine parses a line of a dotenv file. It reports false for a
+// blank or comment line.
+func parseEnvLine(line string) (string, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", false, nil
+	}
+	line = strings.TrimPrefix(line, "export ")
+
+	i := strings.IndexByte(line, '=')
+	if i < 0 {
+		return "", false, fmt.Errorf("expected KEY=VALUE")
+	}
+	key := strings.TrimSpace(line[:i])
+	if !validEnvKey(key) {
+		return "", false, fmt.Errorf("invalid variable name %q", key)
+	}
+	value, err := parseEnvValue(strings.TrimSpace(line[i+1:]))
+	if err != nil {
+		return "", false, err
+	}
+	return key + "=" + value, true, nil
+}
+
+func parseEnvValue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	switch s[0] {
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quote")
+		}
+		if err := checkAfterQuote(s[end+2:]); err != nil {
+			return "", err
+		}
+		return s[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; c {
+			case '"':
+				if err := checkAfterQuote(s[i+1:]); err != nil {
+					return "", err
+				}
+				return b.String(), nil
+			case '\\':
+				if i+1 == len(s) {
+					return "", fmt.Errorf("unterminated double quote")
+				}
+				i++
+				switch s[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(s[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double quote")
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// checkAfterQuote checks that nothing but a comment follows the closing
+// quote of a value.
+func checkAfterQuote(rest string) error {
+	trimmed := strings.TrimLeft(rest, " \t")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") && len(trimmed) < len(rest) {
+		return nil
+	}
+	return fmt.Errorf("unexpected %q after closing quote", rest)
+}
+
+func validEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		ok   bool
+		err  string
+	}{
+		{line: ""},
+		{line: "   "},
+		{line: "# comment"},
+		{line: "KEY=value", want: "KEY=value", ok: true},
+		{line: "  KEY = value  ", want: "KEY=value", ok: true},
+		{line: "export KEY=value", want: "KEY=value", ok: true},
+		{line: "KEY=", want: "KEY=", ok: true},
+		{line: "KEY=a=b", want: "KEY=a=b", ok: true},
+		{line: "KEY=value # comment", want: "KEY=value", ok: true},
+		{line: "KEY=value#hash", want: "KEY=value#hash", ok: true},
+		{line: "KEY='single $quoted \\n'", want: "KEY=single $quoted \\n", ok: true},
+		{line: "KEY='a # b' # comment", want: "KEY=a # b", ok: true},
+		{line: `KEY="double \"quoted\"\n\ttab\\"`, want: "KEY=double \"quoted\"\n\ttab\\", ok: true},
+		{line: `KEY="a # b" # comment`, want: "KEY=a # b", ok: true},
+		{line: "A.B_1=x", want: "A.B_1=x", ok: true},
+		{line: "KEY='a'b", err: `unexpected "b" after closing quote`},
+		{line: `KEY="a"b`, err: `unexpected "b" after closing quote`},
+		{line: "KEY='a'#b", err: `unexpected "#b" after closing quote`},
+		{line: "KEY='unterminated", err: "unterminated single quote"},
+		{line: `KEY="unterminated`, err: "unterminated double quote"},
+		{line: `KEY="escaped end\"`, err: "unterminated double quote"},
+		{line: `KEY="trailing \`, err: "unterminated double quote"},
+		{line: "KEY", err: "expected KEY=VALUE"},
+		{line: "=value", err: `invalid variable name ""`},
+		{line: "1KEY=value", err: `invalid variable name "1KEY"`},
+		{line: "MY-KEY=value", err: `invalid variable name "MY-KEY"`},
+	}
+	for _, tt := range tests {
+		got, ok, err := parseEnvLine(tt.line)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("parseEnvLine(%q) error = %v, want %s", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want || ok != tt.ok {
+			t.Errorf("parseEnvLine(%q) = %q, %v, %v, want %q, %v", tt.line, got, ok, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := "# settings\nexport A=1\n\nB='two'\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vars, err := readEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(vars, " "); got != "A=1 B=two" {
+		t.Errorf("vars = %s, want A=1 B=two", got)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(content+"C='x'y\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEnvFile(path); err == nil || !strings.Contains(err.Error(), path+":5:") {
+		t.Errorf("error = %v, want one for line 5", err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	env := []string{"HOME=/home/gopher", "PORT=8080", "PORT=9090"}
+	tests := []struct {
+		mode expandMode
+		s    string
+		want string
+		err  string
+		warn bool
+	}{
+		{mode: expandOff, s: "$HOME/$UNSET", want: "$HOME/$UNSET"},
+		{mode: expandWarn, s: "$HOME:${PORT}", want: "/home/gopher:9090"},
+		{mode: expandWarn, s: "$$HOME costs $$5", want: "$HOME costs $5"},
+		{mode: expandWarn, s: "a${UNSET}b", want: "ab", warn: true},
+		{mode: expandStrict, s: "$HOME", want: "/home/gopher"},
+		{mode: expandStrict, s: "$$UNSET", want: "$UNSET"},
+		{mode: expandStrict, s: "$UNSET:$OTHER", err: "UNSET, OTHER not set"},
+	}
+	for _, tt := range tests {
+		logs := captureLog(t)
+		cfg := &config{expandEnv: tt.mode}
+		got, err := cfg.expand(tt.s, env)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("expand(%q) with %s error = %v, want %s", tt.s, tt.mode.String(), err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("expand(%q) with %s = %q, %v, want %q", tt.s, tt.mode.String(), got, err, tt.want)
+		}
+		if warned := strings.Contains(logs.String(), "WARNING: UNSET not set"); warned != tt.warn {
+			t.Errorf("expand(%q) with %s warned: %v, want %v", tt.s, tt.mode.String(), warned, tt.warn)
+		}
+	}
+}
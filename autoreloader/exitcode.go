@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// exitPolicy decides the exit code of the wrapper.
+type exitPolicy struct {
+	// zeroOnSignal exits with 0 when the wrapper was stopped by a
+	// signal.
+	zeroOnSignal bool
+
+	// fixed, if set, is always the exit code.
+	fixed *int
+}
+
+// code returns the exit code of the wrapper, given the exit code of the
+// last process and whether the wrapper was stopped by a signal.
+func (p exitPolicy) code(child int, signaled bool) int {
+	switch {
+	case p.fixed != nil:
+		return *p.fixed
+	case p.zeroOnSignal && signaled:
+		return 0
+	default:
+		return child
+	}
+}
+
+func (p *exitPolicy) String() string {
+	switch {
+	case p.fixed != nil:
+		return strconv.Itoa(*p.fixed)
+	case p.zeroOnSignal:
+		return "zero-on-signal"
+	default:
+		return "child"
+	}
+}
+
+func (p *exitPolicy) Set(s string) error {
+	switch s {
+	case "child":
+		*p = exitPolicy{}
+	case "zero-on-signal":
+		*p = exitPolicy{zeroOnSignal: true}
+	default:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > 255 {
+			return fmt.Errorf("must be child, zero-on-signal or a code from 0 to 255")
+		}
+		*p = exitPolicy{fixed: &n}
+	}
+	return nil
+}
+
+// exitOnChange is a flag.Value that, once set, makes the wrapper exit
+// with the code, 0 by default, rather than restart the command on a
+// change.
+type exitOnChange struct {
+	enabled bool
+	code    int
+}
+
+func (e *exitOnChange) IsBoolFlag() bool { return true }
+
+func (e *exitOnChange) String() string {
+	if !e.enabled {
+		return "false"
+	}
+	return strconv.Itoa(e.code)
+}
+
+func (e *exitOnChange) Set(s string) error {
+	switch s {
+	case "true":
+		*e = exitOnChange{enabled: true}
+	case "false":
+		*e = exitOnChange{}
+	default:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > 255 {
+			return fmt.Errorf("must be a code from 0 to 255")
+		}
+		*e = exitOnChange{enabled: true, code: n}
+	}
+	return nil
+}
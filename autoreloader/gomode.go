@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// goPackage is the part of the output of go list used in --go mode.
+type goPackage struct {
+	Name       string
+	Dir        string
+	EmbedFiles []string
+	Module     *struct {
+		Main  bool
+		Dir   string
+		GoMod string
+	}
+}
+
+// setupGoMode configures the wrapper to build the main package of --go
+// into a temporary directory, which it returns, and run the result with
+// the command line as its arguments. The main modules of the package and
+// its dependencies are watched for changes to their Go sources, go.mod
+// and go.sum, along with the files embedded into their packages.
+func setupGoMode(cfg *config) (string, error) {
+	pkg := cfg.goPkg
+	if pkg == "." || pkg == ".." || strings.HasPrefix(pkg, "./") || strings.HasPrefix(pkg, "../") {
+		// The build runs in the directory of --chdir.
+		abs, err := filepath.Abs(pkg)
+		if err != nil {
+			return "", err
+		}
+		pkg = abs
+	}
+	pkgs, err := listGoPackages(pkg)
+	if err != nil {
+		return "", err
+	}
+
+	modules := map[string]bool{}
+	exts := map[string]bool{}
+	for _, p := range pkgs {
+		if p.Module == nil || !p.Module.Main {
+			continue
+		}
+		if !modules[p.Module.Dir] {
+			modules[p.Module.Dir] = true
+			cfg.watch = append(cfg.watch, p.Module.Dir, p.Module.GoMod, filepath.Join(p.Module.Dir, "go.sum"))
+		}
+		for _, file := range p.EmbedFiles {
+			cfg.watch = append(cfg.watch, filepath.Join(p.Dir, file))
+			// Also notice new files that the embed patterns may match.
+			if ext := filepath.Ext(file); ext != "" && !exts[ext] {
+				exts[ext] = true
+				cfg.exts = append(cfg.exts, ext)
+			}
+		}
+	}
+	if len(modules) == 0 {
+		return "", fmt.Errorf("--go %s: package is not within a module", cfg.goPkg)
+	}
+	cfg.exts = append(cfg.exts, ".go")
+
+	dir, err := os.MkdirTemp("", "autoreloader-")
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(pkgs[len(pkgs)-1].Dir)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	out := filepath.Join(dir, name)
+	cfg.build = fmt.Sprintf("go build -o %s %s", shellQuote(out), shellQuote(pkg))
+	cfg.command = append([]string{out}, cfg.command...)
+	return dir, nil
+}
+
+// listGoPackages lists the package and its dependencies, with the
+// package last.
+func listGoPackages(pkg string) ([]goPackage, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "list", "-deps", "-json", pkg)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("--go %s: go list failed: %v\n%s", pkg, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	var pkgs []goPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p goPackage
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("--go %s: failed to parse the output of go list: %v", pkg, err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	if len(pkgs) == 0 || pkgs[len(pkgs)-1].Name != "main" {
+		return nil, fmt.Errorf("--go %s: not a main package", pkg)
+	}
+	return pkgs, nil
+}
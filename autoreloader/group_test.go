@@ -0,0 +1,93 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// spawner returns a command that starts a grandchild, whose pid it
+// writes to the file, and then waits for it.
+func spawner(pidFile string) string {
+	return "#!/bin/sh\nsleep 60 &\necho $! > " + pidFile + "\nwait\n"
+}
+
+// grandchild waits for the pid written by the spawner.
+func grandchild(t *testing.T, pidFile string) int {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if b, err := ioutil.ReadFile(pidFile); err == nil && strings.HasSuffix(string(b), "\n") {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return pid
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the grandchild to start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// alive reports whether the process is running, counting a zombie,
+// which only waits to be reaped, as exited.
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) == syscall.ESRCH {
+		return false
+	}
+	b, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(b[strings.LastIndexByte(string(b), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+// waitExited waits for the process to exit.
+func waitExited(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("process %d still running", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestStopKillsGrandchild checks that stopping the command also stops
+// the processes it started, through its process group.
+func TestStopKillsGrandchild(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	h := newHarness(t, spawner(pidFile))
+	pid := grandchild(t, pidFile)
+
+	h.stop()
+	waitExited(t, pid)
+}
+
+// TestRestartKillsGrandchild checks that a restart stops the processes
+// started by the previous generation.
+func TestRestartKillsGrandchild(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	h := newHarness(t, spawner(first), "--delay=20ms")
+	pid := grandchild(t, first)
+
+	h.write(spawner(second))
+	h.waitFor("the restart", generation(2))
+	waitExited(t, pid)
+
+	h.stop()
+	waitExited(t, grandchild(t, second))
+}
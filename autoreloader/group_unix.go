@@ -0,0 +1,37 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// jobObject is only used on Windows, where it stands in for the process
+// group of the command.
+type jobObject struct{}
+
+// setProcessGroup makes the command run in its own process group.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// joinGroup is a no-op, as the command starts in its process group.
+func (p *process) joinGroup() error {
+	return nil
+}
+
+// signalGroup sends the signal to the process group of the command.
+func (p *process) signalGroup(sig syscall.Signal) error {
+	return syscall.Kill(-p.pid(), sig)
+}
+
+// killGroup kills the process group of the command, which was started
+// by setProcessGroup.
+func killGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// closeGroup is a no-op, as the process group ends with its processes.
+func (p *process) closeGroup() {}
@@ -0,0 +1,77 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// jobObject is the job object that the command and the processes it
+// starts are assigned to, which stands in for a process group.
+type jobObject struct {
+	handle windows.Handle
+}
+
+// setProcessGroup is a no-op, as the command joins its job object once
+// it has started.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// joinGroup assigns the command to a new job object, which the processes
+// it starts from then on belong to as well. The processes of the job are
+// killed once it is closed, even if the wrapper dies.
+func (p *process) joinGroup() error {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return err
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(job)
+		return err
+	}
+	proc, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(p.pid()))
+	if err != nil {
+		windows.CloseHandle(job)
+		return err
+	}
+	defer windows.CloseHandle(proc)
+	if err := windows.AssignProcessToJobObject(job, proc); err != nil {
+		windows.CloseHandle(job)
+		return err
+	}
+	p.job.handle = job
+	return nil
+}
+
+// signalGroup terminates every process of the job object, as Windows
+// cannot deliver signals.
+func (p *process) signalGroup(sig syscall.Signal) error {
+	if p.job.handle == 0 {
+		return p.cmd.Process.Kill()
+	}
+	return windows.TerminateJobObject(p.job.handle, 1)
+}
+
+// killGroup kills the command. The processes it started are left
+// running, as only the command itself joins a job object.
+func killGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// closeGroup closes the job object, which kills any process left in it.
+func (p *process) closeGroup() {
+	if p.job.handle != 0 {
+		windows.CloseHandle(p.job.handle)
+		p.job.handle = 0
+	}
+}
@@ -0,0 +1,185 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMain keeps the output of the supervisors under test off the
+// terminal, unless the tests are verbose.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	log.SetOutput(stderr)
+	if !testing.Verbose() {
+		stdio.setLogFile(ioutil.Discard, true)
+	}
+	os.Exit(m.Run())
+}
+
+// logBuffer collects the log of the wrapper.
+type logBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (l *logBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *logBuffer) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.String()
+}
+
+// captureLog collects the log of the wrapper for the rest of the test.
+func captureLog(t *testing.T) *logBuffer {
+	l := &logBuffer{}
+	stdio.setLogFile(l, !testing.Verbose())
+	t.Cleanup(func() {
+		if testing.Verbose() {
+			stdio.setLogFile(nil, false)
+		} else {
+			stdio.setLogFile(ioutil.Discard, true)
+		}
+	})
+	return l
+}
+
+// chdir changes the working directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// sleeper is a command that runs until it is stopped.
+const sleeper = "#!/bin/sh\nexec sleep 60\n"
+
+// harness supervises a shell script in a temporary directory.
+type harness struct {
+	t    *testing.T
+	path string
+	s    *supervisor
+	done chan struct{}
+	code int
+}
+
+// newHarness starts supervising the script with the flags. The
+// supervisor is stopped at the end of the test.
+func newHarness(t *testing.T, script string, flags ...string) *harness {
+	t.Helper()
+	h := &harness{t: t, path: filepath.Join(t.TempDir(), "cmd"), done: make(chan struct{})}
+	h.write(script)
+	cfg, err := parseConfig(append(flags, h.path))
+	if err != nil {
+		t.Fatalf("parsing %v: %v", flags, err)
+	}
+	h.s = newSupervisor(cfg, h.path)
+	go func() {
+		h.code = h.s.run()
+		close(h.done)
+	}()
+	t.Cleanup(func() { h.stop() })
+	return h
+}
+
+// write replaces the script, as a build would.
+func (h *harness) write(script string) {
+	h.t.Helper()
+	tmp := h.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(script), 0755); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// request sends the control request and returns the reply.
+func (h *harness) request(command string) controlReply {
+	h.t.Helper()
+	req := controlRequest{command: command, reply: make(chan controlReply, 1)}
+	select {
+	case h.s.requests <- req:
+	case <-h.done:
+		h.t.Fatalf("supervisor exited with code %d before %s", h.code, command)
+	case <-time.After(10 * time.Second):
+		h.t.Fatalf("timed out sending %s", command)
+	}
+	select {
+	case reply := <-req.reply:
+		return reply
+	case <-time.After(10 * time.Second):
+		h.t.Fatalf("timed out waiting for the reply to %s", command)
+	}
+	return controlReply{}
+}
+
+// status returns the status of the supervisor.
+func (h *harness) status() controlStatus {
+	return h.s.board.status()
+}
+
+// waitFor waits until the status satisfies the condition.
+func (h *harness) waitFor(what string, cond func(st controlStatus) bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond(h.status()) {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s; status: %+v", what, h.status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// wait waits for the supervisor to exit and returns its exit code.
+func (h *harness) wait() int {
+	h.t.Helper()
+	select {
+	case <-h.done:
+		return h.code
+	case <-time.After(30 * time.Second):
+		h.t.Fatal("timed out waiting for the supervisor to exit")
+	}
+	return 0
+}
+
+// stop stops the supervisor, unless it exited, and returns its exit
+// code.
+func (h *harness) stop() int {
+	h.t.Helper()
+	select {
+	case <-h.done:
+		return h.code
+	default:
+	}
+	req := controlRequest{command: "stop", reply: make(chan controlReply, 1)}
+	select {
+	case h.s.requests <- req:
+	case <-h.done:
+	}
+	return h.wait()
+}
+
+// generation returns a condition on the generation of the command.
+func generation(n int) func(st controlStatus) bool {
+	return func(st controlStatus) bool { return st.Generation == n && st.Running }
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// runHook runs the hook command through the shell in the directory and
+// with the environment of the command, writing its output with a
+// prefix. The hook is killed if it does not exit within the timeout.
+func runHook(kind, command, dir string, env []string, timeout time.Duration) error {
+	out := newPrefixWriter(stdout, "["+kind+"] ", styleBuild)
+	errs := newPrefixWriter(stderr, "["+kind+"] ", styleBuild)
+	defer out.flush()
+	defer errs.flush()
+
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = env
+	cmd.Dir = dir
+	cmd.Stdout = out
+	cmd.Stderr = errs
+	// Run the hook in its own process group, so that killing it also
+	// stops the commands it runs.
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s hook %q: %v", kind, command, err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s hook %q: %v", kind, command, err)
+		}
+		return nil
+	case <-timer.C:
+		killGroup(cmd)
+		<-done
+		return fmt.Errorf("%s hook %q did not exit within %v", kind, command, timeout)
+	}
+}
+
+// runHooks runs the hooks in order, stopping at the first that fails.
+func (s *supervisor) runHooks(kind string, commands []string, env []string) error {
+	for _, command := range commands {
+		command, err := s.cfg.expand(command, env)
+		if err != nil {
+			return fmt.Errorf("%s hook: %v", kind, err)
+		}
+		command = expandPlaceholders(command, s.changed)
+		logger.debugf("Running %s hook %q", kind, command)
+		if err := runHook(kind, command, s.cfg.chdir, env, s.cfg.hookTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// skippedDirs are directories that churn without affecting the command,
+// so they are not watched unless a watched path lies within them.
+var skippedDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	".idea":        true,
+	".venv":        true,
+	".tox":         true,
+	"__pycache__":  true,
+	"node_modules": true,
+}
+
+// relPath returns the slash-separated path of name relative to the watch
+// root that contains it, or its base name if no root contains it.
+func (w *watcher) relPath(name string) string {
+	rel := filepath.Base(name)
+	for _, root := range w.roots {
+		if r, err := filepath.Rel(root, name); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+			break
+		}
+	}
+	return filepath.ToSlash(rel)
+}
+
+// skipped reports whether the path lies within, or is, one of the
+// skipped directories beneath its watch root, or is hidden.
+func (w *watcher) skipped(name string) bool {
+	return w.inSkippedDir(name) || w.hidden(name)
+}
+
+// inSkippedDir reports whether the path lies within, or is, one of the
+// skipped directories beneath its watch root.
+func (w *watcher) inSkippedDir(name string) bool {
+	for _, elem := range strings.Split(w.relPath(name), "/") {
+		if skippedDirs[elem] {
+			return true
+		}
+	}
+	return false
+}
+
+// hidden reports whether the path lies within, or is, a file or
+// directory beneath its watch root whose name starts with a dot, such as
+// an editor lock file, unless hidden files are included. Watched files
+// are never hidden.
+func (w *watcher) hidden(name string) bool {
+	if w.includeHidden || w.isFile(name) {
+		return false
+	}
+	for _, elem := range strings.Split(w.relPath(name), "/") {
+		if strings.HasPrefix(elem, ".") && elem != "." && elem != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// filtered reports whether the file within a watched directory lacks
+// one of the extensions being watched.
+func (w *watcher) filtered(name string) bool {
+	if len(w.exts) == 0 || w.isFile(name) {
+		return false
+	}
+	ext := filepath.Ext(name)
+	for _, e := range w.exts {
+		if ext == e {
+			return false
+		}
+	}
+	return true
+}
+
+// ignoredBy returns the ignore pattern that matches the path, if any.
+// Patterns are matched against the path relative to the watch root that
+// contains it, or against the base name of a watched file.
+func (w *watcher) ignoredBy(name string) (string, bool) {
+	if len(w.ignore) == 0 {
+		return "", false
+	}
+	rel := w.relPath(name)
+	for _, pattern := range w.ignore {
+		if matchGlob(pattern, rel) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matchGlob reports whether the slash-separated name matches the
+// pattern. In addition to the syntax of path.Match, a "**" element
+// matches any number of path elements, including none.
+func matchGlob(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.tmp", "a.tmp", true},
+		{"*.tmp", "dir/a.tmp", false},
+		{"**/*.tmp", "a.tmp", true},
+		{"**/*.tmp", "dir/sub/a.tmp", true},
+		{"**/*.tmp", "dir/a.go", false},
+		{"vendor/**", "vendor", true},
+		{"vendor/**", "vendor/a/b.go", true},
+		{"vendor/**", "src/vendor/a.go", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"**", "any/thing", true},
+		{"**/testdata/**", "pkg/testdata/golden.txt", true},
+		{"**/testdata/**", "pkg/data/golden.txt", false},
+		{"a/*/c", "a/b/c", true},
+		{"a/*/c", "a/b/b/c", false},
+		{"[", "[", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoredBy(t *testing.T) {
+	root := filepath.FromSlash("/src/app")
+	bin := filepath.FromSlash("/build/bin/server")
+	w := &watcher{
+		roots:  []string{root},
+		files:  map[string]bool{bin: true},
+		ignore: []string{"**/*_test.go", "tmp/**", "server"},
+	}
+	tests := []struct {
+		name    string
+		pattern string
+	}{
+		{filepath.Join(root, "main_test.go"), "**/*_test.go"},
+		{filepath.Join(root, "pkg", "a_test.go"), "**/*_test.go"},
+		{filepath.Join(root, "tmp", "cache", "x"), "tmp/**"},
+		{filepath.Join(root, "pkg", "tmp", "x"), ""},
+		{filepath.Join(root, "main.go"), ""},
+		// Watched files are matched by their base name.
+		{bin, "server"},
+	}
+	for _, tt := range tests {
+		pattern, ok := w.ignoredBy(tt.name)
+		if pattern != tt.pattern || ok != (tt.pattern != "") {
+			t.Errorf("ignoredBy(%s) = %q, %v, want %q", tt.name, pattern, ok, tt.pattern)
+		}
+	}
+}
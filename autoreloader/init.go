@@ -0,0 +1,4 @@
+package main
+
+// initEnv marks the wrapper that --init started beneath itself.
+const initEnv = "AUTORELOADER_INIT"
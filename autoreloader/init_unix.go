@@ -0,0 +1,84 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// initSignals are the signals that --init relays to the wrapper.
+var initSignals = append([]os.Signal{syscall.SIGUSR1, syscall.SIGUSR2}, forwardedSignals...)
+
+// runInit runs the wrapper beneath itself and acts as the init process
+// of a container, with --init: processes orphaned by the command are
+// reparented to it, which it reaps, and the signals sent to it, such as
+// the SIGTERM of docker stop, are relayed to the wrapper. When it is not
+// PID 1, it becomes a subreaper where supported. It exits with the exit
+// code of the wrapper.
+//
+// The wrapper runs in its own process group, in the foreground of the
+// terminal if there is one, so that signals from the terminal reach it
+// only once, directly.
+func runInit() {
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Cannot find the autoreloader executable: %v", err)
+	}
+	if os.Getpid() != 1 {
+		if err := becomeSubreaper(); err != nil {
+			log.Printf("Cannot reap orphaned processes: %v", err)
+		}
+	}
+	signals := make(chan os.Signal, 16)
+	signal.Notify(signals, append([]os.Signal{syscall.SIGCHLD}, initSignals...)...)
+
+	cmd := exec.Command(self, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), initEnv+"=1")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if isTerminal(os.Stdin) {
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = int(os.Stdin.Fd())
+	}
+	if err := cmd.Start(); err != nil {
+		log.Fatalf("Cannot start autoreloader: %v", err)
+	}
+	pid := cmd.Process.Pid
+
+	for {
+		if code, exited := reap(pid); exited {
+			os.Exit(code)
+		}
+		sig := <-signals
+		if sig != syscall.SIGCHLD {
+			syscall.Kill(pid, sig.(syscall.Signal))
+		}
+	}
+}
+
+// reap reaps every child that exited, reporting whether the wrapper with
+// the process ID is one of them along with its exit code.
+func reap(pid int) (int, bool) {
+	for {
+		var status syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || wpid <= 0 {
+			return 0, false
+		}
+		if wpid != pid {
+			continue
+		}
+		if status.Signaled() {
+			return 128 + int(status.Signal()), true
+		}
+		return status.ExitStatus(), true
+	}
+}
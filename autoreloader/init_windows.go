@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "log"
+
+// runInit fails, as Windows has no init process to stand in for.
+func runInit() {
+	log.Fatal("--init is not supported on windows")
+}
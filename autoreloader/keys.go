@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+)
+
+// keyEscape is the byte, Ctrl-], that precedes a key for the wrapper in
+// its standard input, like the escape character of ssh. The standard
+// input is otherwise passed to the command, so the keys only act after
+// it. Pressing Ctrl-] twice passes the byte itself.
+const keyEscape = 0x1d
+
+// keyMap describes the keys for the wrapper.
+const keyMap = `Keys, after Ctrl-]:
+  r  restart the command now
+  p  pause or resume restarting on changes
+  q  stop the command and exit
+  ?  show this help`
+
+// keyFilter removes the keys for the wrapper from the standard input,
+// passing them on keys.
+type keyFilter struct {
+	keys    chan<- byte
+	escaped bool
+}
+
+// filter returns the chunk without the keys for the wrapper. Without a
+// terminal in raw mode, the key is followed by a newline, which is
+// dropped along with it.
+func (f *keyFilter) filter(chunk []byte) []byte {
+	out := chunk[:0]
+	for i := 0; i < len(chunk); i++ {
+		b := chunk[i]
+		switch {
+		case f.escaped && b == keyEscape:
+			out = append(out, b)
+		case f.escaped:
+			select {
+			case f.keys <- b:
+			default:
+			}
+			if i+1 < len(chunk) && (chunk[i+1] == '\n' || chunk[i+1] == '\r') {
+				i++
+			}
+		case b == keyEscape:
+			f.escaped = true
+			continue
+		default:
+			out = append(out, b)
+		}
+		f.escaped = false
+	}
+	return out
+}
+
+// handleKeys acts on the keys for the wrapper, passing them to the
+// supervisor as requests like those over the control socket.
+func (s *supervisor) handleKeys(keys <-chan byte) {
+	for key := range keys {
+		var command string
+		switch key {
+		case 'r':
+			command = "restart"
+		case 'p':
+			command = "pause"
+		case 'q':
+			command = "stop"
+		case '?', 'h':
+			fmt.Fprintln(stderr, keyMap)
+			continue
+		default:
+			logger.infof("Unknown key %q; press Ctrl-] ? for help", key)
+			continue
+		}
+		req := controlRequest{command: command, reply: make(chan controlReply, 1)}
+		s.requests <- req
+		if reply := <-req.reply; reply.Error != "" {
+			logger.errorf("Key %q: %s", key, reply.Error)
+		}
+	}
+}
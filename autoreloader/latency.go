@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// latency measures the steps of a restart, from the first change that
+// caused it until the new command is ready.
+type latency struct {
+	// changed is when the first change was seen, or when the restart
+	// began if it was not caused by a change.
+	changed time.Time
+
+	settle time.Duration
+	build  time.Duration
+	stop   time.Duration
+	killed bool
+	port   time.Duration
+	start  time.Duration
+	ready  time.Duration
+	total  time.Duration
+}
+
+// String describes the latency compactly, such as "1.8s: build 1.2s,
+// stop 300ms, start 100ms, ready 200ms". Steps that did not apply are
+// omitted.
+func (l latency) String() string {
+	var steps []string
+	step := func(name string, d time.Duration) {
+		if d > 0 {
+			steps = append(steps, fmt.Sprintf("%s %v", name, round(d)))
+		}
+	}
+	step("settle", l.settle)
+	step("build", l.build)
+	step("stop", l.stop)
+	if l.killed && len(steps) > 0 {
+		steps[len(steps)-1] += " (killed)"
+	}
+	step("port", l.port)
+	step("start", l.start)
+	step("ready", l.ready)
+	return fmt.Sprintf("%v: %s", round(l.total), strings.Join(steps, ", "))
+}
+
+func (l *latency) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) *int64 {
+		if d == 0 {
+			return nil
+		}
+		ms := d.Milliseconds()
+		return &ms
+	}
+	return json.Marshal(struct {
+		SettleMS *int64 `json:"settle_ms,omitempty"`
+		BuildMS  *int64 `json:"build_ms,omitempty"`
+		StopMS   *int64 `json:"stop_ms,omitempty"`
+		Killed   bool   `json:"killed,omitempty"`
+		PortMS   *int64 `json:"port_ms,omitempty"`
+		StartMS  *int64 `json:"start_ms,omitempty"`
+		ReadyMS  *int64 `json:"ready_ms,omitempty"`
+	}{ms(l.settle), ms(l.build), ms(l.stop), l.killed, ms(l.port), ms(l.start), ms(l.ready)})
+}
+
+// round rounds the duration for display: to the tenth of a second over
+// a second, or else to the millisecond.
+func round(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/agschwender/autoreload"
+)
+
+// logLevel defines how much the wrapper logs.
+type logLevel int
+
+const (
+	// levelQuiet only logs errors.
+	levelQuiet logLevel = iota
+
+	// levelDefault additionally logs lifecycle events, such as changes
+	// and restarts.
+	levelDefault
+
+	// levelVerbose additionally logs every watch event, the signals sent
+	// and timings.
+	levelVerbose
+)
+
+// levelNames are the names of the levels in JSON logs.
+var levelNames = map[logLevel]string{
+	levelQuiet:   "error",
+	levelDefault: "info",
+	levelVerbose: "debug",
+}
+
+// logFormat is the format of the wrapper's log.
+type logFormat int
+
+const (
+	// formatText logs human readable lines.
+	formatText logFormat = iota
+
+	// formatJSON logs a JSON object per line.
+	formatJSON
+)
+
+func (f *logFormat) String() string {
+	if *f == formatJSON {
+		return "json"
+	}
+	return "text"
+}
+
+func (f *logFormat) Set(s string) error {
+	switch s {
+	case "text":
+		*f = formatText
+	case "json":
+		*f = formatJSON
+	default:
+		return fmt.Errorf("must be text or json")
+	}
+	return nil
+}
+
+// logEvent is an event of the wrapper, such as a start of the command.
+// In JSON logs, the fields that do not apply to the event are omitted.
+type logEvent struct {
+	Time       time.Time `json:"ts"`
+	Event      string    `json:"event"`
+	Level      string    `json:"level"`
+	Message    string    `json:"msg"`
+	Process    string    `json:"process,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	PID        int       `json:"pid,omitempty"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	DurationMS *int64    `json:"duration_ms,omitempty"`
+	Generation int       `json:"generation,omitempty"`
+	Latency    *latency  `json:"latency,omitempty"`
+
+	// Restart counts the restarts, which the trigger caused, replacing a
+	// process that was up for the previous uptime.
+	Restart          int    `json:"restart,omitempty"`
+	Trigger          string `json:"trigger,omitempty"`
+	PreviousUptimeMS *int64 `json:"previous_uptime_ms,omitempty"`
+}
+
+// withExitCode returns the event with its exit code set.
+func (e logEvent) withExitCode(code int) logEvent {
+	e.ExitCode = &code
+	return e
+}
+
+// withDuration returns the event with its duration set.
+func (e logEvent) withDuration(d time.Duration) logEvent {
+	ms := d.Milliseconds()
+	e.DurationMS = &ms
+	return e
+}
+
+// leveledLogger logs the messages of the wrapper that are within its
+// level. It implements autoreload.Logger, so that messages of the
+// autoreload package obey the same level. The output of the command is
+// not affected.
+type leveledLogger struct {
+	mu     sync.Mutex
+	level  logLevel
+	format logFormat
+}
+
+var _ autoreload.Logger = (*leveledLogger)(nil)
+
+// logger is the logger of the wrapper.
+var logger = &leveledLogger{level: levelDefault}
+
+func (l *leveledLogger) setLevel(level logLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+func (l *leveledLogger) setFormat(format logFormat) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.format = format
+}
+
+func (l *leveledLogger) enabled(level logLevel) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.level >= level
+}
+
+// event logs the event with the message if the level is enabled.
+func (l *leveledLogger) event(level logLevel, e logEvent, format string, args ...interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.format != formatJSON {
+		log.Print(styleWrapper.paint(msg))
+		return
+	}
+	e.Time = time.Now()
+	e.Level = levelNames[level]
+	e.Message = msg
+	e.Process = processName
+	writeJSONLine(stderr, e)
+}
+
+// errorf logs an error. Errors are logged at every level.
+func (l *leveledLogger) errorf(format string, args ...interface{}) {
+	l.event(levelQuiet, logEvent{Event: "log"}, format, args...)
+}
+
+// infof logs a lifecycle event.
+func (l *leveledLogger) infof(format string, args ...interface{}) {
+	l.event(levelDefault, logEvent{Event: "log"}, format, args...)
+}
+
+// debugf logs a detail that is only of interest when diagnosing the
+// wrapper.
+func (l *leveledLogger) debugf(format string, args ...interface{}) {
+	l.event(levelVerbose, logEvent{Event: "log"}, format, args...)
+}
+
+// writeJSONLine writes the value as a line of JSON.
+func writeJSONLine(w io.Writer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	w.Write(append(b, '\n'))
+}
+
+func (l *leveledLogger) Info(msg string) {
+	l.infof("%s", msg)
+}
+
+func (l *leveledLogger) Error(msg string, err error) {
+	l.errorf("%s", fmt.Sprintf("%s: %v", msg, err))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// logFile is the file of --log-file, if any.
+var logFile *rotatingFile
+
+// openLogFile directs the output of the wrapper to the log file of
+// --log-file, in addition to the terminal unless --log-file-only is
+// given.
+func openLogFile(cfg *config) error {
+	f, err := openRotatingFile(cfg.logFile, int64(cfg.logMaxSize), cfg.logKeep)
+	if err != nil {
+		return err
+	}
+	logFile = f
+	stdio.setLogFile(f, cfg.logFileOnly)
+	return nil
+}
+
+// ansiEscape matches the escape sequences that style output.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// rotatingFile is a log file that is rotated once it exceeds its
+// maximum size, keeping a number of old files, path.1 being the newest.
+// Each write is written whole, so writers that write whole lines are not
+// interleaved mid-line.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	f       *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, maxSize int64, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("cannot open log file: %w", err)
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// reopen closes and reopens the file, so that writing continues to the
+// path after the file was moved, as by logrotate.
+func (r *rotatingFile) reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.f.Close()
+	return r.open()
+}
+
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Styles are only meant for the terminal.
+	out := ansiEscape.ReplaceAll(b, nil)
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(out)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(out)
+	r.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// rotate moves the file to path.1, shifting the old files and removing
+// the oldest, and opens a new file.
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	os.Remove(r.path + "." + strconv.Itoa(r.keep))
+	for i := r.keep - 1; i >= 1; i-- {
+		os.Rename(r.path+"."+strconv.Itoa(i), r.path+"."+strconv.Itoa(i+1))
+	}
+	if r.keep > 0 {
+		os.Rename(r.path, r.path+".1")
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
+
+// sizeValue is a flag.Value for a size in bytes, with an optional KB, MB
+// or GB suffix.
+type sizeValue int64
+
+func (v *sizeValue) String() string {
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+func (v *sizeValue) Set(s string) error {
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}}
+	number, unit := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("must be a size such as 10MB")
+	}
+	*v = sizeValue(n * unit)
+	return nil
+}
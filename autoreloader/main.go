@@ -4,73 +4,102 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"sync"
-	"time"
-
-	"github.com/agschwender/autoreload"
+	"path/filepath"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatalf("Must supply a command to autoreload")
+	log.SetOutput(stderr)
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(subcommand(os.Args[2:]))
+		}
 	}
 
-	// Verify that the supplied command exists and generate an exec
-	// command.
-	path, err := exec.LookPath(os.Args[1])
-	if err != nil {
-		log.Fatalf("Cannot find executable: %s", os.Args[1])
+	cfg := mustParseConfig()
+	logger.setLevel(cfg.logLevel())
+	logger.setFormat(cfg.logFormat)
+	colors = cfg.colored()
+	if cfg.init && os.Getenv(initEnv) == "" {
+		runInit()
 	}
-
-	// Define the command and redirect output
-	cmd := exec.Command(path, os.Args[2:]...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	// Start the supplied command
-	err = cmd.Start()
-	if err != nil {
-		log.Fatalf("Failed to spawn process: %v", err)
+	if cfg.procfile != "" && processName == "" {
+		os.Exit(runProcfile(cfg))
 	}
 
-	// Starting the command above can trigger watch events that would
-	// trigger a reload. Delay defining the autoreloader monitor.
-	time.Sleep(250 * time.Millisecond)
-
-	// Setup wait for reload
-	var wg sync.WaitGroup
-
-	// Start the autoreloader monitor.
-	autoreload.New(
-		autoreload.WithCommand(os.Args[1]),
-		autoreload.WithOnReload(func() {
-			// When the application needs to reload, we must kill the
-			// spawned command.
-			log.Printf("Killing spawned process")
-			if err := cmd.Process.Kill(); err != nil {
-				log.Fatalf("Failed to kill spawned process: %v", err)
+	var path string
+	var err error
+	if cfg.goPkg == "" {
+		// Verify that the supplied command exists.
+		if path, err = exec.LookPath(cfg.command[0]); err != nil {
+			// An executable given by path may be built later, but one
+			// looked up in PATH may not.
+			if _, statErr := os.Stat(cfg.command[0]); !os.IsNotExist(statErr) || filepath.Base(cfg.command[0]) == cfg.command[0] {
+				log.Fatalf("Cannot find executable: %s", cfg.command[0])
 			}
+			if info, statErr := os.Stat(filepath.Dir(cfg.command[0])); cfg.build == "" && (statErr != nil || !info.IsDir()) {
+				log.Fatalf("Cannot find executable %s, nor its directory to watch for it", cfg.command[0])
+			}
+			path = cfg.command[0]
+		}
+		// Resolve the command relative to the current directory rather
+		// than that of --chdir, as watched paths are.
+		if path, err = filepath.Abs(path); err != nil {
+			log.Fatalf("Cannot resolve executable: %v", err)
+		}
+	}
 
-			// Add to the wait group so that the autoreloader executable
-			// will not exit due to the command being killed.
-			wg.Add(1)
-		}),
-	).Start()
-
-	// Wait for the command to complete.
-	err = cmd.Wait()
-
-	// If there is anything in the wait group, it means that the
-	// autoreloader package is restarting the executable. Wait here
-	// until it has completed that process.
-	wg.Wait()
+	if cfg.daemon && !daemonized() {
+		daemonize(cfg)
+	}
+	if cfg.logFile != "" {
+		cfg.logFileOnly = cfg.logFileOnly || daemonized()
+		if err := openLogFile(cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var goDir string
+	if cfg.goPkg != "" {
+		if goDir, err = setupGoMode(cfg); err != nil {
+			log.Fatal(err)
+		}
+		path = cfg.command[0]
+	}
+	if cfg.pidfile != "" {
+		if err := writePidfile(cfg.pidfile); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// Maintain the error exit code of the supplied command.
-	if err != nil {
-		if exiterr, ok := err.(*exec.ExitError); ok {
-			os.Exit(int(exiterr.ExitCode()))
-		} else {
-			os.Exit(1)
+	s := newSupervisor(cfg, path)
+	var metrics *metricsServer
+	if cfg.metricsAddr != "" {
+		if metrics, err = serveMetrics(cfg.metricsAddr, &s.board); err != nil {
+			if cfg.pidfile != "" {
+				removePidfile(cfg.pidfile)
+			}
+			log.Fatal(err)
 		}
 	}
+	var control *controlServer
+	if socket := cfg.controlSocket(); socket != "" {
+		if control, err = serveControl(socket, &s.board, s.requests); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Supervise the command, maintaining its exit code.
+	code := s.run()
+	if control != nil {
+		control.close()
+	}
+	if metrics != nil {
+		metrics.close()
+	}
+	if cfg.pidfile != "" {
+		removePidfile(cfg.pidfile)
+	}
+	if goDir != "" {
+		os.RemoveAll(goDir)
+	}
+	os.Exit(code)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// metricsServer serves the metrics of the wrapper at /metrics on
+// --metrics-addr, in the text format of Prometheus.
+type metricsServer struct {
+	srv *http.Server
+}
+
+// serveMetrics listens on the address and serves the metrics of the
+// board. It fails if the address is taken, such as by the command.
+func serveMetrics(addr string, board *statusBoard) (*metricsServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot serve metrics on %s: %v", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		board.writeMetrics(w)
+	})
+	m := &metricsServer{srv: &http.Server{Handler: mux, ReadHeaderTimeout: controlTimeout}}
+	go m.srv.Serve(ln)
+	logger.debugf("Serving metrics on http://%s/metrics", ln.Addr())
+	return m, nil
+}
+
+// close stops serving, waiting briefly for the scrapes being answered.
+func (m *metricsServer) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.srv.Shutdown(ctx); err != nil {
+		m.srv.Close()
+	}
+}
+
+// writeMetrics writes the metrics of the board.
+func (b *statusBoard) writeMetrics(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	metric(w, "autoreloader_restarts_total", "counter", "Restarts of the command by cause: change, manual or crash.")
+	causes := []string{"change", "manual", "crash"}
+	for cause := range b.restartCauses {
+		if cause != "change" && cause != "manual" && cause != "crash" {
+			causes = append(causes, cause)
+		}
+	}
+	sort.Strings(causes[3:])
+	for _, cause := range causes {
+		fmt.Fprintf(w, "autoreloader_restarts_total{cause=%q} %d\n", cause, b.restartCauses[cause])
+	}
+
+	metric(w, "autoreloader_builds_total", "counter", "Builds of the command by result.")
+	fmt.Fprintf(w, "autoreloader_builds_total{result=\"success\"} %d\n", b.buildsSucceeded)
+	fmt.Fprintf(w, "autoreloader_builds_total{result=\"failure\"} %d\n", b.buildsFailed)
+	metric(w, "autoreloader_build_duration_seconds", "summary", "Durations of the builds.")
+	fmt.Fprintf(w, "autoreloader_build_duration_seconds_sum %g\n", b.buildTime.Seconds())
+	fmt.Fprintf(w, "autoreloader_build_duration_seconds_count %d\n", b.buildsSucceeded+b.buildsFailed)
+
+	metric(w, "autoreloader_ready_duration_seconds", "summary", "How long restarted processes took to accept connections on --wait-tcp.")
+	fmt.Fprintf(w, "autoreloader_ready_duration_seconds_sum %g\n", b.readyTime.Seconds())
+	fmt.Fprintf(w, "autoreloader_ready_duration_seconds_count %d\n", b.readies)
+
+	up, uptime, generation := 0, time.Duration(0), 0
+	if b.proc != nil {
+		generation = b.proc.generation
+		select {
+		case <-b.proc.done:
+		default:
+			up, uptime = 1, time.Since(b.proc.started)
+		}
+	}
+	metric(w, "autoreloader_generation", "gauge", "Starts of the command.")
+	fmt.Fprintf(w, "autoreloader_generation %d\n", generation)
+	metric(w, "autoreloader_child_up", "gauge", "Whether the command is running.")
+	fmt.Fprintf(w, "autoreloader_child_up %d\n", up)
+	metric(w, "autoreloader_child_uptime_seconds", "gauge", "How long the command has been running.")
+	fmt.Fprintf(w, "autoreloader_child_uptime_seconds %g\n", uptime.Seconds())
+}
+
+// metric writes the help and type of the metric.
+func metric(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// notifier sends a desktop notification with the title and message.
+type notifier func(title, message string) *exec.Cmd
+
+// notifiers are the notifiers of each operating system, along with the
+// executable they need.
+var notifiers = map[string]struct {
+	executable string
+	notify     notifier
+}{
+	"linux": {"notify-send", func(title, message string) *exec.Cmd {
+		return exec.Command("notify-send", "--app-name=autoreloader", title, message)
+	}},
+	"darwin": {"osascript", func(title, message string) *exec.Cmd {
+		script := "display notification " + appleScriptQuote(message) + " with title " + appleScriptQuote(title)
+		return exec.Command("osascript", "-e", script)
+	}},
+	"windows": {"powershell", func(title, message string) *exec.Cmd {
+		script := `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode(` + powerShellQuote(title) + `)) | Out-Null
+$text.Item(1).AppendChild($xml.CreateTextNode(` + powerShellQuote(message) + `)) | Out-Null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($xml)
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('autoreloader').Show($toast)`
+		return exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	}},
+}
+
+// notify sends a desktop notification about the command, if enabled.
+// Only the first line of the message is sent. Nothing is sent if the
+// operating system has no notifier or its executable is missing.
+func (s *supervisor) notify(message string) {
+	if !s.cfg.notify {
+		return
+	}
+	n, ok := notifiers[runtime.GOOS]
+	if !ok {
+		return
+	}
+	if _, err := exec.LookPath(n.executable); err != nil {
+		logger.debugf("Not notifying, %s is missing", n.executable)
+		return
+	}
+	message = strings.SplitN(message, "\n", 2)[0]
+	cmd := n.notify("autoreloader: "+filepath.Base(s.cfg.command[0]), message)
+	go func() {
+		if err := cmd.Run(); err != nil {
+			logger.debugf("Failed to notify: %v", err)
+		}
+	}()
+}
+
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func powerShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// outputTarget is where a stream of the output of the command is written
+// with --stdout or --stderr: "-", the default, for the output of the
+// wrapper, "null" to discard it, or a file that it is appended to.
+type outputTarget string
+
+// outputNull is the outputTarget that discards the output.
+const outputNull outputTarget = "null"
+
+func (t *outputTarget) String() string {
+	if *t == "" {
+		return "-"
+	}
+	return string(*t)
+}
+
+func (t *outputTarget) Set(s string) error {
+	if s == "" {
+		return fmt.Errorf("must be -, null or a file")
+	}
+	*t = outputTarget(s)
+	return nil
+}
+
+// redirected reports whether the stream is written elsewhere than to the
+// output of the wrapper.
+func (t outputTarget) redirected() bool {
+	return t != "" && t != "-"
+}
+
+// lineHold is how long the output of the command holds an incomplete
+// line before writing it.
+const lineHold = 100 * time.Millisecond
+
+// lineWriter writes each line written to it to the underlying writer,
+// formatted by format. Incomplete lines are held until they are
+// completed or flushed or, if hold is set, for at most hold.
+type lineWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format func(line []byte) []byte
+	buf    []byte
+	hold   time.Duration
+	timer  *time.Timer
+}
+
+// newPrefixWriter returns a lineWriter that writes each line with a
+// prefix, in the style.
+func newPrefixWriter(w io.Writer, prefix string, s style) *lineWriter {
+	return &lineWriter{w: w, format: func(line []byte) []byte {
+		return []byte(s.paint(prefix + string(line)))
+	}}
+}
+
+// childLine is the JSON envelope of a line of output of the command.
+type childLine struct {
+	Time       time.Time `json:"ts"`
+	Event      string    `json:"event"`
+	Process    string    `json:"process,omitempty"`
+	Stream     string    `json:"stream"`
+	Line       string    `json:"line"`
+	Generation int       `json:"generation"`
+}
+
+// newJSONWriter returns a lineWriter that writes each line of the
+// stream of the command in a JSON envelope.
+func newJSONWriter(w io.Writer, stream string, generation int) *lineWriter {
+	return &lineWriter{w: w, format: func(line []byte) []byte {
+		var buf bytes.Buffer
+		writeJSONLine(&buf, childLine{
+			Time:       time.Now(),
+			Event:      "output",
+			Process:    processName,
+			Stream:     stream,
+			Line:       string(bytes.TrimSuffix(line, []byte("\n"))),
+			Generation: generation,
+		})
+		return buf.Bytes()
+	}}
+}
+
+func (p *lineWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			if len(p.buf) > 0 && p.hold > 0 && p.timer == nil {
+				p.timer = time.AfterFunc(p.hold, p.release)
+			}
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// release writes the incomplete line once held for hold.
+func (p *lineWriter) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.timer = nil
+	if len(p.buf) > 0 {
+		p.writeLine(p.buf)
+		p.buf = nil
+	}
+}
+
+// flush writes any incomplete line.
+func (p *lineWriter) flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *lineWriter) writeLine(line []byte) error {
+	_, err := p.w.Write(p.format(line))
+	return err
+}
+
+// maxTailSize caps how many bytes a tailWriter keeps, however long its
+// lines are.
+const maxTailSize = 64 * 1024
+
+// tailWriter keeps the last lines written to it.
+type tailWriter struct {
+	mu    sync.Mutex
+	buf   []byte
+	lines int
+}
+
+func newTailWriter(lines int) *tailWriter {
+	return &tailWriter{lines: lines}
+}
+
+func (t *tailWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, b...)
+	for bytes.Count(t.buf, []byte("\n")) > t.lines {
+		t.buf = t.buf[bytes.IndexByte(t.buf, '\n')+1:]
+	}
+	if len(t.buf) > maxTailSize {
+		t.buf = t.buf[len(t.buf)-maxTailSize:]
+	}
+	return len(b), nil
+}
+
+// String returns the lines that were kept.
+func (t *tailWriter) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(bytes.TrimRight(t.buf, "\n"))
+}
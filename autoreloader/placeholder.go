@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandPlaceholders substitutes the placeholders in the shell command
+// with the changed paths, relative to the working directory:
+//
+//	{file}   the first changed path
+//	{dir}    its directory
+//	{base}   its base name
+//	{ext}    its extension
+//	{files}  all changed paths, separated by spaces
+//
+// The paths are substituted already quoted for the shell, so
+// placeholders must not be quoted in the command.
+func expandPlaceholders(command string, paths []string) string {
+	if !strings.Contains(command, "{") {
+		return command
+	}
+	var file string
+	files := make([]string, len(paths))
+	for i, path := range paths {
+		files[i] = shellQuote(displayPath(path))
+	}
+	if len(paths) > 0 {
+		file = displayPath(paths[0])
+	}
+	dir, base := "", ""
+	if file != "" {
+		dir, base = filepath.Dir(file), filepath.Base(file)
+	}
+	return strings.NewReplacer(
+		"{file}", shellQuote(file),
+		"{dir}", shellQuote(dir),
+		"{base}", shellQuote(base),
+		"{ext}", shellQuote(filepath.Ext(file)),
+		"{files}", strings.Join(files, " "),
+	).Replace(command)
+}
+
+// displayPath returns the path relative to the working directory, if it
+// lies within it.
+func displayPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel
+		}
+	}
+	return path
+}
+
+// shellQuote quotes the value for the shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,56 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	wd := t.TempDir()
+	chdir(t, wd)
+	tests := []struct {
+		name    string
+		command string
+		paths   []string
+		want    string
+	}{
+		{"none", "go build ./...", []string{filepath.Join(wd, "main.go")}, "go build ./..."},
+		{"file", "gofmt -l {file}", []string{filepath.Join(wd, "cmd", "main.go")}, "gofmt -l 'cmd/main.go'"},
+		{"parts", "{dir} {base} {ext}", []string{filepath.Join(wd, "cmd", "main.go")}, "'cmd' 'main.go' '.go'"},
+		{"files", "go vet {files}", []string{filepath.Join(wd, "a.go"), filepath.Join(wd, "b", "c.go")}, "go vet 'a.go' 'b/c.go'"},
+		{"quote", "cat {file}", []string{filepath.Join(wd, "it's.txt")}, `cat 'it'\''s.txt'`},
+		{"outside", "cat {file}", []string{"/etc/hosts"}, "cat '/etc/hosts'"},
+		{"sibling", "cat {file}", []string{filepath.Join(filepath.Dir(wd), "other")}, "cat '" + filepath.Join(filepath.Dir(wd), "other") + "'"},
+		{"dotted", "cat {file}", []string{filepath.Join(wd, "..env")}, "cat '..env'"},
+		{"empty", "echo {file} {dir} {base} {ext} [{files}]", nil, "echo '' '' '' '' []"},
+		{"unknown", "echo {other}", []string{filepath.Join(wd, "a.go")}, "echo {other}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandPlaceholders(tt.command, tt.paths); got != tt.want {
+				t.Errorf("expandPlaceholders(%q, %q) = %q, want %q", tt.command, tt.paths, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExpandPlaceholdersQuoting checks that the shell sees the paths as
+// they are, whatever they contain.
+func TestExpandPlaceholdersQuoting(t *testing.T) {
+	wd := t.TempDir()
+	chdir(t, wd)
+	for _, name := range []string{"it's.txt", "a b.txt", `$HOME "x"`, "semi;colon", "back\\slash"} {
+		command := expandPlaceholders("printf %s {base}", []string{filepath.Join(wd, name)})
+		out, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+		if string(out) != name {
+			t.Errorf("%s printed %q, want %q", command, out, name)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// hugeTree is the number of files beyond which checking the watched
+// paths becomes expensive.
+const hugeTree = 10000
+
+// crossCheckInterval is how often the watched paths are checked for
+// changes that went unreported by file system events.
+const crossCheckInterval = 30 * time.Second
+
+// fileState is the state of a file used to detect changes to it.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	mode    os.FileMode
+}
+
+// snapshot returns the state of the watched files and of the files
+// within the watched directories, keyed by path. Ignored and skipped
+// directories are not descended into.
+func (w *watcher) snapshot() map[string]fileState {
+	states := map[string]fileState{}
+	record := func(path string, info os.FileInfo) {
+		states[path] = fileState{modTime: info.ModTime(), size: info.Size(), mode: info.Mode()}
+	}
+	for _, path := range w.fileList() {
+		if info, err := os.Stat(path); err == nil {
+			record(path, info)
+		}
+	}
+	for _, root := range w.roots {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// The path may have been removed since it was listed.
+				return nil
+			}
+			if _, ignored := w.ignoredBy(path); path != root && (ignored || w.skipped(path)) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				record(path, info)
+			}
+			return nil
+		})
+	}
+	return states
+}
+
+// diffStates returns an event for each file created, removed or changed
+// between the snapshots.
+func diffStates(earlier, current map[string]fileState) []fsnotify.Event {
+	var events []fsnotify.Event
+	for path, state := range current {
+		before, ok := earlier[path]
+		switch {
+		case !ok:
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		case state != before:
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+	for path := range earlier {
+		if _, ok := current[path]; !ok {
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+		}
+	}
+	return events
+}
+
+// poll sends an event for every change to the watched paths, checking
+// them at the interval.
+func (w *watcher) poll(interval time.Duration, events chan<- fsnotify.Event) {
+	states := w.snapshot()
+	if len(states) > hugeTree {
+		logger.errorf("Polling %d files every %v; consider narrowing --watch or adding --ignore", len(states), interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			current := w.snapshot()
+			for _, event := range diffStates(states, current) {
+				select {
+				case events <- event:
+				case <-w.done:
+					return
+				}
+			}
+			states = current
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// crossCheck occasionally compares snapshots of the watched paths and
+// suggests polling if files changed without any event being received,
+// as happens on some mounted volumes.
+func (w *watcher) crossCheck() {
+	states := w.snapshot()
+	if len(states) > hugeTree {
+		return
+	}
+	ticker := time.NewTicker(crossCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			current := w.snapshot()
+			seen := atomic.SwapInt64(&w.seen, 0)
+			if seen == 0 && len(diffStates(states, current)) > 0 {
+				logger.errorf("Files changed but no file system events were received; if they are on a mounted volume, try --poll")
+				return
+			}
+			states = current
+		case <-w.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// awaitPortFree waits for the address of --wait-port-free to be free
+// before the command is started again, so that it does not fail to
+// listen while the previous process lingers. If the address stays busy
+// past the timeout, the restart proceeds, or with --fail-on-port-busy,
+// awaitPortFree reports that the wrapper stopped with exit code 1. If
+// the wrapper is stopped by a signal in the meantime, it reports that it
+// stopped along with the exit code of the command.
+func (s *supervisor) awaitPortFree() (int, bool) {
+	probe := s.cfg.waitPortFree
+	if probe.addr == "" {
+		return 0, false
+	}
+	begin := time.Now()
+	deadline := time.NewTimer(probe.timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if portFree(probe.addr) {
+			if waited := time.Since(begin); waited >= 100*time.Millisecond {
+				logger.debugf("%s became free after %v", probe.addr, waited.Round(time.Millisecond))
+			}
+			return 0, false
+		}
+		select {
+		case <-ticker.C:
+		case sig := <-s.signals:
+			logger.infof("Received %v while waiting for %s to be free; exiting", sig, probe.addr)
+			s.signaled = true
+			s.cancelBuild()
+			return s.proc.exitCode(), true
+		case <-deadline.C:
+			busy := probe.addr + " is still in use"
+			if _, port, err := net.SplitHostPort(probe.addr); err == nil {
+				if n, err := strconv.Atoi(port); err == nil {
+					if holder := portHolder(n); holder != "" {
+						busy += " by " + holder
+					}
+				}
+			}
+			if s.cfg.failOnPortBusy {
+				logger.errorf("%s after %v; exiting", busy, probe.timeout)
+				return 1, true
+			}
+			logger.errorf("%s after %v; starting anyway", busy, probe.timeout)
+			return 0, false
+		}
+	}
+}
+
+// portFree reports whether the address can be listened on.
+func portFree(addr string) bool {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
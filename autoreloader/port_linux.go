@@ -0,0 +1,121 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// States of TCP sockets in /proc/net/tcp.
+const (
+	tcpTimeWait = "06"
+	tcpListen   = "0A"
+)
+
+// tcpSockets returns the inodes of the sockets on the local TCP port,
+// only those listening if listen is set, and whether a connection on it
+// is in TIME_WAIT.
+func tcpSockets(port int, listen bool) (map[string]bool, bool) {
+	inodes := map[string]bool{}
+	timeWait := false
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(table)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // Skip the header.
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 {
+				continue
+			}
+			i := strings.LastIndexByte(fields[1], ':')
+			if p, err := strconv.ParseUint(fields[1][i+1:], 16, 16); err != nil || int(p) != port {
+				continue
+			}
+			if listen && fields[3] != tcpListen {
+				continue
+			}
+			if fields[9] != "0" {
+				inodes[fields[9]] = true
+			} else if fields[3] == tcpTimeWait {
+				timeWait = true
+			}
+		}
+		f.Close()
+	}
+	return inodes, timeWait
+}
+
+// socketOwners returns the process IDs that have one of the sockets
+// open.
+func socketOwners(inodes map[string]bool) []int {
+	if len(inodes) == 0 {
+		return nil
+	}
+	var pids []int
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		if inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+			pid, _ := strconv.Atoi(strings.Split(fd, "/")[2])
+			if len(pids) == 0 || pids[len(pids)-1] != pid {
+				pids = append(pids, pid)
+			}
+		}
+	}
+	return pids
+}
+
+// portHolder describes what holds the local TCP port, such as
+// "process 1234 (server)", or returns "" if it cannot tell.
+func portHolder(port int) string {
+	inodes, timeWait := tcpSockets(port, false)
+	if pids := socketOwners(inodes); len(pids) > 0 {
+		comm, _ := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pids[0]))
+		return fmt.Sprintf("process %d (%s)", pids[0], strings.TrimSpace(string(comm)))
+	}
+	if timeWait {
+		return "a connection in TIME_WAIT"
+	}
+	return ""
+}
+
+// listeningOn reports whether the process, or a process in the process
+// group it leads, listens on the local TCP port. It reports false for ok
+// if it cannot tell.
+func listeningOn(pid, port int) (listening, ok bool) {
+	inodes, _ := tcpSockets(port, true)
+	for _, owner := range socketOwners(inodes) {
+		if owner == pid || processGroupOf(owner) == pid {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// processGroupOf returns the process group of the process, or 0 if it
+// cannot tell.
+func processGroupOf(pid int) int {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0
+	}
+	// The command name, in parentheses, may contain spaces.
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	if len(fields) < 3 {
+		return 0
+	}
+	pgid, _ := strconv.Atoi(fields[2])
+	return pgid
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+// portHolder describes what holds the local TCP port, which is not
+// supported on this platform.
+func portHolder(port int) string {
+	return ""
+}
+
+// listeningOn reports whether the process listens on the local TCP port,
+// which cannot be told on this platform.
+func listeningOn(pid, port int) (listening, ok bool) {
+	return false, false
+}
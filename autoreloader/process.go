@@ -0,0 +1,339 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// process is a running instance of the supervised command.
+type process struct {
+	cmd        *exec.Cmd
+	generation int
+	started    time.Time
+	done       chan struct{}
+
+	detachStdin func()
+	terminal    *terminal
+
+	// output holds the writers of the output of the command, if it is
+	// wrapped in JSON or colored, and files the files of --stdout and
+	// --stderr it is redirected to, if any.
+	output []*lineWriter
+	files  []io.Closer
+
+	// stderr keeps the last lines of the error output of the command,
+	// to report a crash.
+	stderr *tailWriter
+
+	// group reports whether the command runs in its own process group,
+	// which receives the signals sent to the command. On Windows, job
+	// holds the job object that stands in for the process group.
+	group bool
+	job   jobObject
+
+	mu        sync.Mutex
+	code      int
+	ended     time.Time
+	escalated bool
+}
+
+// interruptedError is returned by startProcess when a signal was
+// received while retrying.
+type interruptedError struct {
+	sig os.Signal
+}
+
+func (e *interruptedError) Error() string {
+	return fmt.Sprintf("received %v while starting", e.sig)
+}
+
+// startProcess starts the command with the arguments. Starting is
+// retried while the executable is busy, missing or incomplete, as it is
+// while it is being rebuilt, until the attempts or the wait are
+// exhausted or a signal is received on interrupt. The generation counts
+// the starts of the command.
+func startProcess(cfg *config, path string, args, env []string, stdin *stdinRelay, generation int, interrupt <-chan os.Signal) (*process, error) {
+	if logFile != nil {
+		if err := logFile.reopen(); err != nil {
+			logger.errorf("%v", err)
+		}
+	}
+	var err error
+	begin := time.Now()
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			logger.debugf("Failed to start %s: %v; retrying in %v", path, err, cfg.retryInterval)
+			timer := time.NewTimer(cfg.retryInterval)
+			select {
+			case <-timer.C:
+			case sig := <-interrupt:
+				timer.Stop()
+				return nil, &interruptedError{sig: sig}
+			}
+		}
+		cmd := exec.Command(path, args...)
+		cmd.Env = env
+		cmd.Dir = cfg.chdir
+		p := &process{cmd: cmd, generation: generation, done: make(chan struct{}), group: cfg.processGroup}
+		if cfg.processGroup {
+			setProcessGroup(cmd)
+		}
+		var pipe io.WriteCloser
+		if cfg.tty {
+			if p.terminal, err = newTerminal(cmd); err != nil {
+				return nil, err
+			}
+		} else {
+			// Keep lines whole, so that the streams are not
+			// interleaved mid-line with each other or the log of the
+			// wrapper. Incomplete lines, such as prompts, are written
+			// once held for lineHold.
+			out, errOut := stdout.source(), stderr.source()
+			if cfg.wrapChildOutput {
+				p.output = []*lineWriter{
+					newJSONWriter(out, "stdout", generation),
+					newJSONWriter(errOut, "stderr", generation),
+				}
+			} else {
+				p.output = []*lineWriter{
+					newPrefixWriter(out, "", styleNone),
+					newPrefixWriter(errOut, "", styleStderr),
+				}
+				p.output[0].hold, p.output[1].hold = lineHold, lineHold
+			}
+			cmd.Stdout, cmd.Stderr = p.output[0], p.output[1]
+			// Redirected streams are written as they are, without
+			// styles or JSON envelopes.
+			if cfg.stdoutTarget.redirected() {
+				if cmd.Stdout, err = p.open(cfg.stdoutTarget); err != nil {
+					return nil, err
+				}
+			}
+			if cfg.stderrTarget.redirected() {
+				if cmd.Stderr, err = p.open(cfg.stderrTarget); err != nil {
+					p.closeFiles()
+					return nil, err
+				}
+			}
+			if cfg.crashTail > 0 {
+				p.stderr = newTailWriter(cfg.crashTail)
+				cmd.Stderr = io.MultiWriter(cmd.Stderr, p.stderr)
+			}
+			if pipe, err = cmd.StdinPipe(); err != nil {
+				p.closeFiles()
+				return nil, err
+			}
+		}
+		if err = cmd.Start(); err == nil {
+			p.started = time.Now()
+			if p.group {
+				if err := p.joinGroup(); err != nil {
+					logger.errorf("Failed to group the processes of process %d: %v", p.pid(), err)
+				}
+			}
+			if p.terminal != nil {
+				p.detachStdin = p.terminal.start(stdin)
+			} else {
+				p.detachStdin = stdin.attach(pipe)
+			}
+			go p.wait()
+			return p, nil
+		}
+		if p.terminal != nil {
+			p.terminal.slave.Close()
+			p.terminal.master.Close()
+		}
+		p.closeFiles()
+		if !retryable(err) {
+			return nil, fmt.Errorf("failed to start %s: %w", path, err)
+		}
+		if cfg.maxAttempts > 0 && attempt >= cfg.maxAttempts ||
+			cfg.retryMaxWait > 0 && time.Since(begin)+cfg.retryInterval > cfg.retryMaxWait {
+			break
+		}
+	}
+	return nil, fmt.Errorf("failed to start %s: the new binary stayed %s for %v; is your build still running? (%v)",
+		path, unavailable(err), time.Since(begin).Round(time.Millisecond), err)
+}
+
+// unavailable describes why a retryable error prevented starting the
+// executable.
+func unavailable(err error) string {
+	var errno syscall.Errno
+	errors.As(err, &errno)
+	switch errno {
+	case syscall.ETXTBSY:
+		return "busy"
+	case syscall.ENOENT:
+		return "missing"
+	case syscall.ENOEXEC:
+		return "incomplete"
+	default:
+		return "unexecutable"
+	}
+}
+
+// retryable reports whether starting the command failed in a way that
+// may succeed once the executable has been completely written.
+func retryable(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	switch errno {
+	case syscall.ETXTBSY, syscall.ENOENT, syscall.ENOEXEC, syscall.EACCES:
+		return true
+	}
+	return false
+}
+
+func (p *process) wait() {
+	err := p.cmd.Wait()
+	for _, w := range p.output {
+		w.flush()
+	}
+	p.detachStdin()
+	if p.terminal != nil {
+		p.terminal.close()
+	}
+	p.closeFiles()
+	p.mu.Lock()
+	p.code = exitCode(err)
+	p.ended = time.Now()
+	p.mu.Unlock()
+	close(p.done)
+}
+
+// open opens the target that a stream of the command is redirected to.
+// Files are opened for each process, so that they can be moved away in
+// the meantime.
+func (p *process) open(t outputTarget) (io.Writer, error) {
+	if t == outputNull {
+		return ioutil.Discard, nil
+	}
+	f, err := os.OpenFile(string(t), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	p.files = append(p.files, f)
+	return f, nil
+}
+
+// closeFiles closes the files that the output of the command is
+// redirected to.
+func (p *process) closeFiles() {
+	for _, f := range p.files {
+		f.Close()
+	}
+	p.files = nil
+}
+
+// pid returns the process ID of the command.
+func (p *process) pid() int {
+	return p.cmd.Process.Pid
+}
+
+// signal sends the signal to the command, or to its process group.
+func (p *process) signal(sig os.Signal) error {
+	if s, ok := sig.(syscall.Signal); ok && p.group {
+		return p.signalGroup(s)
+	}
+	return p.cmd.Process.Signal(sig)
+}
+
+// stop sends the signal to the command and waits for it to exit. If it
+// has not exited within the timeout, or a signal is received on
+// interrupt in the meantime, it is killed. stop returns the signal
+// received on interrupt, if any.
+func (p *process) stop(sig os.Signal, timeout time.Duration, interrupt <-chan os.Signal) os.Signal {
+	select {
+	case <-p.done:
+		if p.group {
+			// Stop any processes left behind in the group.
+			p.signal(sig)
+			p.closeGroup()
+		}
+		return nil
+	default:
+	}
+	if err := p.signal(sig); err != nil {
+		logger.errorf("Failed to signal process %d: %v", p.pid(), err)
+	}
+	received := p.escalate(timeout, interrupt)
+	<-p.done
+	p.closeGroup()
+	return received
+}
+
+// escalate kills the command if it has not exited within the timeout or
+// a signal is received on interrupt, which it returns.
+func (p *process) escalate(timeout time.Duration, interrupt <-chan os.Signal) os.Signal {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+	case <-timer.C:
+		logger.infof("Process %d did not exit within %v; sending SIGKILL", p.pid(), timeout)
+		p.kill()
+	case sig := <-interrupt:
+		logger.infof("Received %v while stopping process %d; sending SIGKILL", sig, p.pid())
+		p.kill()
+		return sig
+	}
+	return nil
+}
+
+// kill kills the command immediately.
+func (p *process) kill() {
+	p.mu.Lock()
+	p.escalated = true
+	p.mu.Unlock()
+	p.signal(syscall.SIGKILL)
+}
+
+// killed reports whether the command had to be killed to stop it.
+func (p *process) killed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.escalated
+}
+
+// uptime returns how long the command ran once it has exited.
+func (p *process) uptime() time.Duration {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ended.Sub(p.started)
+}
+
+// exitCode returns the exit code of the command once it has exited.
+func (p *process) exitCode() int {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.code
+}
+
+// exitCode returns the exit code for the error returned by waiting for a
+// command. A command terminated by a signal has the conventional code of
+// 128 plus the signal number.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 1
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
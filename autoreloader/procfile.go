@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// procfileEnv names the process of the Procfile that a wrapper started
+// by -f supervises, and procfileColorEnv tells it to color its output.
+const (
+	procfileEnv      = "AUTORELOADER_PROCESS"
+	procfileColorEnv = "AUTORELOADER_PROCESS_COLOR"
+)
+
+// processName is the name of the process of the Procfile that the
+// wrapper supervises, if any. It is included in JSON logs.
+var processName = os.Getenv(procfileEnv)
+
+// procfileEntry is a process of a Procfile.
+type procfileEntry struct {
+	name    string
+	command []string
+	watch   []string
+	ignore  []string
+}
+
+// procfileLine matches a line of a Procfile: the name of a process, or
+// that of one of its options, and the value.
+var procfileLine = regexp.MustCompile(`^([A-Za-z0-9_-]+)(?:\.(watch|ignore))?:\s*(.*)$`)
+
+// readProcfile reads the processes of the Procfile. Each line is a name
+// and the command of the process, such as "api: ./bin/api --port 8000".
+// Lines such as "api.watch: ./templates" and "api.ignore: *.tmp" add
+// space-separated paths to watch, and globs to ignore, for a process.
+// Blank lines and those starting with # are skipped.
+func readProcfile(path string) ([]procfileEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []procfileEntry
+	options := map[string][]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := procfileLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("%s:%d: must be name: command", path, n)
+		}
+		words, err := splitWords(m[3])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("%s:%d: %s has no value", path, n, m[1])
+		}
+		if m[2] != "" {
+			key := m[1] + "." + m[2]
+			options[key] = append(options[key], words...)
+			continue
+		}
+		for _, e := range entries {
+			if e.name == m[1] {
+				return nil, fmt.Errorf("%s:%d: %s is defined twice", path, n, m[1])
+			}
+		}
+		entries = append(entries, procfileEntry{name: m[1], command: words})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: no processes", path)
+	}
+
+	for i := range entries {
+		e := &entries[i]
+		e.watch, e.ignore = options[e.name+".watch"], options[e.name+".ignore"]
+		delete(options, e.name+".watch")
+		delete(options, e.name+".ignore")
+	}
+	for key := range options {
+		return nil, fmt.Errorf("%s: %s is not a process", path, strings.SplitN(key, ".", 2)[0])
+	}
+	return entries, nil
+}
+
+// splitWords splits the command line into words as a shell would,
+// honoring single and double quotes and backslash escapes, but without
+// expanding anything.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// useProcfileEntry configures the wrapper, started by -f, to supervise
+// the process of the Procfile named by procfileEnv.
+func (cfg *config) useProcfileEntry(name string) error {
+	for _, e := range cfg.procfileEntries {
+		if e.name == name {
+			cfg.command = e.command
+			cfg.watch = append(cfg.watch, e.watch...)
+			cfg.ignore = append(cfg.ignore, e.ignore...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a process of %s", name, cfg.procfile)
+}
+
+// procfileExit is the exit of the wrapper of a process of the Procfile.
+type procfileExit struct {
+	index int
+	code  int
+}
+
+// runProcfile supervises each process of the Procfile, with -f, by a
+// wrapper of its own started with the same flags. The output of each
+// wrapper is prefixed by the name of its process. Signals are relayed to
+// every wrapper, which stops its process as usual. It returns the exit
+// code of the first wrapper that failed, with --exit-on-failure, after
+// stopping the others, or else that of the first to exit once stopped.
+func runProcfile(cfg *config) int {
+	self, err := os.Executable()
+	if err != nil {
+		logger.errorf("Cannot find the autoreloader executable: %v", err)
+		return 1
+	}
+	width := 0
+	for _, e := range cfg.procfileEntries {
+		if len(e.name) > width {
+			width = len(e.name)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	exits := make(chan procfileExit, len(cfg.procfileEntries))
+	var cmds []*exec.Cmd
+	exited := map[int]bool{}
+	stop := func(sig os.Signal) {
+		for i, cmd := range cmds {
+			if !exited[i] {
+				cmd.Process.Signal(sig)
+			}
+		}
+	}
+	for i, e := range cfg.procfileEntries {
+		s := nameStyles[i%len(nameStyles)]
+		prefix := fmt.Sprintf("%-*s | ", width, e.name)
+		if cfg.logFormat == formatJSON {
+			// Lines are told apart by their process field instead.
+			prefix = ""
+		}
+		out, errOut := newNameWriter(stdout, prefix, s), newNameWriter(stderr, prefix, s)
+
+		cmd := exec.Command(self, os.Args[1:]...)
+		cmd.Env = append(os.Environ(), procfileEnv+"="+e.name)
+		if colors {
+			cmd.Env = append(cmd.Env, procfileColorEnv+"=1")
+		}
+		cmd.Stdout, cmd.Stderr = out, errOut
+		// Keep signals from the terminal from reaching the wrappers
+		// twice.
+		setProcessGroup(cmd)
+		if err := cmd.Start(); err != nil {
+			logger.errorf("Failed to start %s: %v", e.name, err)
+			stop(syscall.SIGTERM)
+			for range cmds {
+				<-exits
+			}
+			return 1
+		}
+		logger.debugf("Started %s with process %d", e.name, cmd.Process.Pid)
+		cmds = append(cmds, cmd)
+		go func(i int) {
+			code := exitCode(cmd.Wait())
+			out.flush()
+			errOut.flush()
+			exits <- procfileExit{index: i, code: code}
+		}(i)
+	}
+
+	code := 0
+	stopping := false
+	for running := len(cmds); running > 0; {
+		select {
+		case sig := <-signals:
+			stopping = true
+			stop(sig)
+		case x := <-exits:
+			running--
+			exited[x.index] = true
+			name := cfg.procfileEntries[x.index].name
+			switch {
+			case stopping:
+				logger.debugf("%s exited with code %d", name, x.code)
+				if code == 0 {
+					code = x.code
+				}
+			case x.code != 0 && cfg.exitOnFailure:
+				logger.errorf("%s exited with code %d; stopping", name, x.code)
+				stopping, code = true, x.code
+				stop(syscall.SIGTERM)
+			default:
+				logger.infof("%s exited with code %d", name, x.code)
+			}
+		}
+	}
+	return code
+}
+
+// newNameWriter returns a lineWriter that writes each line with the
+// name of its process as a prefix, in the style.
+func newNameWriter(w io.Writer, prefix string, s style) *lineWriter {
+	return &lineWriter{w: w, format: func(line []byte) []byte {
+		return append([]byte(s.paint(prefix)), line...)
+	}}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultReadyTimeout is how long to wait for the command to accept
+// connections when no timeout is given.
+const defaultReadyTimeout = 30 * time.Second
+
+// tcpProbe is an address that the command accepts connections on once
+// it is ready.
+type tcpProbe struct {
+	addr    string
+	timeout time.Duration
+}
+
+func (p *tcpProbe) String() string {
+	if p.addr == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s,%v", p.addr, p.timeout)
+}
+
+func (p *tcpProbe) Set(s string) error {
+	addr, timeout := s, defaultReadyTimeout
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		d, err := time.ParseDuration(s[i+1:])
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q: must be a positive duration", s[i+1:])
+		}
+		addr, timeout = s[:i], d
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("must be host:port[,timeout]: %v", err)
+	}
+	p.addr, p.timeout = addr, timeout
+	return nil
+}
+
+// readiness is the outcome of waiting for a process to be ready.
+type readiness int
+
+const (
+	ready readiness = iota
+	unready
+	exitedUnready
+	interruptedUnready
+)
+
+// awaitReady waits for the command to accept connections on the address
+// of --wait-tcp. If it does not within the timeout, a warning is logged,
+// or with --fail-on-unready, the command is stopped and awaitReady
+// reports that the wrapper stopped along with its exit code. A signal
+// received while waiting is left for the supervisor to handle.
+func (s *supervisor) awaitReady() (int, bool) {
+	probe := s.cfg.waitTCP
+	switch s.probeReady(s.proc) {
+	case unready:
+		if !s.cfg.failOnUnready {
+			logger.errorf("Process %d is not accepting connections on %s after %v; leaving it running", s.proc.pid(), probe.addr, probe.timeout)
+			return 0, false
+		}
+		logger.errorf("Process %d is not accepting connections on %s after %v; stopping it", s.proc.pid(), probe.addr, probe.timeout)
+		s.proc.stop(s.cfg.killSignal, s.cfg.killTimeout, s.signals)
+		return 1, true
+	case exitedUnready:
+		logger.errorf("Process %d exited before accepting connections on %s", s.proc.pid(), probe.addr)
+	}
+	return 0, false
+}
+
+// probeReady waits up to the timeout of --wait-tcp for the process to
+// accept connections on its address, or for processes of the command to
+// listen on its port where that can be told, since another process may
+// accept connections on the same address with --overlap. A signal
+// received while waiting is left in pendingSignal for the supervisor to
+// handle.
+func (s *supervisor) probeReady(p *process) readiness {
+	probe := s.cfg.waitTCP
+	if probe.addr == "" {
+		return ready
+	}
+	port := 0
+	if _, portStr, err := net.SplitHostPort(probe.addr); err == nil {
+		port, _ = strconv.Atoi(portStr)
+	}
+	begin := time.Now()
+	deadline := time.NewTimer(probe.timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if s.accepting(p, probe.addr, port) {
+			logger.debugf("Process %d accepted a connection on %s after %v", p.pid(), probe.addr, time.Since(begin).Round(time.Millisecond))
+			return ready
+		}
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return exitedUnready
+		case sig := <-s.signals:
+			s.pendingSignal = sig
+			return interruptedUnready
+		case <-deadline.C:
+			return unready
+		}
+	}
+}
+
+// accepting reports whether the process accepts connections on the
+// address. With --overlap, the process, or a process in its group, must
+// also listen on the port, where that can be told.
+func (s *supervisor) accepting(p *process, addr string, port int) bool {
+	conn, err := net.DialTimeout("tcp", addr, 250*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	if !s.cfg.overlap || port == 0 {
+		return true
+	}
+	listening, ok := listeningOn(p.pid(), port)
+	return listening || !ok
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// signalRule sends a signal to the command, rather than restarting it,
+// when a path beneath path changes.
+type signalRule struct {
+	path string
+	sig  syscall.Signal
+}
+
+// signalRules is a flag.Value that collects PATH=SIGNAL rules.
+type signalRules []signalRule
+
+func (v *signalRules) String() string {
+	rules := make([]string, len(*v))
+	for i, r := range *v {
+		rules[i] = fmt.Sprintf("%s=%s", r.path, signalName(r.sig))
+	}
+	return strings.Join(rules, ",")
+}
+
+func (v *signalRules) Set(s string) error {
+	i := strings.LastIndexByte(s, '=')
+	if i <= 0 {
+		return fmt.Errorf("must be PATH=SIGNAL")
+	}
+	sig, err := parseSignal(s[i+1:])
+	if err != nil {
+		return err
+	}
+	path, err := filepath.Abs(s[:i])
+	if err != nil {
+		return err
+	}
+	*v = append(*v, signalRule{path: path, sig: sig})
+	return nil
+}
+
+// match returns the signal of the rule that the path lies beneath.
+func (v signalRules) match(path string) (syscall.Signal, bool) {
+	for _, r := range v {
+		if path == r.path || strings.HasPrefix(path, r.path+string(filepath.Separator)) {
+			return r.sig, true
+		}
+	}
+	return 0, false
+}
+
+// changeSignals returns the signals to send to the command for the
+// changed paths, with --signal-only or --on, or nil if the command must
+// be restarted because a path that is not signaled for changed.
+func (s *supervisor) changeSignals(paths []string) []syscall.Signal {
+	if s.cfg.signalOnly != 0 {
+		return []syscall.Signal{syscall.Signal(s.cfg.signalOnly)}
+	}
+	if len(s.cfg.signalRules) == 0 {
+		return nil
+	}
+	var sigs []syscall.Signal
+	for _, path := range paths {
+		sig, ok := s.cfg.signalRules.match(path)
+		if !ok {
+			return nil
+		}
+		if !containsSignal(sigs, sig) {
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs
+}
+
+func containsSignal(sigs []syscall.Signal, sig syscall.Signal) bool {
+	for _, s := range sigs {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// signalChange sends the signals to the command for the change, rather
+// than restarting it.
+func (s *supervisor) signalChange(change logEvent, sigs []syscall.Signal) {
+	if s.exited {
+		logger.event(levelDefault, change, "%s; process %d is not running", s.reason, s.proc.pid())
+		return
+	}
+	for _, sig := range sigs {
+		logger.event(levelDefault, change, "%s; sending SIG%s to process %d", s.reason, signalName(sig), s.proc.pid())
+		if err := s.proc.signal(sig); err != nil {
+			logger.errorf("Failed to send SIG%s to process %d: %v", signalName(sig), s.proc.pid(), err)
+			continue
+		}
+		logger.event(levelVerbose, s.procEvent("signal"), "Sent SIG%s to process %d", signalName(sig), s.proc.pid())
+		s.board.signaled(sig, s.reason, time.Now())
+	}
+}
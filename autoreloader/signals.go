@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// forwardedSignals are the signals that are relayed to the command.
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// stopsWrapper reports whether the signal stops the wrapper along with
+// the command. The other forwarded signals, such as SIGHUP, are only
+// passed on to the command.
+func stopsWrapper(sig os.Signal) bool {
+	return sig == syscall.SIGINT || sig == syscall.SIGTERM
+}
+
+// parseSignal parses a signal name, with or without the SIG prefix, or
+// number.
+func parseSignal(s string) (syscall.Signal, error) {
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	if sig, ok := signalNames[name]; ok {
+		return sig, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+
+	names := make([]string, 0, len(signalNames))
+	for name := range signalNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("invalid signal %q: must be a number or one of %s", s, strings.Join(names, ", "))
+}
+
+// signalName returns the name of the signal without the SIG prefix, or
+// its number if it has no accepted name.
+func signalName(sig syscall.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// signalValue is a flag.Value that parses a signal.
+type signalValue syscall.Signal
+
+func (v *signalValue) String() string {
+	return signalName(syscall.Signal(*v))
+}
+
+func (v *signalValue) Set(s string) error {
+	sig, err := parseSignal(s)
+	if err != nil {
+		return err
+	}
+	*v = signalValue(sig)
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "syscall"
+
+// signalNames maps the accepted signal names to signals.
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
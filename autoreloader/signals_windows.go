@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "syscall"
+
+// signalNames maps the accepted signal names to signals. Windows has no
+// user-defined signals.
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// stdinRelay feeds the standard input of the wrapper to one process at
+// a time. Input read while no process is attached, such as during a
+// restart, is held for the next process. Once the input reaches EOF,
+// the standard input of every process is closed. If keys are used, they
+// are removed from the input first.
+type stdinRelay struct {
+	chunks chan []byte
+	keys   *keyFilter
+
+	// pending and eof are only accessed by the attached process.
+	pending []byte
+	eof     bool
+}
+
+func newStdinRelay(r io.Reader, keys chan<- byte) *stdinRelay {
+	relay := &stdinRelay{chunks: make(chan []byte, 64)}
+	if keys != nil {
+		relay.keys = &keyFilter{keys: keys}
+	}
+	go relay.read(r)
+	return relay
+}
+
+func (r *stdinRelay) read(in io.Reader) {
+	defer close(r.chunks)
+	if r.keys != nil {
+		defer close(r.keys.keys)
+	}
+	for {
+		buf := make([]byte, 32*1024)
+		n, err := in.Read(buf)
+		chunk := buf[:n]
+		if r.keys != nil {
+			chunk = r.keys.filter(chunk)
+		}
+		if len(chunk) > 0 {
+			r.chunks <- chunk
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// attach feeds the input to w until the returned function is first
+// called or writing fails. w is closed when the input reaches EOF.
+func (r *stdinRelay) attach(w io.WriteCloser) (detach func()) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if r.pending != nil {
+				if _, err := w.Write(r.pending); err != nil {
+					return
+				}
+				r.pending = nil
+			}
+			if r.eof {
+				w.Close()
+				return
+			}
+			select {
+			case <-stop:
+				return
+			case chunk, ok := <-r.chunks:
+				if !ok {
+					r.eof = true
+					continue
+				}
+				r.pending = chunk
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+		})
+	}
+}
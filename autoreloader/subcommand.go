@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// subcommands are the commands that control a running wrapper. They are
+// run as "autoreloader <name> [flags]"; use -- to supervise a command
+// of the same name.
+var subcommands = map[string]func(args []string) int{
+	"status":  statusCommand,
+	"stop":    stopCommand,
+	"restart": restartCommand,
+}
+
+// controlFlags parses the flags of a subcommand that locate the control
+// socket of the running wrapper.
+type controlFlags struct {
+	fs      *flag.FlagSet
+	cfg     config
+	timeout time.Duration
+}
+
+func newControlFlags(name string, timeout time.Duration) *controlFlags {
+	c := &controlFlags{fs: flag.NewFlagSet("autoreloader "+name, flag.ContinueOnError)}
+	c.fs.StringVar(&c.cfg.socket, "socket", "", "control socket of the autoreloader")
+	c.fs.StringVar(&c.cfg.pidfile, "pidfile", "", "pidfile of the autoreloader, next to which its control socket is")
+	c.fs.DurationVar(&c.timeout, "timeout", timeout, "how long to wait for the autoreloader to answer")
+	return c
+}
+
+// parse parses the arguments and returns the path of the control socket.
+func (c *controlFlags) parse(args []string) (string, bool) {
+	if err := c.fs.Parse(args); err != nil {
+		return "", false
+	}
+	path := c.cfg.controlSocket()
+	if path == "" {
+		fmt.Fprintln(c.fs.Output(), "must supply --socket or --pidfile")
+		return "", false
+	}
+	return path, true
+}
+
+// statusCommand prints the status of the running wrapper.
+func statusCommand(args []string) int {
+	c := newControlFlags("status", 5*time.Second)
+	asJSON := c.fs.Bool("json", false, "print the status as JSON")
+	path, ok := c.parse(args)
+	if !ok {
+		return 2
+	}
+
+	var st controlStatus
+	if err := requestControl(path, "status", c.timeout, &st); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(st)
+		return 0
+	}
+
+	state := "running"
+	if !st.Running {
+		state = "exited"
+	}
+	fmt.Printf("pid:          %d (%s)\n", st.PID, state)
+	fmt.Printf("uptime:       %s\n", st.Uptime)
+	fmt.Printf("generation:   %d\n", st.Generation)
+	if st.LastRestartTime != nil {
+		fmt.Printf("last restart: %s (%s)\n", st.LastRestartTime.Format(time.RFC3339), st.LastRestartReason)
+	} else {
+		fmt.Printf("last restart: never\n")
+	}
+	if st.PreviousUptime != "" {
+		fmt.Printf("restarts:     %d (uptime of previous: %s)\n", st.Restarts, st.PreviousUptime)
+	} else {
+		fmt.Printf("restarts:     %d\n", st.Restarts)
+	}
+	if st.LastSignalTime != nil {
+		fmt.Printf("last signal:  %s (%s, %s; %d in total)\n", st.LastSignalTime.Format(time.RFC3339), st.LastSignal, st.LastSignalReason, st.Signals)
+	}
+	if st.MaxRestarts > 0 {
+		fmt.Printf("recent:       %d of %d per %s\n", st.RecentRestarts, st.MaxRestarts, st.RestartWindow)
+	}
+	if st.ThrottledUntil != nil {
+		fmt.Printf("throttled:    until %s\n", st.ThrottledUntil.Format(time.RFC3339))
+	}
+	fmt.Printf("watching:     %s\n", strings.Join(st.WatchPaths, ", "))
+	return 0
+}
+
+// stopCommand stops the running wrapper and its command.
+func stopCommand(args []string) int {
+	c := newControlFlags("stop", 30*time.Second)
+	path, ok := c.parse(args)
+	if !ok {
+		return 2
+	}
+	var reply controlReply
+	if err := requestControl(path, "stop", c.timeout, &reply); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if reply.ExitCode != nil {
+		fmt.Printf("Stopped; the command exited with code %d\n", *reply.ExitCode)
+	}
+	return 0
+}
+
+// restartCommand restarts the command of the running wrapper, as if its
+// executable had changed.
+func restartCommand(args []string) int {
+	c := newControlFlags("restart", 30*time.Second)
+	path, ok := c.parse(args)
+	if !ok {
+		return 2
+	}
+	var reply controlReply
+	if err := requestControl(path, "restart", c.timeout, &reply); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Restarted; generation %d\n", reply.Generation)
+	return 0
+}
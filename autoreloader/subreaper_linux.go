@@ -0,0 +1,12 @@
+//go:build linux
+// +build linux
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// becomeSubreaper makes orphaned descendants reparent to the wrapper
+// rather than to the init process, as they do when it is PID 1.
+func becomeSubreaper() error {
+	return unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0)
+}
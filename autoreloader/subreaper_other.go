@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+// becomeSubreaper is a no-op, as only PID 1 can reap orphaned
+// descendants on this platform.
+func becomeSubreaper() error {
+	return nil
+}
@@ -0,0 +1,959 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// supervisor runs the command and restarts it when its executable
+// changes.
+type supervisor struct {
+	cfg     *config
+	path    string
+	proc    *process
+	build   *build
+	stdin   *stdinRelay
+	signals chan os.Signal
+
+	// exited reports whether the exit of proc has been handled.
+	exited bool
+
+	// unbuilt reports whether the executable did not exist yet when the
+	// wrapper started, so that the command is only started once it does.
+	unbuilt bool
+
+	// target is the file that the executable resolves to, if its path
+	// is a symlink. Both are watched: the symlink for when it is made
+	// to refer to another file, and the target for its changes.
+	target string
+
+	// signaled reports whether the wrapper was stopped by a signal, and
+	// changed whether it stopped due to a change, with --exit-on-change.
+	signaled    bool
+	exitChanged bool
+
+	// crashes counts the consecutive unexpected exits of the command,
+	// and backoff delays restarting it after one.
+	crashes int
+	backoff *time.Timer
+
+	// binary describes the executable of the running command.
+	binary binaryInfo
+
+	// changed holds the paths whose change caused the pending restart,
+	// which are substituted for the placeholders of the build and hook
+	// commands.
+	changed []string
+
+	// reason describes why the command is restarted, and board holds
+	// the status reported over the control socket.
+	reason string
+	board  statusBoard
+
+	// cause classifies the reason for the metrics: change, manual or
+	// crash.
+	cause string
+
+	// latency measures the steps of the pending restart.
+	latency latency
+
+	// throttle limits how often changes restart the command.
+	throttle throttle
+
+	// counters counts the restarts of the command.
+	counters counters
+
+	// restartedOnce reports whether the command was restarted, with
+	// --once, after which once stops it when it ran for --once-timeout.
+	restartedOnce bool
+	once          *time.Timer
+
+	// paused reports whether restarting on changes is paused, with the
+	// keys, and pending whether there were changes in the meantime.
+	paused  bool
+	pending bool
+
+	// pendingSignal is a signal received while waiting for the new
+	// process of an overlapped restart, which is handled next.
+	pendingSignal os.Signal
+
+	// requests receives the requests over the control socket.
+	requests chan controlRequest
+
+	// webhooks tracks the events being posted to --webhook.
+	webhooks sync.WaitGroup
+}
+
+func newSupervisor(cfg *config, path string) *supervisor {
+	s := &supervisor{
+		cfg:      cfg,
+		path:     path,
+		signals:  make(chan os.Signal, 1),
+		requests: make(chan controlRequest),
+		throttle: throttle{max: cfg.maxRestarts, per: cfg.restartWindow},
+		counters: loadCounters(cfg),
+	}
+	s.board.watchPaths = s.watchPaths()
+	s.board.maxRestarts, s.board.restartWindow = cfg.maxRestarts, cfg.restartWindow
+	s.board.restarts = s.counters.Restarts
+
+	// Keys are only read from a terminal.
+	var keys chan byte
+	if isTerminal(os.Stdin) {
+		keys = make(chan byte, 8)
+		go s.handleKeys(keys)
+	}
+	s.stdin = newStdinRelay(os.Stdin, keys)
+	return s
+}
+
+// run supervises the command until it exits or the wrapper is stopped
+// by a signal. It returns the exit code of the wrapper, as decided by
+// the exit code policy.
+func (s *supervisor) run() int {
+	code := s.supervise()
+	s.webhooks.Wait()
+	if s.exitChanged && !s.signaled {
+		return s.cfg.exitOnChange.code
+	}
+	return s.cfg.exitPolicy.code(code, s.signaled)
+}
+
+// supervise supervises the command until it exits or the wrapper is
+// stopped by a signal. It returns the exit code of the command.
+func (s *supervisor) supervise() int {
+	signal.Notify(s.signals, forwardedSignals...)
+	defer signal.Stop(s.signals)
+
+	if s.cfg.goPkg == "" {
+		_, err := os.Stat(s.path)
+		s.unbuilt = os.IsNotExist(err)
+		s.target = linkTarget(s.path)
+	}
+	logger.debugf("Watching %s with a delay of %v", strings.Join(s.watchPaths(), ", "), s.cfg.delay)
+
+	var w *watcher
+	if s.cfg.waitFirstChange || s.cfg.goPkg != "" || s.unbuilt {
+		var err error
+		if w, err = newWatcher(s.cfg, s.watchPaths()...); err != nil {
+			logger.errorf("Failed to watch %s: %v", s.path, err)
+			return 1
+		}
+		defer w.close()
+		if (s.cfg.goPkg != "" || s.unbuilt && s.cfg.build != "") && !s.cfg.waitFirstChange {
+			// Build the command before starting it.
+			s.startBuild()
+		}
+		if code, stopped := s.awaitFirstChange(w); stopped {
+			return code
+		}
+	}
+
+	if w == nil {
+		// Watch before starting the command, so that no change is
+		// missed. The events that starting it triggers are ignored, as
+		// the executable is unchanged.
+		var err error
+		if w, err = newWatcher(s.cfg, s.watchPaths()...); err != nil {
+			logger.errorf("Failed to watch %s: %v", s.path, err)
+			return 1
+		}
+		defer w.close()
+	}
+
+	env, err := s.cfg.environ()
+	if err != nil {
+		logger.errorf("%v", err)
+		return 1
+	}
+	args, err := s.cfg.args(env)
+	if err != nil {
+		logger.errorf("%v", err)
+		return 1
+	}
+	s.binary = readBinaryInfo(s.path)
+	proc, err := startProcess(s.cfg, s.path, args, env, s.stdin, 1, s.signals)
+	if err != nil {
+		logger.errorf("%v", err)
+		return 1
+	}
+	s.proc = proc
+	s.board.started(proc, "")
+	logger.event(levelDefault, s.procEvent("start"), "Started process %d", proc.pid())
+	if code, stopped := s.awaitReady(); stopped {
+		return code
+	}
+
+	for {
+		if sig := s.pendingSignal; sig != nil {
+			s.pendingSignal = nil
+			if code, stopped := s.handleSignal(w, sig); stopped {
+				return code
+			}
+			continue
+		}
+		select {
+		case c := <-w.changes:
+			paths := c.paths
+			s.retarget(w, paths)
+			if s.spurious(paths) {
+				logger.debugf("%s, but the executable is unchanged; ignoring", s.describeChanges(paths))
+				continue
+			}
+			if s.restartedOnce {
+				logger.debugf("%s; ignoring, as the command was restarted once", s.describeChanges(paths))
+				continue
+			}
+			s.changed, s.reason, s.cause = paths, s.describeChanges(paths), "change"
+			s.latency = latency{changed: c.first, settle: time.Since(c.first)}
+			change := logEvent{Event: "change", Path: paths[0]}
+			if sigs := s.changeSignals(paths); sigs != nil {
+				s.signalChange(change, sigs)
+				continue
+			}
+			if s.paused {
+				logger.event(levelDefault, change, "%s; paused", s.describeChanges(paths))
+				s.pending = true
+				continue
+			}
+			if code, stopped := s.applyChange(change); stopped {
+				return code
+			}
+		case err := <-s.buildDone():
+			if !s.finishBuild(err) {
+				continue
+			}
+			if s.cfg.exitOnChange.enabled {
+				return s.exitForChange()
+			}
+			if s.throttled() {
+				continue
+			}
+			if code, stopped := s.restart(); stopped {
+				return code
+			}
+		case <-s.throttle.done():
+			s.throttle.timer = nil
+			s.latency = latency{}
+			logger.infof("Resuming restarts; %s", s.reason)
+			if s.throttled() {
+				continue
+			}
+			if code, stopped := s.restart(); stopped {
+				return code
+			}
+		case sig := <-s.signals:
+			if code, stopped := s.handleSignal(w, sig); stopped {
+				return code
+			}
+		case req := <-s.requests:
+			if code, stopped := s.control(w, req); stopped {
+				return code
+			}
+		case <-s.procDone():
+			s.exited = true
+			s.reportCrash()
+			if s.crashedAfterRestart() {
+				continue
+			}
+			if !s.cfg.restartOnExit || !s.scheduleRestart() {
+				s.cancelBuild()
+				logger.event(levelDefault, s.exitEvent(), "Process %d exited with code %d", s.proc.pid(), s.proc.exitCode())
+				return s.proc.exitCode()
+			}
+		case <-s.onceDone():
+			logger.infof("Process %d still running after %v; stopping", s.proc.pid(), s.cfg.onceTimeout)
+			s.proc.stop(s.cfg.killSignal, s.cfg.killTimeout, s.signals)
+			logger.event(levelDefault, s.exitEvent(), "Process %d exited with code %d", s.proc.pid(), s.proc.exitCode())
+			return 0
+		case <-s.backoffDone():
+			// The restart also applies a change pending while paused.
+			s.changed, s.reason, s.cause = nil, fmt.Sprintf("process exited with code %d", s.proc.exitCode()), "crash"
+			s.pending = false
+			s.latency = latency{}
+			if code, stopped := s.restart(); stopped {
+				return code
+			}
+		}
+	}
+}
+
+// spurious reports whether the changed paths are only the executable,
+// which is still the one that was started, such as for the events that
+// starting it triggers.
+func (s *supervisor) spurious(paths []string) bool {
+	if s.cfg.build != "" || !s.binary.unchanged(s.path) {
+		return false
+	}
+	for _, path := range paths {
+		if path != s.path && path != s.target {
+			return false
+		}
+	}
+	return true
+}
+
+// applyChange builds and restarts the command for the change, or exits
+// with --exit-on-change. It reports whether the wrapper stopped along
+// with the exit code of the command.
+func (s *supervisor) applyChange(change logEvent) (int, bool) {
+	if s.cfg.build != "" {
+		logger.event(levelDefault, change, "%s; building", s.reason)
+		s.startBuild()
+		return 0, false
+	}
+	if s.cfg.exitOnChange.enabled {
+		logger.event(levelDefault, change, "%s; exiting", s.reason)
+		return s.exitForChange(), true
+	}
+	logger.event(levelDefault, change, "%s; restarting", s.reason)
+	if s.throttled() {
+		return 0, false
+	}
+	return s.restart()
+}
+
+// unbuiltError returns why the executable, which did not exist when the
+// wrapper started, is not yet a valid one, if it is not.
+func (s *supervisor) unbuiltError() error {
+	if !s.unbuilt {
+		return nil
+	}
+	return validateExecutable(s.path)
+}
+
+// control acts on the request over the control socket and replies to
+// it. It reports whether the wrapper stopped along with the exit code of
+// the command.
+func (s *supervisor) control(w *watcher, req controlRequest) (int, bool) {
+	switch req.command {
+	case "restart":
+		logger.infof("Restart requested; restarting")
+		s.changed, s.reason, s.cause = nil, "restart requested", "manual"
+		s.pending = false
+		s.latency = latency{}
+		s.throttle.reset()
+		s.board.throttled(0, time.Time{})
+		generation := s.proc.generation
+		code, stopped := s.restart()
+		switch {
+		case stopped:
+			req.reply <- controlReply{Error: "stopped while restarting"}
+			return code, true
+		case s.proc.generation == generation:
+			req.reply <- controlReply{Error: fmt.Sprintf("restart failed; %s", s.keeping())}
+		default:
+			req.reply <- controlReply{Generation: s.proc.generation}
+		}
+	case "stop":
+		logger.infof("Stop requested; stopping")
+		code := s.shutdown(w, s.cfg.killSignal)
+		req.reply <- controlReply{ExitCode: &code}
+		return code, true
+	case "pause":
+		req.reply <- controlReply{Generation: s.proc.generation}
+		s.paused = !s.paused
+		if s.paused {
+			logger.infof("Restarts paused; press Ctrl-] p to resume")
+			return 0, false
+		}
+		logger.infof("Restarts resumed")
+		if !s.pending || len(s.changed) == 0 {
+			s.pending = false
+			return 0, false
+		}
+		s.pending = false
+		s.latency = latency{}
+		return s.applyChange(logEvent{Event: "change", Path: s.changed[0]})
+	default:
+		req.reply <- controlReply{Error: fmt.Sprintf("unknown command %q", req.command)}
+	}
+	return 0, false
+}
+
+// awaitFirstChange waits for the first change before the command is
+// started, with --wait-first-change, building the command first if a
+// build command is used. With --go, or if the executable does not exist
+// yet, it instead waits for the initial build to succeed, or without a
+// build command, for the executable to be created. If the wrapper is
+// stopped by a signal in the meantime, it reports that it stopped along
+// with the conventional exit code for the signal.
+func (s *supervisor) awaitFirstChange(w *watcher) (int, bool) {
+	switch {
+	case s.unbuilt && !s.cfg.waitFirstChange:
+		logger.infof("Waiting for %s to be built...", displayPath(s.path))
+	case s.build == nil:
+		logger.infof("Waiting for the first change to %s", strings.Join(s.watchPaths(), ", "))
+	}
+	for {
+		select {
+		case c := <-w.changes:
+			paths := c.paths
+			s.retarget(w, paths)
+			s.changed, s.reason = paths, s.describeChanges(paths)
+			change := logEvent{Event: "change", Path: paths[0]}
+			if s.cfg.build == "" {
+				if err := s.unbuiltError(); err != nil {
+					logger.debugf("%s; not starting yet: %v", s.describeChanges(paths), err)
+					continue
+				}
+				logger.event(levelDefault, change, "%s; starting", s.describeChanges(paths))
+				return 0, false
+			}
+			logger.event(levelDefault, change, "%s; building", s.describeChanges(paths))
+			s.startBuild()
+		case err := <-s.buildDone():
+			if s.finishBuild(err) {
+				return 0, false
+			}
+		case req := <-s.requests:
+			if req.command != "stop" {
+				req.reply <- controlReply{Error: "the command has not started yet"}
+				continue
+			}
+			logger.infof("Stop requested; exiting")
+			s.cancelBuild()
+			req.reply <- controlReply{}
+			return 0, true
+		case sig := <-s.signals:
+			logger.infof("Received %v before starting; exiting", sig)
+			s.signaled = true
+			s.cancelBuild()
+			if sig, ok := sig.(syscall.Signal); ok {
+				return 128 + int(sig), true
+			}
+			return 1, true
+		}
+	}
+}
+
+// finishBuild reports the result of the build that is done and whether
+// it succeeded.
+func (s *supervisor) finishBuild(err error) bool {
+	b := s.build
+	s.build = nil
+	s.latency.build = time.Since(b.started)
+	s.board.built(err == nil, s.latency.build)
+	finish := logEvent{Event: "build_finish"}.withExitCode(exitCode(err)).withDuration(s.latency.build)
+	if err != nil {
+		failure := b.failure(err)
+		logger.event(levelQuiet, finish, "BUILD FAILED: %v; %s", failure, s.keeping())
+		s.notify(fmt.Sprintf("Build failed: %v", failure))
+		s.postWebhook(finish, fmt.Sprintf("Build failed: %v", failure))
+		return false
+	}
+	if err := s.unbuiltError(); err != nil && s.proc == nil {
+		logger.event(levelQuiet, finish, "Build succeeded, but %v; %s", err, s.keeping())
+		return false
+	}
+	switch {
+	case s.proc == nil:
+		logger.event(levelDefault, finish, "Build succeeded; starting")
+	case s.cfg.exitOnChange.enabled:
+		logger.event(levelDefault, finish, "Build succeeded; exiting")
+	default:
+		logger.event(levelDefault, finish, "Build succeeded; restarting")
+	}
+	return true
+}
+
+// keeping describes what becomes of the command when it cannot be
+// restarted.
+func (s *supervisor) keeping() string {
+	if s.proc == nil || s.exited {
+		return "waiting for the next change"
+	}
+	return fmt.Sprintf("keeping process %d running", s.proc.pid())
+}
+
+// procDone returns a channel that is closed when the command exits, or
+// nil if its exit has already been handled.
+func (s *supervisor) procDone() <-chan struct{} {
+	if s.exited {
+		return nil
+	}
+	return s.proc.done
+}
+
+// scheduleRestart schedules restarting the command after it exited
+// unexpectedly. The delay doubles with every consecutive crash, up to the
+// maximum backoff, and is reset once the command has stayed up for the
+// maximum backoff. It reports false if the command has crashed too many
+// times.
+func (s *supervisor) scheduleRestart() bool {
+	uptime := s.proc.uptime()
+	if uptime >= s.cfg.backoffMax {
+		s.crashes = 0
+	}
+	s.crashes++
+	if s.proc.exitCode() == 0 {
+		// Failures are reported with a crash summary.
+		logger.event(levelDefault, s.exitEvent().withDuration(uptime), "Process %d exited with code 0 after %v", s.proc.pid(), uptime.Round(time.Millisecond))
+	}
+	if s.cfg.maxCrashes > 0 && s.crashes > s.cfg.maxCrashes {
+		logger.errorf("Process crashed %d times in a row; giving up", s.crashes)
+		return false
+	}
+
+	delay := s.cfg.backoffBase
+	for i := 1; i < s.crashes && delay < s.cfg.backoffMax; i++ {
+		delay *= 2
+	}
+	if delay > s.cfg.backoffMax {
+		delay = s.cfg.backoffMax
+	}
+	logger.infof("Restarting in %v", delay)
+	s.backoff = time.NewTimer(delay)
+	return true
+}
+
+// crashedAfterRestart reports whether, with --no-restart-on-error, the
+// command failed shortly after it was restarted due to a change. The
+// command is then not restarted until the next change.
+func (s *supervisor) crashedAfterRestart() bool {
+	if !s.cfg.noRestartOnError || s.proc.generation == 1 || s.proc.exitCode() == 0 {
+		return false
+	}
+	uptime := s.proc.uptime()
+	if uptime >= s.cfg.infantMortality {
+		return false
+	}
+	logger.errorf("Process %d failed %v after restarting; waiting for the next change", s.proc.pid(), uptime.Round(time.Millisecond))
+	return true
+}
+
+// reportCrash reports a failure of the command, along with the last
+// lines of its error output.
+func (s *supervisor) reportCrash() {
+	code := s.proc.exitCode()
+	if code == 0 {
+		return
+	}
+	uptime := s.proc.uptime()
+	var tail string
+	if s.proc.stderr != nil {
+		tail = s.proc.stderr.String()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "----- crash summary -----\nProcess %d exited with code %d after %v\n", s.proc.pid(), code, uptime.Round(time.Millisecond))
+	if tail != "" {
+		fmt.Fprintf(&b, "Last lines of stderr:\n%s\n", tail)
+	}
+	b.WriteString("-------------------------")
+	crash := logEvent{Event: "crash", PID: s.proc.pid(), Generation: s.proc.generation}.withExitCode(code).withDuration(uptime)
+	logger.event(levelQuiet, crash, "%s", b.String())
+
+	msg := fmt.Sprintf("Process exited with code %d", code)
+	if line := panicLine(tail); line != "" {
+		msg += ": " + line
+	}
+	s.notify(msg)
+	s.postWebhook(crash, msg)
+}
+
+// panicLine returns the first line of a panic in the output, or else its
+// first line.
+func panicLine(output string) string {
+	lines := strings.Split(output, "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ") {
+			return line
+		}
+	}
+	return strings.TrimSpace(lines[0])
+}
+
+// backoffDone returns a channel that receives once the command should be
+// restarted after a crash, or nil if no restart is scheduled.
+func (s *supervisor) backoffDone() <-chan time.Time {
+	if s.backoff == nil {
+		return nil
+	}
+	return s.backoff.C
+}
+
+// onceDone returns a channel that receives once the restarted command
+// ran for --once-timeout, or nil if it is not timed.
+func (s *supervisor) onceDone() <-chan time.Time {
+	if s.once == nil {
+		return nil
+	}
+	return s.once.C
+}
+
+// watchPaths returns the paths to watch, which include trigger files
+// that may not exist yet. When a build command is used,
+// the executable is produced by the build, so it is not watched, nor is
+// the shell of -c.
+func (s *supervisor) watchPaths() []string {
+	var paths []string
+	if s.cfg.build == "" && s.cfg.shell == "" {
+		paths = append(paths, s.path)
+		if s.target != "" {
+			paths = append(paths, s.target)
+		}
+	}
+	paths = append(paths, s.cfg.watch...)
+	for _, rule := range s.cfg.signalRules {
+		paths = append(paths, rule.path)
+	}
+	paths = append(paths, s.cfg.triggers...)
+	return append(paths, s.cfg.envFiles...)
+}
+
+// startBuild runs the build command, canceling a build that is already
+// running.
+func (s *supervisor) startBuild() {
+	if s.build != nil {
+		logger.infof("Canceling the running build")
+		s.cancelBuild()
+	}
+	command := s.cfg.build
+	env, err := s.cfg.environ()
+	if err == nil {
+		command, err = s.cfg.expand(command, env)
+	}
+	var b *build
+	if err == nil {
+		b, err = startBuild(expandPlaceholders(command, s.changed), s.cfg.chdir)
+	}
+	if err != nil {
+		logger.errorf("BUILD FAILED: %v; %s", err, s.keeping())
+		return
+	}
+	logger.event(levelVerbose, logEvent{Event: "build_start"}, "Building with %q", s.cfg.build)
+	s.build = b
+}
+
+// procEvent returns the event about the command.
+func (s *supervisor) procEvent(event string) logEvent {
+	return logEvent{Event: event, PID: s.proc.pid(), Generation: s.proc.generation}
+}
+
+// exitEvent returns the event about the exit of the command.
+func (s *supervisor) exitEvent() logEvent {
+	return s.procEvent("exit").withExitCode(s.proc.exitCode())
+}
+
+// buildDone returns a channel that receives the result of the running
+// build, or nil if no build is running.
+func (s *supervisor) buildDone() <-chan error {
+	if s.build == nil {
+		return nil
+	}
+	return s.build.done
+}
+
+// cancelBuild stops the running build, if any.
+func (s *supervisor) cancelBuild() {
+	if s.build != nil {
+		s.build.cancel()
+		s.build = nil
+	}
+}
+
+// describeChanges describes the changed paths for the log, relative to
+// the working directory where possible.
+func (s *supervisor) describeChanges(paths []string) string {
+	path := s.displayPath(paths[0])
+	if len(paths) == 1 {
+		return fmt.Sprintf("%s changed", path)
+	}
+	return fmt.Sprintf("%s and %d more changed", path, len(paths)-1)
+}
+
+// displayPath returns the path relative to the working directory, where
+// possible. The symlink of the executable and its target are named
+// together, so that a change to either is attributed to both.
+func (s *supervisor) displayPath(path string) string {
+	if s.target != "" && (path == s.path || path == s.target) {
+		return displayPath(s.path) + " -> " + displayPath(s.target)
+	}
+	return displayPath(path)
+}
+
+// retarget resolves the executable again if its symlink changed, and
+// then watches its new target rather than the old one.
+func (s *supervisor) retarget(w *watcher, paths []string) {
+	if !contains(paths, s.path) || s.cfg.build != "" || s.cfg.shell != "" {
+		return
+	}
+	target := linkTarget(s.path)
+	if target == s.target {
+		return
+	}
+	if s.target != "" {
+		w.removeFile(s.target)
+	}
+	if target != "" {
+		if err := w.addFile(target); err != nil {
+			logger.errorf("Failed to watch %s: %v", target, err)
+		}
+		logger.infof("%s now refers to %s", displayPath(s.path), displayPath(target))
+	}
+	s.target = target
+}
+
+// restart stops the command and starts it again. If the wrapper is
+// stopped by a signal in the meantime, restart reports that it stopped
+// along with the exit code of the command.
+func (s *supervisor) restart() (int, bool) {
+	l := s.latency
+	if l.changed.IsZero() {
+		l.changed = time.Now()
+	}
+	env, err := s.cfg.environ()
+	var args []string
+	if err == nil {
+		args, err = s.cfg.args(env)
+	}
+	if err != nil {
+		logger.errorf("%v; keeping process %d running", err, s.proc.pid())
+		s.notify(fmt.Sprintf("Restart failed: %v", err))
+		return 0, false
+	}
+	if err := s.validate(env); err != nil {
+		logger.errorf("NOT RESTARTING: %v; %s", err, s.keeping())
+		s.notify(fmt.Sprintf("Not restarting: %v", err))
+		return 0, false
+	}
+	if err := s.runHooks("pre", s.cfg.preHooks, env); err != nil {
+		logger.errorf("%v; keeping process %d running", err, s.proc.pid())
+		s.notify(fmt.Sprintf("Restart failed: %v", err))
+		return 0, false
+	}
+
+	if s.cfg.overlap && !s.exited {
+		return s.overlap(env, args, l)
+	}
+
+	old := s.proc
+	logger.debugf("Stopping process %d with SIG%s", s.proc.pid(), signalName(s.cfg.killSignal))
+	begin := time.Now()
+	sig := s.proc.stop(s.cfg.killSignal, s.cfg.killTimeout, s.signals)
+	l.stop, l.killed = time.Since(begin), s.proc.killed()
+	logger.event(levelVerbose, s.exitEvent(), "Process %d exited with code %d", s.proc.pid(), s.proc.exitCode())
+
+	if sig == nil {
+		select {
+		case sig = <-s.signals:
+		default:
+		}
+	}
+	if sig != nil {
+		logger.infof("Received %v while restarting; exiting", sig)
+		s.signaled = true
+		s.cancelBuild()
+		return s.proc.exitCode(), true
+	}
+
+	if s.backoff != nil {
+		s.backoff.Stop()
+		s.backoff = nil
+	}
+	if s.cfg.waitPortFree.addr != "" {
+		begin = time.Now()
+		if code, stopped := s.awaitPortFree(); stopped {
+			return code, true
+		}
+		l.port = time.Since(begin)
+	}
+	binary := readBinaryInfo(s.path)
+	if diff := s.binary.diff(binary, s.cfg.chdir); diff != "" {
+		logger.infof("Restarting: %s", diff)
+	}
+	s.binary = binary
+	s.cfg.clear.clear()
+	begin = time.Now()
+	proc, err := startProcess(s.cfg, s.path, args, env, s.stdin, s.proc.generation+1, s.signals)
+	l.start = time.Since(begin)
+	var interrupted *interruptedError
+	if errors.As(err, &interrupted) {
+		logger.infof("Received %v while starting; exiting", interrupted.sig)
+		s.signaled = true
+		return s.proc.exitCode(), true
+	}
+	if err != nil {
+		logger.errorf("%v", err)
+		return 1, true
+	}
+	s.proc = proc
+	s.exited = false
+	s.board.started(proc, s.reason)
+	begin = time.Now()
+	if code, stopped := s.awaitReady(); stopped {
+		return code, true
+	}
+	if s.cfg.waitTCP.addr != "" {
+		l.ready = time.Since(begin)
+	}
+	s.restarted(env, l, old)
+	return 0, false
+}
+
+// restarted reports the restart of the command, which took the latency,
+// replacing the old process, and runs the post hooks.
+func (s *supervisor) restarted(env []string, l latency, old *process) {
+	l.total = time.Since(l.changed)
+	s.latency = latency{}
+	s.counters.Restarts++
+	s.counters.save(s.cfg)
+	uptime := old.uptime()
+	s.board.counted(s.cause, s.counters.Restarts, uptime)
+	if l.ready > 0 {
+		s.board.readied(l.ready)
+	}
+
+	e := s.procEvent("restart").withDuration(l.total)
+	e.Latency, e.Restart, e.Trigger = &l, s.counters.Restarts, s.trigger()
+	e.PreviousUptimeMS = new(int64)
+	*e.PreviousUptimeMS = uptime.Milliseconds()
+	logger.event(levelDefault, e, "↻ restart #%d: process %d (uptime of previous: %v, trigger: %s, took %s)",
+		s.counters.Restarts, s.proc.pid(), round(uptime), e.Trigger, l)
+	s.notify(fmt.Sprintf("Restarted process %d", s.proc.pid()))
+	s.postWebhook(e, fmt.Sprintf("Restarted process %d: %s", s.proc.pid(), s.reason))
+	if err := s.runHooks("post", s.cfg.postHooks, env); err != nil {
+		logger.errorf("%v", err)
+	}
+	if s.cfg.once {
+		s.restartedOnce = true
+		logger.infof("Restarted once; waiting for process %d to exit", s.proc.pid())
+		if s.cfg.onceTimeout > 0 {
+			s.once = time.NewTimer(s.cfg.onceTimeout)
+		}
+	}
+}
+
+// handleSignal stops the wrapper on SIGINT and SIGTERM and forwards any
+// other signal to the command. It reports whether the wrapper stopped
+// along with the exit code of the command.
+func (s *supervisor) handleSignal(w *watcher, sig os.Signal) (int, bool) {
+	if stopsWrapper(sig) {
+		return s.shutdown(w, sig), true
+	}
+	if s.exited {
+		return 0, false
+	}
+	logger.debugf("Forwarding %v to process %d", sig, s.proc.pid())
+	if err := s.proc.signal(sig); err != nil {
+		logger.errorf("Failed to forward %v to process %d: %v", sig, s.proc.pid(), err)
+	}
+	return 0, false
+}
+
+// overlap restarts the command with --overlap: the new process is
+// started alongside the old one, which is only stopped once the new one
+// is ready. If the new one is not, it is killed and the old one keeps
+// running. A signal received in the meantime is left in pendingSignal
+// for the supervisor to handle.
+func (s *supervisor) overlap(env, args []string, l latency) (int, bool) {
+	old := s.proc
+	binary := readBinaryInfo(s.path)
+	if diff := s.binary.diff(binary, s.cfg.chdir); diff != "" {
+		logger.infof("Restarting: %s", diff)
+	}
+	// Only one process can read the input.
+	old.detachStdin()
+	begin := time.Now()
+	proc, err := startProcess(s.cfg, s.path, args, env, s.stdin, old.generation+1, s.signals)
+	l.start = time.Since(begin)
+	var interrupted *interruptedError
+	if errors.As(err, &interrupted) {
+		s.pendingSignal = interrupted.sig
+		return 0, false
+	}
+	if err != nil {
+		logger.errorf("%v; keeping process %d running", err, old.pid())
+		return 0, false
+	}
+
+	begin = time.Now()
+	switch s.probeReady(proc) {
+	case unready:
+		logger.errorf("NOT RESTARTING: process %d is not accepting connections on %s after %v; killing it and keeping process %d running",
+			proc.pid(), s.cfg.waitTCP.addr, s.cfg.waitTCP.timeout, old.pid())
+		s.notify(fmt.Sprintf("Restart failed: process %d is not ready", proc.pid()))
+		proc.stop(syscall.SIGKILL, s.cfg.killTimeout, nil)
+		return 0, false
+	case exitedUnready:
+		logger.errorf("NOT RESTARTING: process %d exited with code %d before accepting connections on %s; keeping process %d running",
+			proc.pid(), proc.exitCode(), s.cfg.waitTCP.addr, old.pid())
+		s.notify(fmt.Sprintf("Restart failed: process %d exited with code %d", proc.pid(), proc.exitCode()))
+		proc.stop(syscall.SIGKILL, s.cfg.killTimeout, nil)
+		return 0, false
+	case interruptedUnready:
+		proc.stop(syscall.SIGKILL, s.cfg.killTimeout, nil)
+		return 0, false
+	}
+	if s.cfg.waitTCP.addr != "" {
+		l.ready = time.Since(begin)
+	}
+
+	s.binary = binary
+	s.proc = proc
+	s.board.started(proc, s.reason)
+	logger.debugf("Stopping process %d with SIG%s", old.pid(), signalName(s.cfg.killSignal))
+	begin = time.Now()
+	s.pendingSignal = old.stop(s.cfg.killSignal, s.cfg.killTimeout, s.signals)
+	l.stop, l.killed = time.Since(begin), old.killed()
+	exit := logEvent{Event: "exit", PID: old.pid(), Generation: old.generation}.withExitCode(old.exitCode())
+	logger.event(levelVerbose, exit, "Process %d exited with code %d", old.pid(), old.exitCode())
+	s.restarted(env, l, old)
+	return 0, false
+}
+
+// trigger describes what triggered the restart, such as the path that
+// changed.
+func (s *supervisor) trigger() string {
+	switch len(s.changed) {
+	case 0:
+		return s.reason
+	case 1:
+		return s.displayPath(s.changed[0])
+	default:
+		return fmt.Sprintf("%s and %d more", s.displayPath(s.changed[0]), len(s.changed)-1)
+	}
+}
+
+// exitForChange stops the command to exit the wrapper due to a change,
+// with --exit-on-change. It returns the exit code of the command.
+func (s *supervisor) exitForChange() int {
+	s.exitChanged = true
+	if s.backoff != nil {
+		s.backoff.Stop()
+		s.backoff = nil
+	}
+	logger.debugf("Stopping process %d with SIG%s", s.proc.pid(), signalName(s.cfg.killSignal))
+	if sig := s.proc.stop(s.cfg.killSignal, s.cfg.killTimeout, s.signals); sig != nil {
+		s.signaled = true
+	}
+	logger.event(levelDefault, s.exitEvent(), "Process %d exited with code %d", s.proc.pid(), s.proc.exitCode())
+	return s.proc.exitCode()
+}
+
+// shutdown stops the wrapper after it received the signal. It stops
+// watching, cancels any build and pending restart, then forwards the
+// signal to the command and waits for it to exit. The command is killed
+// if it does not exit within the kill timeout, or immediately if another
+// signal is received. It returns the exit code of the command.
+func (s *supervisor) shutdown(w *watcher, sig os.Signal) int {
+	s.signaled = true
+	w.close()
+	s.cancelBuild()
+	if s.backoff != nil {
+		s.backoff.Stop()
+		s.backoff = nil
+	}
+
+	logger.debugf("Forwarding %v to process %d", sig, s.proc.pid())
+	s.proc.stop(sig, s.cfg.killTimeout, s.signals)
+	logger.event(levelDefault, s.exitEvent(), "Process %d exited with code %d", s.proc.pid(), s.proc.exitCode())
+	return s.proc.exitCode()
+}
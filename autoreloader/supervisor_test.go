@@ -0,0 +1,315 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// settle waits for a change to settle and be handled.
+const settle = 300 * time.Millisecond
+
+// TestResumeAfterRequestedRestart checks that a change made while
+// paused, which a requested restart already applied, does not restart
+// the command again once resumed.
+func TestResumeAfterRequestedRestart(t *testing.T) {
+	h := newHarness(t, sleeper, "--delay=20ms")
+	h.waitFor("the command to start", generation(1))
+
+	h.request("pause")
+	h.write(sleeper + "# changed\n")
+	time.Sleep(settle)
+	if reply := h.request("restart"); reply.Error != "" {
+		t.Fatalf("restart: %s", reply.Error)
+	}
+	h.waitFor("the requested restart", generation(2))
+	h.request("pause")
+	time.Sleep(settle)
+
+	if st := h.status(); st.Generation != 2 {
+		t.Errorf("generation = %d after resuming, want 2", st.Generation)
+	}
+}
+
+// TestResumeAfterCrashRestart checks that a change made while paused,
+// which the restart after a crash already applied, does not restart the
+// command again once resumed.
+func TestResumeAfterCrashRestart(t *testing.T) {
+	crasher := "#!/bin/sh\nsleep 0.3\nexit 3\n"
+	h := newHarness(t, crasher, "--delay=20ms", "--restart-on-exit", "--backoff=50ms")
+	h.waitFor("the command to start", generation(1))
+
+	h.request("pause")
+	h.write(crasher + "# changed\n")
+	h.waitFor("the restart after the crash", func(st controlStatus) bool { return st.Generation >= 2 })
+	h.request("pause")
+	time.Sleep(settle)
+
+	if reply := h.request("pause"); reply.Error != "" {
+		t.Errorf("pause: %s", reply.Error)
+	}
+}
+
+// TestForwardHangup checks that a SIGHUP is passed on to the command,
+// which keeps running, while a SIGTERM stops the wrapper.
+func TestForwardHangup(t *testing.T) {
+	hups := filepath.Join(t.TempDir(), "hups")
+	h := newHarness(t, "#!/bin/sh\ntrap 'echo hup >> "+hups+"' HUP\nwhile true; do sleep 0.05; done\n")
+	h.waitFor("the command to start", generation(1))
+
+	h.s.signals <- syscall.SIGHUP
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(hups); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the command to receive SIGHUP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st := h.status(); st.Generation != 1 || !st.Running {
+		t.Errorf("status = %+v after SIGHUP, want generation 1 running", st)
+	}
+
+	h.s.signals <- syscall.SIGTERM
+	h.wait()
+	if !h.s.signaled {
+		t.Error("the wrapper did not stop on SIGTERM")
+	}
+}
+
+// stubborn is a command that ignores SIGTERM.
+const stubborn = "#!/bin/sh\ntrap '' TERM\nwhile true; do sleep 0.05; done\n"
+
+// TestStopEscalatesToKill checks that a command that ignores the stop
+// signal is killed once the kill timeout passes.
+func TestStopEscalatesToKill(t *testing.T) {
+	h := newHarness(t, stubborn, "--kill-timeout=300ms")
+	h.waitFor("the command to start", generation(1))
+	pid := h.status().PID
+
+	start := time.Now()
+	h.s.signals <- syscall.SIGTERM
+	h.wait()
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("stopped after %v, before the kill timeout", elapsed)
+	}
+	if !h.s.proc.killed() {
+		t.Error("the command was not killed")
+	}
+	waitExited(t, pid)
+}
+
+// TestSecondSignalKills checks that a signal received while waiting for
+// the command to stop kills it without waiting for the kill timeout.
+func TestSecondSignalKills(t *testing.T) {
+	h := newHarness(t, stubborn, "--kill-timeout=30s")
+	h.waitFor("the command to start", generation(1))
+	pid := h.status().PID
+
+	h.s.signals <- syscall.SIGTERM
+	time.Sleep(100 * time.Millisecond)
+	h.s.signals <- syscall.SIGINT
+	select {
+	case <-h.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second signal did not kill the command")
+	}
+	if !h.s.proc.killed() {
+		t.Error("the command was not killed")
+	}
+	waitExited(t, pid)
+}
+
+// TestNoRestartWhenUnchanged checks that neither starting the command
+// nor events for its executable restart it while the executable is
+// unchanged.
+func TestNoRestartWhenUnchanged(t *testing.T) {
+	h := newHarness(t, sleeper, "--delay=200ms")
+	h.waitFor("the command to start", generation(1))
+	time.Sleep(settle)
+
+	// Rewrite the executable in place and restore its modification
+	// time, which leaves it as it was started.
+	info, err := os.Stat(h.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(h.path, []byte(sleeper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(h.path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * settle)
+
+	if st := h.status(); st.Generation != 1 || st.Restarts != 0 {
+		t.Errorf("generation = %d, restarts = %d with the executable untouched, want 1 and 0", st.Generation, st.Restarts)
+	}
+}
+
+// waitLines waits until the file has at least n lines.
+func waitLines(t *testing.T, path string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		b, _ := ioutil.ReadFile(path)
+		if strings.Count(string(b), "\n") >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d lines in %s; have %q", n, path, b)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestSignalsDuringOverlap checks that signals received while waiting
+// for the new process of an overlapped restart are forwarded to the old
+// one, and do not wedge the supervisor, however many arrive.
+func TestSignalsDuringOverlap(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	dir := t.TempDir()
+	starts, signals := filepath.Join(dir, "starts"), filepath.Join(dir, "signals")
+	script := "#!/bin/sh\necho $$ >> " + starts + "\n" +
+		"trap 'echo hup >> " + signals + "' HUP\ntrap 'echo usr1 >> " + signals + "' USR1\n" +
+		"while true; do sleep 0.05 & wait; done\n"
+	h := newHarness(t, script, "--overlap", "--wait-tcp="+addr+",2s", "--delay=20ms")
+	// The first process never becomes ready; wait for the supervisor to
+	// give up on it and handle requests.
+	h.request("pause")
+	h.request("pause")
+
+	h.write(script + "# changed\n")
+	waitLines(t, starts, 2)
+	h.s.signals <- syscall.SIGHUP
+	h.s.signals <- syscall.SIGUSR1
+	waitLines(t, signals, 2)
+
+	if reply := h.request("pause"); reply.Error != "" {
+		t.Fatalf("pause: %s", reply.Error)
+	}
+	if st := h.status(); st.Generation != 1 || !st.Running {
+		t.Errorf("status = %+v, want generation 1 running", st)
+	}
+}
+
+// TestChdir checks that the command runs in the directory of --chdir,
+// while watched paths are relative to the directory the wrapper was run
+// in, and that restarts still report how the executable changed.
+func TestChdir(t *testing.T) {
+	invoked, work := t.TempDir(), t.TempDir()
+	for _, dir := range []string{invoked, work} {
+		if err := os.Mkdir(filepath.Join(dir, "assets"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chdir(t, invoked)
+	pwd := filepath.Join(t.TempDir(), "pwd")
+	script := "#!/bin/sh\npwd -P >> " + pwd + "\nexec sleep 60\n"
+	logs := captureLog(t)
+	h := newHarness(t, script, "--chdir="+work, "--watch=assets", "--delay=20ms")
+	h.waitFor("the command to start", generation(1))
+
+	waitLines(t, pwd, 1)
+	b, _ := ioutil.ReadFile(pwd)
+	if want, _ := filepath.EvalSymlinks(work); strings.TrimSpace(string(b)) != want {
+		t.Errorf("command ran in %q, want %q", strings.TrimSpace(string(b)), want)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(work, "assets", "a"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(settle)
+	if st := h.status(); st.Generation != 1 {
+		t.Fatalf("generation = %d after a change in --chdir, want 1", st.Generation)
+	}
+	if err := ioutil.WriteFile(filepath.Join(invoked, "assets", "a"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h.waitFor("the restart for the watched path", generation(2))
+
+	changed := script + "# changed\n"
+	h.write(changed)
+	h.waitFor("the restart for the executable", generation(3))
+	want := fmt.Sprintf("Restarting: %d → %d bytes (+%d)", len(script), len(changed), len(changed)-len(script))
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("log does not contain %q:\n%s", want, logs.String())
+	}
+}
+
+// TestEnvFileReread checks that the env files are read again for every
+// restart.
+func TestEnvFileReread(t *testing.T) {
+	dir := t.TempDir()
+	envFile, out := filepath.Join(dir, ".env"), filepath.Join(dir, "out")
+	if err := ioutil.WriteFile(envFile, []byte("GREETING=hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho $GREETING >> " + out + "\nexec sleep 60\n"
+	h := newHarness(t, script, "--env-file="+envFile, "--delay=20ms")
+	h.waitFor("the command to start", generation(1))
+
+	if err := ioutil.WriteFile(envFile, []byte("GREETING=goodbye\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h.waitFor("the restart for the env file", generation(2))
+	waitLines(t, out, 2)
+	if b, _ := ioutil.ReadFile(out); string(b) != "hello\ngoodbye\n" {
+		t.Errorf("command saw %q, want hello then goodbye", b)
+	}
+}
+
+// TestExitCode checks the exit code of the wrapper under each exit code
+// policy, both when the command exits on its own and when the wrapper
+// is stopped by a signal, which the command exits from with 128 plus
+// the signal number.
+func TestExitCode(t *testing.T) {
+	const exiter = "#!/bin/sh\nexit 3\n"
+	tests := []struct {
+		policy string
+		script string
+		signal syscall.Signal
+		want   int
+	}{
+		{"child", exiter, 0, 3},
+		{"child", sleeper, syscall.SIGTERM, 128 + int(syscall.SIGTERM)},
+		{"child", sleeper, syscall.SIGINT, 128 + int(syscall.SIGINT)},
+		{"zero-on-signal", exiter, 0, 3},
+		{"zero-on-signal", sleeper, syscall.SIGTERM, 0},
+		{"7", exiter, 0, 7},
+		{"7", sleeper, syscall.SIGTERM, 7},
+	}
+	for _, tt := range tests {
+		name := tt.policy + "/exit"
+		if tt.signal != 0 {
+			name = tt.policy + "/" + signalName(tt.signal)
+		}
+		t.Run(name, func(t *testing.T) {
+			h := newHarness(t, tt.script, "--exit-code="+tt.policy)
+			if tt.signal != 0 {
+				h.waitFor("the command to start", generation(1))
+				h.s.signals <- tt.signal
+			}
+			if code := h.wait(); code != tt.want {
+				t.Errorf("exit code = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
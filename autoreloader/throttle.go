@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// throttle limits how many restarts changes cause within a window, with
+// --max-restarts and --per. Once the limit is reached, restarts pause
+// until the window allows another one.
+type throttle struct {
+	max int
+	per time.Duration
+
+	// recent holds the times of the restarts within the window.
+	recent []time.Time
+
+	// timer fires once restarts resume, and is nil unless they are
+	// paused.
+	timer *time.Timer
+	until time.Time
+}
+
+// prune forgets the restarts that left the window.
+func (t *throttle) prune(now time.Time) {
+	i := 0
+	for i < len(t.recent) && now.Sub(t.recent[i]) >= t.per {
+		i++
+	}
+	t.recent = t.recent[i:]
+}
+
+// allow reports whether a restart may happen now, and records it if so.
+// Otherwise, restarts are paused until the window allows one.
+func (t *throttle) allow(now time.Time) bool {
+	if t.max == 0 {
+		return true
+	}
+	if t.timer != nil {
+		return false
+	}
+	t.prune(now)
+	if len(t.recent) < t.max {
+		t.recent = append(t.recent, now)
+		return true
+	}
+	t.until = t.recent[0].Add(t.per)
+	t.timer = time.NewTimer(t.until.Sub(now))
+	return false
+}
+
+// resume ends a pause of the restarts.
+func (t *throttle) resume() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// reset ends a pause of the restarts and forgets the recent ones.
+func (t *throttle) reset() {
+	t.resume()
+	t.recent = nil
+}
+
+// done returns a channel that receives once restarts resume, or nil if
+// they are not paused.
+func (t *throttle) done() <-chan time.Time {
+	if t.timer == nil {
+		return nil
+	}
+	return t.timer.C
+}
+
+// throttled reports whether the restart for a change must wait, in which
+// case the change is kept until restarts resume.
+func (s *supervisor) throttled() bool {
+	paused := s.throttle.timer != nil
+	if s.throttle.allow(time.Now()) {
+		s.board.throttled(len(s.throttle.recent), time.Time{})
+		return false
+	}
+	if paused {
+		logger.infof("Restarts are paused until %s; restarting then", s.throttle.until.Format("15:04:05"))
+		return true
+	}
+	msg := fmt.Sprintf("RESTARTS THROTTLED: %d restarts within %v; pausing restarts until %s", s.throttle.max, s.throttle.per, s.throttle.until.Format("15:04:05"))
+	logger.errorf("%s", msg)
+	s.notify(msg)
+	s.board.throttled(len(s.throttle.recent), s.throttle.until)
+	return true
+}
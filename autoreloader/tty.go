@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// eot is the character that signals the end of input to a terminal.
+const eot = 0x04
+
+// terminal runs a process under a pseudo-terminal, so that it behaves as
+// it would when run interactively.
+type terminal struct {
+	master  *os.File
+	slave   *os.File
+	restore func()
+	winch   chan os.Signal
+	copied  chan struct{}
+}
+
+// newTerminal allocates a pseudo-terminal and makes it the controlling
+// terminal of the command.
+func newTerminal(cmd *exec.Cmd) (*terminal, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	// A new session also places the command in its own process group.
+	setControllingTerminal(cmd)
+	return &terminal{
+		master: master,
+		slave:  slave,
+		winch:  make(chan os.Signal, 1),
+		copied: make(chan struct{}),
+	}, nil
+}
+
+// start connects the pseudo-terminal to the terminal of the wrapper once
+// the command has started. The terminal of the wrapper is put into raw
+// mode, so that keys such as Ctrl-C are passed to the command.
+func (t *terminal) start(stdin *stdinRelay) func() {
+	t.slave.Close()
+	if isTerminal(os.Stdin) {
+		restore, err := makeRaw(os.Stdin)
+		if err != nil {
+			logger.errorf("Failed to put terminal into raw mode: %v", err)
+		} else {
+			t.restore = restore
+		}
+		copySize(os.Stdin, t.master)
+		notifyResize(t.winch)
+		go func() {
+			for range t.winch {
+				copySize(os.Stdin, t.master)
+			}
+		}()
+	}
+	go func() {
+		defer close(t.copied)
+		io.Copy(stdout.source(), t.master)
+	}()
+	return stdin.attach(ptyInput{t.master})
+}
+
+// close restores the terminal of the wrapper once the command has
+// exited.
+func (t *terminal) close() {
+	signal.Stop(t.winch)
+	close(t.winch)
+	<-t.copied
+	if t.restore != nil {
+		t.restore()
+	}
+	t.master.Close()
+}
+
+// ptyInput writes input to a pseudo-terminal. Closing it signals the end
+// of input to the command rather than hanging up the terminal.
+type ptyInput struct {
+	*os.File
+}
+
+func (p ptyInput) Close() error {
+	_, err := p.Write([]byte{eot})
+	return err
+}
@@ -0,0 +1,32 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errTTYUnsupported = errors.New("--tty is not supported on this platform")
+
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errTTYUnsupported
+}
+
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errTTYUnsupported
+}
+
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+func copySize(from, to *os.File) error {
+	return errTTYUnsupported
+}
+
+func setControllingTerminal(cmd *exec.Cmd) {}
+
+func notifyResize(c chan<- os.Signal) {}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+)
+
+// executableMagics are the leading bytes of the executable formats that
+// the command may be replaced with.
+var executableMagics = [][]byte{
+	[]byte("\x7fELF"),
+	[]byte("#!"),
+	[]byte("MZ"),             // PE
+	{0xfe, 0xed, 0xfa, 0xce}, // Mach-O 32-bit
+	{0xfe, 0xed, 0xfa, 0xcf}, // Mach-O 64-bit
+	{0xce, 0xfa, 0xed, 0xfe}, // Mach-O 32-bit, little-endian
+	{0xcf, 0xfa, 0xed, 0xfe}, // Mach-O 64-bit, little-endian
+	{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal
+}
+
+// validateExecutable checks that the file at path can replace the
+// running command: it is a nonempty regular file that can be executed
+// and is in a known executable format.
+func validateExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !info.Mode().IsRegular():
+		return fmt.Errorf("%s is not a regular file", path)
+	case info.Size() == 0:
+		return fmt.Errorf("%s is empty", path)
+	case runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0:
+		return fmt.Errorf("%s is not executable (mode %v)", path, info.Mode().Perm())
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, 4)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	for _, magic := range executableMagics {
+		if bytes.HasPrefix(head[:n], magic) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not in a known executable format (starts with %q)", path, head[:n])
+}
+
+// validate checks that the executable can replace the running command,
+// and runs the --validate command, if any.
+func (s *supervisor) validate(env []string) error {
+	if err := validateExecutable(s.path); err != nil {
+		return err
+	}
+	if s.cfg.validate == "" {
+		return nil
+	}
+	command, err := s.cfg.expand(s.cfg.validate, env)
+	if err != nil {
+		return fmt.Errorf("validate command: %v", err)
+	}
+	command = expandPlaceholders(command, []string{s.path})
+	logger.debugf("Validating %s with %q", s.path, command)
+	return runHook("validate", command, s.cfg.chdir, env, s.cfg.hookTimeout)
+}
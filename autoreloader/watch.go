@@ -0,0 +1,272 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watcher reports changes to the watched files and directories once
+// they have settled. Files are watched through their parent directory,
+// so that a file replaced by a rename or recreated is still noticed.
+// Directories are watched recursively. When polling, the watched paths
+// are instead checked for changes at the poll interval.
+type watcher struct {
+	fsw     *fsnotify.Watcher
+	events  <-chan fsnotify.Event
+	errors  <-chan error
+	done    chan struct{}
+	closing sync.Once
+	seen    int64
+	mu      sync.Mutex
+	files   map[string]bool
+	roots   []string
+	ignore  []string
+	exts    []string
+	delay   time.Duration
+	debug   bool
+	changes chan changeSet
+
+	// includeHidden watches hidden files and directories within the
+	// watched directories.
+	includeHidden bool
+}
+
+// changeSet holds the paths that changed once they settled.
+type changeSet struct {
+	paths []string
+
+	// first is when the first of the changes was seen.
+	first time.Time
+}
+
+func newWatcher(cfg *config, paths ...string) (*watcher, error) {
+	w := &watcher{
+		done:    make(chan struct{}),
+		files:   map[string]bool{},
+		ignore:  cfg.ignore,
+		exts:    cfg.exts,
+		delay:   cfg.delay,
+		debug:   cfg.debugEvents || cfg.verbose,
+		changes: make(chan changeSet),
+
+		includeHidden: cfg.includeHidden,
+	}
+	if cfg.poll == 0 {
+		fsw, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, err
+		}
+		w.fsw, w.events, w.errors = fsw, fsw.Events, fsw.Errors
+	}
+	for _, path := range paths {
+		if err := w.add(path); err != nil {
+			w.close()
+			return nil, err
+		}
+	}
+	if cfg.poll > 0 {
+		events := make(chan fsnotify.Event)
+		w.events = events
+		go w.poll(time.Duration(cfg.poll), events)
+	} else {
+		go w.crossCheck()
+	}
+	go w.run()
+	return w, nil
+}
+
+// add watches the file or directory at path.
+func (w *watcher) add(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		w.roots = append(w.roots, path)
+		return w.addTree(path)
+	}
+	return w.addFile(path)
+}
+
+// addFile watches the file at path, which need not exist yet.
+func (w *watcher) addFile(path string) error {
+	w.mu.Lock()
+	w.files[path] = true
+	w.mu.Unlock()
+	return w.watchDir(filepath.Dir(path))
+}
+
+// removeFile stops watching the file at path. Its directory is still
+// subscribed to, as other watched paths may lie within it.
+func (w *watcher) removeFile(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.files, path)
+}
+
+// isFile reports whether the path is a watched file.
+func (w *watcher) isFile(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.files[path]
+}
+
+// fileList returns the watched files.
+func (w *watcher) fileList() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var paths []string
+	for path := range w.files {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// watchDir subscribes to the events of the directory, unless polling.
+func (w *watcher) watchDir(path string) error {
+	if w.fsw == nil {
+		return nil
+	}
+	return w.fsw.Add(path)
+}
+
+// addTree watches the directory and all directories beneath it, other
+// than those ignored or skipped.
+func (w *watcher) addTree(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path == root {
+			return w.watchDir(path)
+		}
+		if _, ignored := w.ignoredBy(path); ignored || w.skipped(path) {
+			if !ignored && w.debug && !w.inSkippedDir(path) {
+				// Report the events of hidden directories when
+				// debugging, without descending into them.
+				if err := w.watchDir(path); err != nil {
+					return err
+				}
+			}
+			return filepath.SkipDir
+		}
+		return w.watchDir(path)
+	})
+}
+
+// watched reports whether the path is a watched file or lies within a
+// watched directory.
+func (w *watcher) watched(path string) bool {
+	if w.isFile(path) {
+		return true
+	}
+	for _, root := range w.roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// close stops watching.
+func (w *watcher) close() {
+	w.closing.Do(func() {
+		close(w.done)
+		if w.fsw != nil {
+			w.fsw.Close()
+		}
+	})
+}
+
+// run collects the changed files until no further change is seen for
+// the delay and then reports them.
+func (w *watcher) run() {
+	var changed []string
+	var first time.Time
+	var settled <-chan time.Time
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-w.events:
+			if !ok {
+				return
+			}
+			if !w.watched(event.Name) {
+				continue
+			}
+			if w.skipped(event.Name) {
+				if w.debug && w.hidden(event.Name) {
+					logger.infof("Event %s ignored as hidden; use --include-hidden to watch it", event)
+				}
+				continue
+			}
+			atomic.AddInt64(&w.seen, 1)
+			if pattern, ignored := w.ignoredBy(event.Name); ignored {
+				if w.debug {
+					logger.infof("Event %s ignored by %q", event, pattern)
+				}
+				continue
+			}
+			if event.Op&fsnotify.Create != 0 {
+				// Watch directories created within a watched directory.
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := w.addTree(event.Name); err != nil {
+						logger.errorf("Failed to watch %s: %v", event.Name, err)
+					}
+				}
+			}
+			if w.filtered(event.Name) {
+				if w.debug {
+					logger.infof("Event %s filtered by extension", event)
+				}
+				continue
+			}
+			if w.debug {
+				logger.infof("Event %s", event)
+			}
+			if changed == nil {
+				first = time.Now()
+			}
+			if !contains(changed, event.Name) {
+				changed = append(changed, event.Name)
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(w.delay)
+			settled = timer.C
+		case err, ok := <-w.errors:
+			if !ok {
+				return
+			}
+			logger.errorf("Watch error: %v", err)
+		case <-settled:
+			select {
+			case w.changes <- changeSet{paths: changed, first: first}:
+			case <-w.done:
+				return
+			}
+			changed, settled, timer = nil, nil, nil
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// webhookTimeout is how long delivering an event to --webhook may take.
+const webhookTimeout = 5 * time.Second
+
+// webhookEvent is the JSON payload posted to --webhook.
+type webhookEvent struct {
+	Time       time.Time `json:"ts"`
+	Event      string    `json:"event"`
+	Message    string    `json:"msg"`
+	Command    []string  `json:"command"`
+	Hostname   string    `json:"hostname"`
+	PID        int       `json:"pid,omitempty"`
+	Generation int       `json:"generation,omitempty"`
+	Build      string    `json:"build,omitempty"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	DurationMS *int64    `json:"duration_ms,omitempty"`
+}
+
+// postWebhook posts the event to --webhook in the background, without
+// retrying. Failures are only logged at the verbose level, as they must
+// not affect the command. The wrapper waits for the events to be posted
+// before it exits.
+func (s *supervisor) postWebhook(e logEvent, msg string) {
+	if s.cfg.webhook == "" {
+		return
+	}
+	hostname, _ := os.Hostname()
+	payload := webhookEvent{
+		Time:       time.Now(),
+		Event:      e.Event,
+		Message:    msg,
+		Command:    s.cfg.command,
+		Hostname:   hostname,
+		PID:        e.PID,
+		Generation: e.Generation,
+		ExitCode:   e.ExitCode,
+		DurationMS: e.DurationMS,
+	}
+	if b := s.binary.build; b != nil {
+		payload.Build = shortBuild(b)
+		if b.Modified {
+			payload.Build += " (dirty)"
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.debugf("Failed to post %s to the webhook: %v", e.Event, err)
+		return
+	}
+	s.webhooks.Add(1)
+	go func() {
+		defer s.webhooks.Done()
+		if err := s.deliverWebhook(body); err != nil {
+			logger.debugf("Failed to post %s to the webhook: %v", e.Event, err)
+		}
+	}()
+}
+
+func (s *supervisor) deliverWebhook(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range s.cfg.webhookHeaders {
+		i := strings.IndexByte(header, '=')
+		req.Header.Set(header[:i], header[i+1:])
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
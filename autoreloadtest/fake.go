@@ -0,0 +1,227 @@
+// Package autoreloadtest provides a fake autoreload.Reloader for testing
+// how an application behaves around reloads.
+package autoreloadtest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agschwender/autoreload"
+)
+
+// Exec describes a process that the FakeReloader would have executed.
+type Exec struct {
+	Argv []string
+	Env  []string
+}
+
+// FakeReloader implements autoreload.Reloader without watching files or
+// executing anything. Tests inject changes with Change and inspect the
+// outcome with Execs and History. As with an AutoReloader, hooks are run
+// in registration order, a panicking hook aborts the reload and a reload
+// requested with Reload cannot be vetoed.
+type FakeReloader struct {
+	mu         sync.Mutex
+	running    bool
+	generation int
+	paths      []string
+	hooks      []*hook
+	hookSeq    int
+	hookOrder  []int
+	veto       func(path string) bool
+	execs      []Exec
+	history    []autoreload.ReloadInfo
+}
+
+// hook is a registered reload hook, numbered in registration order.
+type hook struct {
+	fn  func()
+	seq int
+}
+
+var _ autoreload.Reloader = (*FakeReloader)(nil)
+
+// NewFakeReloader creates a FakeReloader that watches the supplied
+// paths.
+func NewFakeReloader(paths ...string) *FakeReloader {
+	return &FakeReloader{generation: 1, paths: paths}
+}
+
+// Start marks the FakeReloader as running.
+func (f *FakeReloader) Start() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = true
+}
+
+// Stop marks the FakeReloader as stopped.
+func (f *FakeReloader) Stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = false
+}
+
+// Reload performs a reload that cannot be vetoed. It returns
+// autoreload.ErrNotRunning if the FakeReloader has not been started.
+func (f *FakeReloader) Reload() error {
+	f.mu.Lock()
+	running := f.running
+	f.mu.Unlock()
+	if !running {
+		return autoreload.ErrNotRunning
+	}
+	f.reload("")
+	return nil
+}
+
+// Status returns the status of the FakeReloader. The generation is
+// incremented by every reload that would have executed a new process.
+func (f *FakeReloader) Status() autoreload.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := autoreload.Status{
+		Name:       "fake",
+		Running:    f.running,
+		Generation: f.generation,
+	}
+	for _, path := range f.paths {
+		status.Paths = append(status.Paths, autoreload.WatchedPath{Path: path, Action: autoreload.ActionReload})
+	}
+	if n := len(f.history); n > 0 {
+		status.LastReloadTime = f.history[n-1].Time
+		status.LastReloadReason = f.history[n-1].Path
+	}
+	return status
+}
+
+// AddOnReload registers a hook that is run when a reload occurs. Hooks
+// are numbered in registration order, starting at 1, for HookOrder. The
+// returned function removes the hook.
+func (f *FakeReloader) AddOnReload(fn func()) (remove func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hookSeq++
+	h := &hook{fn: fn, seq: f.hookSeq}
+	f.hooks = append(f.hooks, h)
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i, other := range f.hooks {
+			if other == h {
+				f.hooks = append(f.hooks[:i:i], f.hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// SetVeto defines a function that decides whether a change injected with
+// Change reloads, much like autoreload.WithChangeDetector. Returning
+// false vetoes the reload.
+func (f *FakeReloader) SetVeto(veto func(path string) bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.veto = veto
+}
+
+// Change simulates a change of the path, reloading unless the reloader
+// is stopped or the change is vetoed. It reports whether a new process
+// would have been executed.
+func (f *FakeReloader) Change(path string) bool {
+	f.mu.Lock()
+	running, veto := f.running, f.veto
+	f.mu.Unlock()
+	if !running || (veto != nil && !veto(path)) {
+		return false
+	}
+	return f.reload(path)
+}
+
+// Execs returns the processes that would have been executed, oldest
+// first.
+func (f *FakeReloader) Execs() []Exec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Exec{}, f.execs...)
+}
+
+// HookOrder returns the hooks run by the latest reload, in the order
+// that they ran, by the numbers that AddOnReload gave them. A removed
+// hook keeps its number, so the hooks registered after it are not
+// renumbered.
+func (f *FakeReloader) HookOrder() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int{}, f.hookOrder...)
+}
+
+// History returns the reloads that occurred, oldest first.
+func (f *FakeReloader) History() []autoreload.ReloadInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]autoreload.ReloadInfo{}, f.history...)
+}
+
+func (f *FakeReloader) reload(path string) bool {
+	f.mu.Lock()
+	hooks := append([]*hook{}, f.hooks...)
+	f.hookOrder = f.hookOrder[:0]
+	for _, h := range hooks {
+		f.hookOrder = append(f.hookOrder, h.seq)
+	}
+	f.mu.Unlock()
+
+	ok := true
+	for _, h := range hooks {
+		if !runHook(h.fn) {
+			ok = false
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info := autoreload.ReloadInfo{Name: "fake", Time: time.Now(), Path: path}
+	if !ok {
+		info.Outcome = autoreload.ReloadAborted
+		info.Err = errors.New("reload hook panicked")
+		f.history = append(f.history, info)
+		return false
+	}
+	info.Outcome = autoreload.ReloadExecuted
+	info.Attempts = 1
+	f.history = append(f.history, info)
+	f.generation++
+	f.execs = append(f.execs, Exec{
+		Argv: append([]string{}, os.Args...),
+		Env:  setEnv(os.Environ(), "AUTORELOAD_GENERATION", strconv.Itoa(f.generation)),
+	})
+	return true
+}
+
+// setEnv returns the environment with the variable set to the value,
+// replacing any existing entry for it.
+func setEnv(env []string, key, value string) []string {
+	out := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, key+"=") {
+			out = append(out, kv)
+		}
+	}
+	return append(out, key+"="+value)
+}
+
+func runHook(fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "autoreloadtest: reload hook panicked: %v\n", r)
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
@@ -0,0 +1,82 @@
+package autoreloadtest
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/agschwender/autoreload"
+)
+
+// TestStartAfterStop checks that a stopped FakeReloader ignores changes
+// and refuses reloads, and reloads again once started.
+func TestStartAfterStop(t *testing.T) {
+	f := NewFakeReloader("/bin/app")
+	f.Start()
+	f.Stop()
+	if f.Change("/bin/app") {
+		t.Error("reloaded on a change while stopped")
+	}
+	if err := f.Reload(); !errors.Is(err, autoreload.ErrNotRunning) {
+		t.Errorf("Reload while stopped = %v, want ErrNotRunning", err)
+	}
+
+	f.Start()
+	if !f.Change("/bin/app") {
+		t.Error("did not reload on a change once started again")
+	}
+	if n := len(f.Execs()); n != 1 {
+		t.Errorf("%d execs, want 1", n)
+	}
+	if gen := f.Status().Generation; gen != 2 {
+		t.Errorf("generation = %d, want 2", gen)
+	}
+}
+
+// TestExecGeneration checks that the environment of an exec holds a
+// single AUTORELOAD_GENERATION, that of the new process.
+func TestExecGeneration(t *testing.T) {
+	os.Setenv("AUTORELOAD_GENERATION", "1")
+	defer os.Unsetenv("AUTORELOAD_GENERATION")
+	f := NewFakeReloader("/bin/app")
+	f.Start()
+	f.Change("/bin/app")
+	f.Change("/bin/app")
+
+	for i, exec := range f.Execs() {
+		var values []string
+		for _, kv := range exec.Env {
+			if strings.HasPrefix(kv, "AUTORELOAD_GENERATION=") {
+				values = append(values, kv)
+			}
+		}
+		want := []string{"AUTORELOAD_GENERATION=" + strconv.Itoa(i+2)}
+		if !reflect.DeepEqual(values, want) {
+			t.Errorf("exec %d has %q, want %q", i, values, want)
+		}
+	}
+}
+
+// TestHookOrder checks that the hooks run in registration order, even if
+// one panics, keeping their numbers when an earlier one is removed.
+func TestHookOrder(t *testing.T) {
+	f := NewFakeReloader("/bin/app")
+	f.AddOnReload(func() {})
+	remove := f.AddOnReload(func() {})
+	f.AddOnReload(func() { panic("boom") })
+	f.AddOnReload(func() {})
+	f.Start()
+
+	f.Change("/bin/app")
+	if got, want := f.HookOrder(), []int{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HookOrder = %v, want %v", got, want)
+	}
+	remove()
+	f.Change("/bin/app")
+	if got, want := f.HookOrder(), []int{1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HookOrder after removing hook 2 = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloaders is the number of AutoReloaders of a typical dev runner, one
+// per service binary.
+const reloaders = 40
+
+// commands creates n commands in a temporary directory.
+func commands(b *testing.B, n int) []string {
+	b.Helper()
+	dir := b.TempDir()
+	cmds := make([]string, n)
+	for i := range cmds {
+		cmds[i] = filepath.Join(dir, fmt.Sprintf("service%d", i))
+		if err := ioutil.WriteFile(cmds[i], []byte("#!/bin/sh\n"), 0755); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return cmds
+}
+
+// BenchmarkManyReloaders starts and stops a dev runner's worth of
+// AutoReloaders, either sharing a WatcherPool or each with a pool of its
+// own, reporting the goroutines and fsnotify watchers that each uses
+// while running. Sharing a pool saves the watcher and its dispatcher;
+// every AutoReloader still runs a goroutine of its own.
+func BenchmarkManyReloaders(b *testing.B) {
+	shared := NewWatcherPool()
+	for _, bm := range []struct {
+		name string
+		pool func() *WatcherPool
+	}{
+		{"pooled", func() *WatcherPool { return shared }},
+		{"unpooled", NewWatcherPool},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			cmds := commands(b, reloaders)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			var goroutines, watchers int
+			for i := 0; i < b.N; i++ {
+				base := runtime.NumGoroutine()
+				ars := make([]AutoReloader, len(cmds))
+				pools := map[*WatcherPool]bool{}
+				for j, cmd := range cmds {
+					pool := bm.pool()
+					pools[pool] = true
+					ars[j] = New(WithCommand(cmd), WithLogger(nil), WithWatcherPool(pool))
+					ars[j].Start()
+				}
+				if n := runtime.NumGoroutine() - base; n > goroutines {
+					goroutines = n
+				}
+				if n := openWatchers(pools); n > watchers {
+					watchers = n
+				}
+				for _, ar := range ars {
+					ar.Stop()
+				}
+			}
+			if bm.name == "pooled" && watchers != 1 {
+				b.Fatalf("%d AutoReloaders sharing a pool use %d fsnotify watchers, want 1", reloaders, watchers)
+			}
+			b.ReportMetric(float64(goroutines)/reloaders, "goroutines/reloader")
+			b.ReportMetric(float64(watchers)/reloaders, "watchers/reloader")
+		})
+	}
+}
+
+// openWatchers returns the number of the pools whose fsnotify watcher is
+// open.
+func openWatchers(pools map[*WatcherPool]bool) int {
+	n := 0
+	for pool := range pools {
+		pool.mu.Lock()
+		if pool.watcher != nil {
+			n++
+		}
+		pool.mu.Unlock()
+	}
+	return n
+}
+
+// BenchmarkEventStorm dispatches events through a WatcherPool shared by
+// a dev runner's worth of AutoReloaders, each logging the changes of its
+// own directory.
+func BenchmarkEventStorm(b *testing.B) {
+	cmds := commands(b, reloaders)
+	pool := NewWatcherPool()
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		dir := b.TempDir()
+		names[i] = filepath.Join(dir, "asset")
+		ar := New(WithCommand(cmd), WithLogger(nil), WithWatcherPool(pool), WithPath(dir, ActionLog))
+		ar.Start()
+		defer ar.Stop()
+	}
+	pool.mu.Lock()
+	events := pool.watcher.Events
+	pool.mu.Unlock()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		select {
+		case events <- fsnotify.Event{Name: names[i%len(names)], Op: fsnotify.Write}:
+		case <-time.After(5 * time.Second):
+			b.Fatal("the pool stopped dispatching events")
+		}
+	}
+}
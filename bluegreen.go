@@ -0,0 +1,203 @@
+package autoreload
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"time"
+)
+
+const defaultBlueGreenTimeout = 30 * time.Second
+
+// Environment variables used to tell a blue/green candidate how to
+// report that it is ready.
+const (
+	envParentPID   = "AUTORELOAD_PARENT_PID"
+	envReadySignal = "AUTORELOAD_READY_SIGNAL"
+)
+
+// BlueGreenConfig defines how the AutoReloader determines that a new
+// version of the application is ready to take over from the old one.
+type BlueGreenConfig struct {
+	// HealthURL is polled until the new process responds with a 2xx
+	// status code. The response must carry the Autoreload-Generation
+	// header of the new process, as set by GenerationHandler, since the
+	// old process may answer on the same address when both listen with
+	// SO_REUSEPORT.
+	HealthURL string
+
+	// ReadySignal is the signal that the new process sends to the old
+	// one once it is ready. The new process sends it by calling
+	// NotifyReady.
+	ReadySignal os.Signal
+
+	// Timeout is how long to wait for the new process to become ready.
+	// By default, this is 30 seconds.
+	Timeout time.Duration
+}
+
+// WithBlueGreen causes the AutoReloader to reload without a gap in
+// service. Instead of re-executing the process, the new version of the
+// application is started as a separate process while the old one keeps
+// running. Once the new process is ready, as determined by the config,
+// the reload callback and hooks are run and the old process exits. If the
+// new process does not become ready within the timeout, or a hook
+// panics, it is killed and the old process keeps running. When neither a
+// health URL nor a ready signal is defined, the new process is
+// considered ready as soon as it starts.
+//
+// Since both processes run at the same time, the application must be
+// able to tolerate a second instance, for example by listening with
+// SO_REUSEPORT or on a port supplied by the environment.
+func WithBlueGreen(cfg BlueGreenConfig) Option {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultBlueGreenTimeout
+	}
+	return func(autoReloader *AutoReloader) {
+		autoReloader.blueGreen = &cfg
+	}
+}
+
+// startBlueGreen starts the new version of the application and waits for
+// it to become ready. If it does not, the new process is killed and an
+// error is returned. Otherwise, it returns a function that kills the new
+// process, should the reload be aborted.
+func (ar AutoReloader) startBlueGreen(execPath string, paths []string) (kill func(), err error) {
+	cfg := ar.blueGreen
+
+	var ready chan os.Signal
+	env := ar.execEnv(paths...)
+	if cfg.ReadySignal != nil {
+		sig, ok := signalNumber(cfg.ReadySignal)
+		if !ok {
+			return nil, fmt.Errorf("unsupported ready signal: %v", cfg.ReadySignal)
+		}
+		ready = make(chan os.Signal, 1)
+		signal.Notify(ready, cfg.ReadySignal)
+		defer signal.Stop(ready)
+		env = append(env,
+			envParentPID+"="+strconv.Itoa(os.Getpid()),
+			envReadySignal+"="+strconv.Itoa(sig),
+		)
+	}
+
+	cmd := exec.Command(execPath)
+	cmd.Args = execArgs(execPath)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if len(ar.inheritFDs) > 0 {
+		// The descriptors are renumbered from 3 in the new process.
+		fds := make([]int, len(ar.inheritFDs))
+		for i, fd := range ar.inheritFDs {
+			f, err := dupFile(fd)
+			if err != nil {
+				return nil, fmt.Errorf("cannot inherit file descriptor %d: %w", fd, err)
+			}
+			defer f.Close()
+			cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+			fds[i] = 3 + i
+		}
+		cmd.Env = inheritEnv(cmd.Env, fds)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	kill = func() {
+		if err := cmd.Process.Kill(); err != nil {
+			ar.log().Error("Failed to kill new process", err)
+		}
+		<-exited
+	}
+	ar.log().Info(fmt.Sprintf("Started new process %d; waiting for it to become ready", cmd.Process.Pid))
+
+	healthy := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	switch {
+	case cfg.HealthURL != "":
+		go pollHealth(cfg.HealthURL, generation+1, healthy, stop)
+	case ready == nil:
+		close(healthy)
+	}
+
+	timeout := time.NewTimer(cfg.Timeout)
+	defer timeout.Stop()
+
+	select {
+	case <-healthy:
+		return kill, nil
+	case <-ready:
+		return kill, nil
+	case werr := <-exited:
+		return nil, fmt.Errorf("new process exited before becoming ready: %v", werr)
+	case <-timeout.C:
+	}
+	kill()
+	return nil, errors.New("new process did not become ready before the timeout")
+}
+
+// GenerationHeader is the response header that identifies the
+// generation of the process serving the health URL of a blue/green
+// reload.
+const GenerationHeader = "Autoreload-Generation"
+
+// GenerationHandler wraps the handler of the health URL of a blue/green
+// reload, setting GenerationHeader on every response so that the old
+// process can tell the responses of the new process from its own:
+//
+//	http.Handle("/health", autoreload.GenerationHandler(health))
+func GenerationHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(GenerationHeader, strconv.Itoa(generation))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// pollHealth requests the URL until the process of the generation
+// responds with a 2xx status code, at which point healthy is closed.
+// Responses of other generations are ignored.
+func pollHealth(url string, gen int, healthy chan<- struct{}, stop <-chan struct{}) {
+	client := &http.Client{Timeout: time.Second}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if resp, err := client.Get(url); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 && resp.Header.Get(GenerationHeader) == strconv.Itoa(gen) {
+				close(healthy)
+				return
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// notifyParent sends the ready signal to the process that started the
+// current one as a blue/green candidate, if any.
+func notifyParent() error {
+	pid, err := strconv.Atoi(os.Getenv(envParentPID))
+	if err != nil {
+		return nil
+	}
+	sig, err := strconv.Atoi(os.Getenv(envReadySignal))
+	if err != nil {
+		return nil
+	}
+	os.Unsetenv(envParentPID)
+	os.Unsetenv(envReadySignal)
+	return signalProcess(pid, sig)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !solaris
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!solaris
+
+package autoreload
+
+import "fmt"
+
+// signalProcess sends the signal to the process. Signals cannot be sent
+// to another process on this platform, so a blue/green candidate can
+// only be found ready by its HealthURL.
+func signalProcess(pid, sig int) error {
+	return fmt.Errorf("cannot send signal %d to process %d: %w", sig, pid, ErrUnsupportedPlatform)
+}
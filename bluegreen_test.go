@@ -0,0 +1,58 @@
+package autoreload
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestPollHealthIgnoresOtherGenerations checks that the health poll of
+// a blue/green reload is only satisfied by the new generation, rather
+// than by the old process answering on a shared address.
+func TestPollHealthIgnoresOtherGenerations(t *testing.T) {
+	var header atomic.Value
+	header.Store("")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gen := header.Load().(string); gen != "" {
+			w.Header().Set(GenerationHeader, gen)
+		}
+	}))
+	defer server.Close()
+
+	healthy := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go pollHealth(server.URL, 2, healthy, stop)
+
+	for _, gen := range []string{"", "1", "3"} {
+		header.Store(gen)
+		select {
+		case <-healthy:
+			t.Fatalf("healthy with %s %q, want 2", GenerationHeader, gen)
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+	header.Store("2")
+	select {
+	case <-healthy:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not healthy once the new generation answered")
+	}
+}
+
+func TestGenerationHandler(t *testing.T) {
+	handlers := map[string]http.Handler{
+		"GenerationHandler": GenerationHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})),
+		"StatusHandler":     New(WithLogger(nil)).StatusHandler(),
+	}
+	for name, h := range handlers {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if got, want := w.Header().Get(GenerationHeader), strconv.Itoa(generation); got != want {
+			t.Errorf("%s set %s to %q, want %q", name, GenerationHeader, got, want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import "syscall"
+
+// signalProcess sends the signal to the process, such as the ready
+// signal of a blue/green candidate to its parent.
+func signalProcess(pid, sig int) error {
+	return syscall.Kill(pid, syscall.Signal(sig))
+}
@@ -0,0 +1,61 @@
+package autoreload
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// BuildInfo describes the build of a Go executable, as recorded by the
+// Go toolchain.
+type BuildInfo struct {
+	// Version is the version of the main module.
+	Version string `json:"version,omitempty"`
+
+	// Revision is the version control revision the executable was built
+	// from.
+	Revision string `json:"revision,omitempty"`
+
+	// Time is when the revision was committed.
+	Time time.Time `json:"time,omitempty"`
+
+	// Modified reports whether the working tree had uncommitted changes.
+	Modified bool `json:"modified,omitempty"`
+
+	// GoVersion is the version of Go that built the executable.
+	GoVersion string `json:"go_version,omitempty"`
+}
+
+// MarshalJSON omits the time if it is unknown.
+func (b BuildInfo) MarshalJSON() ([]byte, error) {
+	type buildInfo BuildInfo
+	var t *time.Time
+	if !b.Time.IsZero() {
+		t = &b.Time
+	}
+	return json.Marshal(struct {
+		buildInfo
+		Time *time.Time `json:"time,omitempty"`
+	}{buildInfo(b), t})
+}
+
+// String returns a short description of the build, such as
+// "abc1234 (dirty)".
+func (b *BuildInfo) String() string {
+	if b == nil {
+		return "unknown build"
+	}
+	s := b.Version
+	if b.Revision != "" {
+		s = b.Revision
+		if len(s) > 7 {
+			s = s[:7]
+		}
+	}
+	if s == "" {
+		s = "unknown build"
+	}
+	if b.Modified {
+		s += " (dirty)"
+	}
+	return s
+}
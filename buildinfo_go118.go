@@ -0,0 +1,44 @@
+//go:build go1.18
+// +build go1.18
+
+package autoreload
+
+import (
+	"debug/buildinfo"
+	"runtime/debug"
+	"time"
+)
+
+// currentBuildInfo returns the build of the running executable.
+func currentBuildInfo() *BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	return newBuildInfo(info)
+}
+
+// ReadBuildInfo returns the build of the executable at path, or nil if
+// it is not a Go executable or was built without build information.
+func ReadBuildInfo(path string) *BuildInfo {
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return newBuildInfo(info)
+}
+
+func newBuildInfo(info *debug.BuildInfo) *BuildInfo {
+	b := &BuildInfo{Version: info.Main.Version, GoVersion: info.GoVersion}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			b.Revision = setting.Value
+		case "vcs.time":
+			b.Time, _ = time.Parse(time.RFC3339, setting.Value)
+		case "vcs.modified":
+			b.Modified = setting.Value == "true"
+		}
+	}
+	return b
+}
@@ -0,0 +1,15 @@
+//go:build !go1.18
+// +build !go1.18
+
+package autoreload
+
+func currentBuildInfo() *BuildInfo {
+	return nil
+}
+
+// ReadBuildInfo returns the build of the executable at path, or nil if
+// it is not a Go executable or was built without build information. It
+// always returns nil before Go 1.18.
+func ReadBuildInfo(path string) *BuildInfo {
+	return nil
+}
@@ -0,0 +1,67 @@
+package autoreload
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// maxContentSize caps how much of a content trigger is read and
+// remembered.
+const maxContentSize = 4096
+
+// WithContentTrigger watches a small file, such as a VERSION file or
+// build manifest, and reloads the application only when its content
+// differs from when it was last read. Events that leave the content
+// unchanged are ignored. Only the first 4KiB of the file is compared. A
+// failure to read the file is logged and not treated as a change.
+func WithContentTrigger(path string) Option {
+	return func(autoReloader *AutoReloader) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		autoReloader.state.paths[path] = ActionReload
+		autoReloader.state.contents[path] = nil
+	}
+}
+
+// readContents remembers the current content of every content trigger.
+// The caller must hold the state lock.
+func (ar AutoReloader) readContents() {
+	for path := range ar.state.contents {
+		content, err := readContent(path)
+		if err != nil {
+			ar.log().Error(fmt.Sprintf("Failed to read content trigger: %s", path), err)
+		}
+		ar.state.contents[path] = content
+	}
+}
+
+// contentChanged reports whether the path is a content trigger and, if
+// so, whether its content changed since it was last read.
+func (ar AutoReloader) contentChanged(path string) (isTrigger, changed bool) {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	earlier, ok := ar.state.contents[path]
+	if !ok {
+		return false, false
+	}
+	content, err := readContent(path)
+	if err != nil {
+		ar.log().Error(fmt.Sprintf("Failed to read content trigger: %s", path), err)
+		return true, false
+	}
+	ar.state.contents[path] = content
+	return true, !bytes.Equal(earlier, content)
+}
+
+func readContent(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxContentSize))
+}
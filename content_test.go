@@ -0,0 +1,62 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newContentTrigger creates a content trigger holding the content.
+func newContentTrigger(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "VERSION")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestContentTrigger checks that rewriting a content trigger with the
+// same content is skipped, while changing it reloads.
+func TestContentTrigger(t *testing.T) {
+	path := newContentTrigger(t, "1.0.0")
+	h := newTestReloader(t, WithContentTrigger(path), WithVerbosity(VerbosityDebug))
+	h.Start()
+
+	if err := ioutil.WriteFile(path, []byte("1.0.0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h.quiet()
+	if !h.logger.contains("Skipping reload: content unchanged: " + path) {
+		t.Error("the skipped reload was not logged")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("1.0.1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+}
+
+// TestContentTriggerReadError checks that a content trigger that cannot
+// be read is logged and not treated as a change.
+func TestContentTriggerReadError(t *testing.T) {
+	path := newContentTrigger(t, "1.0.0")
+	h := newTestReloader(t, WithContentTrigger(path))
+	h.Start()
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if h.shouldReload(path) {
+		t.Error("an unreadable content trigger reloads")
+	}
+	if !h.logger.contains("Failed to read content trigger: " + path) {
+		t.Error("the read error was not logged")
+	}
+}
@@ -0,0 +1,123 @@
+package autoreload
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrNotRunning is returned when requesting a reload from an
+// AutoReloader that has not been started.
+var ErrNotRunning = errors.New("autoreloader is not running")
+
+// WithRequireNewerMtime defines whether a change only reloads the
+// application if the modified time of the changed file is newer than the
+// later of the process start time and the time of the reload that
+// started the process. This avoids reloading when an older binary is
+// restored, for example by git checkout or a backup tool, or when stale
+// events are replayed. Files with a modified time granularity of a
+// second are compared at that granularity. Reloads requested with Reload
+// are not subject to the check.
+func WithRequireNewerMtime(enabled bool) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.requireNewerMtime = enabled
+	}
+}
+
+// ChangeDetector decides whether the change of a watched path warrants
+// a reload. It is called with the path that triggered the change.
+type ChangeDetector func(path string) (changed bool, err error)
+
+// WithChangeDetector defines a function that is consulted, once a change
+// has settled, to decide whether to reload the application. It replaces
+// the built-in checks, such as WithRequireNewerMtime. An error returned
+// by the detector is logged and treated as no change. Reloads requested
+// with Reload do not consult the detector.
+func WithChangeDetector(detector ChangeDetector) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.changeDetector = detector
+	}
+}
+
+// Reload requests that the application be reloaded immediately, as if
+// the executable had changed. It bypasses any checks that would skip a
+// reload. It returns ErrNotRunning if the AutoReloader has not been
+// started.
+func (ar AutoReloader) Reload() error {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	if ar.state.requests == nil {
+		return ErrNotRunning
+	}
+	select {
+	case ar.state.requests <- struct{}{}:
+	default:
+		// A reload is already pending.
+	}
+	return nil
+}
+
+// shouldReload decides whether the change of the path, once settled,
+// warrants a reload. It logs the reason for skipping one.
+func (ar AutoReloader) shouldReload(path string) bool {
+	if isTrigger, changed := ar.contentChanged(path); isTrigger {
+		if !changed {
+			ar.debug(fmt.Sprintf("Skipping reload: content unchanged: %s", path))
+		}
+		return changed
+	}
+	if ar.changeDetector != nil {
+		return ar.detectChange(path)
+	}
+	if ar.identityUnchanged(path) {
+		ar.debug(fmt.Sprintf("Skipping reload: file identity unchanged: %s", path))
+		return false
+	}
+	if ar.requireNewerMtime && !isNewer(path) {
+		ar.log().Info(fmt.Sprintf("Skipping reload: %s is not newer than the running process", path))
+		return false
+	}
+	return true
+}
+
+// detectChange consults the change detector, treating an error or panic
+// as no change.
+func (ar AutoReloader) detectChange(path string) bool {
+	var changed bool
+	var err error
+	if !ar.safely("Change detector panicked", func() {
+		changed, err = ar.changeDetector(path)
+	}) {
+		return false
+	}
+	if err != nil {
+		ar.log().Error(fmt.Sprintf("Change detector failed for %s; skipping reload", path), err)
+		return false
+	}
+	if !changed {
+		ar.log().Info(fmt.Sprintf("Skipping reload: change detector reported no change to %s", path))
+	}
+	return changed
+}
+
+// isNewer reports whether the file at path was modified after the
+// current process, or the reload that started it, began. A missing file
+// is treated as newer so that the replacement logic can handle it.
+func isNewer(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	ref := processStart
+	if lastReloadTime.After(ref) {
+		ref = lastReloadTime
+	}
+	mtime := info.ModTime()
+	if mtime.Nanosecond() == 0 {
+		// The filesystem only records whole seconds, so a file written
+		// in the same second as the reference may still be newer.
+		return !mtime.Before(ref.Truncate(time.Second))
+	}
+	return mtime.After(ref)
+}
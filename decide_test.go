@@ -0,0 +1,151 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeAged replaces the command, as a build would, with a file modified
+// at the time.
+func (h *testReloader) writeAged(content string, mtime time.Time) {
+	h.t.Helper()
+	tmp := h.cmd + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte("#!/bin/sh\n# "+content+"\n"), 0755); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := os.Chtimes(tmp, mtime, mtime); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := os.Rename(tmp, h.cmd); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// TestRequireNewerMtime checks that with WithRequireNewerMtime, an
+// executable older than the process does not reload, unless a reload is
+// requested, while a newer one does.
+func TestRequireNewerMtime(t *testing.T) {
+	h := newTestReloader(t, WithRequireNewerMtime(true))
+	h.Start()
+
+	h.writeAged("old", processStart.Add(-time.Hour))
+	h.quiet()
+	if !h.logger.contains("is not newer than the running process") {
+		t.Error("the skipped reload was not logged")
+	}
+
+	if err := h.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+
+	h = newTestReloader(t, WithRequireNewerMtime(true))
+	h.Start()
+	h.write("new")
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+}
+
+// TestIsNewerCoarseMtime checks that a modified time of whole seconds
+// counts as newer within the second the process started in.
+func TestIsNewerCoarseMtime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cmd")
+	if err := ioutil.WriteFile(path, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	second := processStart.Truncate(time.Second)
+	tests := []struct {
+		mtime time.Time
+		want  bool
+	}{
+		{second, true},
+		{second.Add(-time.Second), false},
+		{processStart.Add(-time.Millisecond), false},
+		{processStart.Add(time.Millisecond), true},
+	}
+	for _, tt := range tests {
+		if err := os.Chtimes(path, tt.mtime, tt.mtime); err != nil {
+			t.Fatal(err)
+		}
+		if got := isNewer(path); got != tt.want {
+			t.Errorf("isNewer with mtime %v and process start %v = %v, want %v", tt.mtime, processStart, got, tt.want)
+		}
+	}
+}
+
+// TestChangeDetector checks that the change detector decides whether a
+// change reloads, in place of the built-in checks, and that its errors
+// and panics skip the reload.
+func TestChangeDetector(t *testing.T) {
+	tests := []struct {
+		name   string
+		detect func() (bool, error)
+		reload bool
+		log    string
+	}{
+		{"changed", func() (bool, error) { return true, nil }, true, ""},
+		{"unchanged", func() (bool, error) { return false, nil }, false, "Skipping reload: change detector reported no change to "},
+		{"error", func() (bool, error) { return true, errors.New("boom") }, false, "Change detector failed for "},
+		{"panic", func() (bool, error) { panic("boom") }, false, "Change detector panicked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := make(chan string, 16)
+			h := newTestReloader(t, WithChangeDetector(func(path string) (bool, error) {
+				paths <- path
+				return tt.detect()
+			}))
+			h.Start()
+			// Only touch the executable, which the built-in checks
+			// would skip.
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(h.cmd, later, later); err != nil {
+				t.Fatal(err)
+			}
+			if tt.reload {
+				if call := h.exec(); call.err != nil {
+					t.Fatal(call.err)
+				}
+			} else {
+				h.quiet()
+			}
+			select {
+			case path := <-paths:
+				if path != h.cmd {
+					t.Errorf("detector called with %s, want %s", path, h.cmd)
+				}
+			default:
+				t.Error("detector not called")
+			}
+			if tt.log != "" && !h.logger.contains(tt.log) {
+				t.Errorf("log does not contain %q", tt.log)
+			}
+		})
+	}
+}
+
+// TestReloadBypassesChangeDetector checks that a requested reload does
+// not consult the change detector.
+func TestReloadBypassesChangeDetector(t *testing.T) {
+	h := newTestReloader(t, WithChangeDetector(func(string) (bool, error) {
+		t.Error("detector consulted for a requested reload")
+		return false, nil
+	}))
+	h.Start()
+	if err := h.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+}
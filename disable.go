@@ -0,0 +1,115 @@
+package autoreload
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DisableCheck decides whether the AutoReloader should be disabled, as
+// it should be when the application is not running in development. If
+// so, it returns the reason.
+type DisableCheck func() (reason string, disabled bool)
+
+// The process and host details consulted by the checks, which tests
+// replace.
+var (
+	getpid           = os.Getpid
+	executable       = os.Executable
+	stdinIsTerminal  = isTerminal
+	containerMarkers = []string{"/.dockerenv", "/run/.containerenv"}
+)
+
+// WithAutoDisable guards against shipping an application that reloads
+// itself. When any of the checks disables the AutoReloader, Start logs
+// the reason and returns without watching. If no checks are supplied,
+// DisabledByEnv, ProductionEnv, ReadOnlyInstall and ContainerInit are
+// used; supply a subset of them to override a check that misfires.
+func WithAutoDisable(checks ...DisableCheck) Option {
+	if len(checks) == 0 {
+		checks = []DisableCheck{DisabledByEnv, ProductionEnv, ReadOnlyInstall, ContainerInit}
+	}
+	return func(autoReloader *AutoReloader) {
+		autoReloader.disableChecks = checks
+	}
+}
+
+// DisabledByEnv disables the AutoReloader when AUTORELOAD_DISABLED is
+// set to 1 or true.
+func DisabledByEnv() (string, bool) {
+	if isTrue(os.Getenv("AUTORELOAD_DISABLED")) {
+		return "AUTORELOAD_DISABLED is set", true
+	}
+	return "", false
+}
+
+// ProductionEnv disables the AutoReloader when GO_ENV or APP_ENV names
+// a production environment, such as prod or production.
+func ProductionEnv() (string, bool) {
+	for _, key := range []string{"GO_ENV", "APP_ENV"} {
+		switch strings.ToLower(os.Getenv(key)) {
+		case "prod", "production", "live":
+			return fmt.Sprintf("%s is %s", key, os.Getenv(key)), true
+		}
+	}
+	return "", false
+}
+
+// ReadOnlyInstall disables the AutoReloader when standard input is not
+// a terminal and the executable lives in a directory that cannot be
+// written to, since such an executable is unlikely to be rebuilt.
+func ReadOnlyInstall() (string, bool) {
+	if stdinIsTerminal() {
+		return "", false
+	}
+	path, err := executable()
+	if err != nil {
+		return "", false
+	}
+	if dir := filepath.Dir(path); !writable(dir) {
+		return fmt.Sprintf("executable directory %s is read-only", dir), true
+	}
+	return "", false
+}
+
+// ContainerInit disables the AutoReloader when the process runs as PID
+// 1 in a container, unless AUTORELOAD_ENABLED is set to 1 or true.
+func ContainerInit() (string, bool) {
+	if getpid() != 1 || isTrue(os.Getenv("AUTORELOAD_ENABLED")) {
+		return "", false
+	}
+	for _, path := range containerMarkers {
+		if _, err := os.Stat(path); err == nil {
+			return "running as PID 1 in a container", true
+		}
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "running as PID 1 in a container", true
+	}
+	return "", false
+}
+
+// disabled returns the reason the AutoReloader is disabled, if any.
+func (ar AutoReloader) disabled() (string, bool) {
+	for _, check := range ar.disableChecks {
+		if reason, disabled := check(); disabled {
+			return reason, true
+		}
+	}
+	return "", false
+}
+
+// isTerminal reports whether standard input is a terminal.
+func isTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func isTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setenv sets an environment variable for the rest of the test; an
+// empty value unsets it.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestDisabledByEnv(t *testing.T) {
+	for value, want := range map[string]bool{"": false, "0": false, "1": true, "true": true, "YES": true} {
+		setenv(t, "AUTORELOAD_DISABLED", value)
+		if _, disabled := DisabledByEnv(); disabled != want {
+			t.Errorf("AUTORELOAD_DISABLED=%q: disabled = %v, want %v", value, disabled, want)
+		}
+	}
+}
+
+func TestProductionEnv(t *testing.T) {
+	tests := []struct {
+		goEnv, appEnv string
+		want          bool
+	}{
+		{"", "", false},
+		{"development", "", false},
+		{"production", "", true},
+		{"", "Prod", true},
+		{"staging", "live", true},
+	}
+	for _, tt := range tests {
+		setenv(t, "GO_ENV", tt.goEnv)
+		setenv(t, "APP_ENV", tt.appEnv)
+		if reason, disabled := ProductionEnv(); disabled != tt.want {
+			t.Errorf("GO_ENV=%q APP_ENV=%q: disabled = %v (%s), want %v", tt.goEnv, tt.appEnv, disabled, reason, tt.want)
+		}
+	}
+}
+
+func TestReadOnlyInstall(t *testing.T) {
+	writableDir := t.TempDir()
+	missingDir := filepath.Join(t.TempDir(), "missing")
+	tests := []struct {
+		name     string
+		terminal bool
+		dir      string
+		want     bool
+	}{
+		{"writable", false, writableDir, false},
+		{"read-only", false, missingDir, true},
+		{"terminal", true, missingDir, false},
+	}
+	defer func(terminal func() bool, exe func() (string, error)) {
+		stdinIsTerminal, executable = terminal, exe
+	}(stdinIsTerminal, executable)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terminal, path := tt.terminal, filepath.Join(tt.dir, "app")
+			stdinIsTerminal = func() bool { return terminal }
+			executable = func() (string, error) { return path, nil }
+			if reason, disabled := ReadOnlyInstall(); disabled != tt.want {
+				t.Errorf("disabled = %v (%s), want %v", disabled, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerInit(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), ".dockerenv")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), ".dockerenv")
+	tests := []struct {
+		name       string
+		pid        int
+		marker     string
+		kubernetes string
+		enabled    string
+		want       bool
+	}{
+		{"not init", 42, marker, "", "", false},
+		{"marker", 1, marker, "", "", true},
+		{"no marker", 1, missing, "", "", false},
+		{"kubernetes", 1, missing, "10.0.0.1", "", true},
+		{"enabled", 1, marker, "", "1", false},
+	}
+	defer func(pid func() int, markers []string) {
+		getpid, containerMarkers = pid, markers
+	}(getpid, containerMarkers)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pid := tt.pid
+			getpid = func() int { return pid }
+			containerMarkers = []string{tt.marker}
+			setenv(t, "KUBERNETES_SERVICE_HOST", tt.kubernetes)
+			setenv(t, "AUTORELOAD_ENABLED", tt.enabled)
+			if reason, disabled := ContainerInit(); disabled != tt.want {
+				t.Errorf("disabled = %v (%s), want %v", disabled, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithAutoDisable(t *testing.T) {
+	setenv(t, "AUTORELOAD_DISABLED", "1")
+	if _, disabled := New(WithAutoDisable()).disabled(); !disabled {
+		t.Error("default checks do not disable with AUTORELOAD_DISABLED set")
+	}
+	if reason, disabled := New(WithAutoDisable(ProductionEnv)).disabled(); disabled {
+		t.Errorf("supplied checks did not override the defaults: disabled (%s)", reason)
+	}
+	if _, disabled := New().disabled(); disabled {
+		t.Error("disabled without WithAutoDisable")
+	}
+}
+
+func TestStartWhenDisabled(t *testing.T) {
+	h := newTestReloader(t, WithAutoDisable(func() (string, bool) {
+		return "testing", true
+	}))
+	h.Start()
+	if !h.logger.contains("Autoreload disabled: testing") {
+		t.Error("Start did not log why it is disabled")
+	}
+	if h.Status().Running {
+		t.Error("Start ran a disabled AutoReloader")
+	}
+	h.write("v2")
+	h.quiet()
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"syscall"
+)
+
+// writable reports whether the directory can be written to.
+func writable(dir string) bool {
+	const wOK = 0x2
+	return syscall.Access(dir, wOK) == nil
+}
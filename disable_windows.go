@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package autoreload
+
+// writable reports whether the directory can be written to. It is not
+// checked on Windows.
+func writable(dir string) bool {
+	return true
+}
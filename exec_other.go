@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !solaris
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!solaris
+
+package autoreload
+
+// execSupported reports whether a process can replace itself with a new
+// executable. On Windows, the new executable can only be started by
+// WithBlueGreen, or by a supervisor with WithExitInsteadOfExec.
+const execSupported = false
+
+// execve replaces the process with the executable.
+func execve(argv0 string, argv []string, envv []string) error {
+	return ErrUnsupportedPlatform
+}
+
+func retryableExecError(err error) bool {
+	return false
+}
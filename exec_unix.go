@@ -0,0 +1,26 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import "syscall"
+
+// execSupported reports whether a process can replace itself with a new
+// executable.
+const execSupported = true
+
+// execve replaces the process with the executable.
+var execve = syscall.Exec
+
+// retryableExecError reports whether the exec failed in a way that may
+// succeed on a later attempt, such as the executable being busy,
+// missing, partially written or not yet executable.
+func retryableExecError(err error) bool {
+	if errno, ok := err.(syscall.Errno); ok {
+		switch errno {
+		case syscall.ETXTBSY, syscall.ENOENT, syscall.ENOEXEC, syscall.EACCES:
+			return true
+		}
+	}
+	return false
+}
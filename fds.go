@@ -0,0 +1,82 @@
+package autoreload
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envInheritFDs lists the file descriptors that the previous generation
+// kept open for the current one, as numbered in the current one.
+const envInheritFDs = "AUTORELOAD_INHERIT_FDS"
+
+// inheritedFDs are the file descriptors that the previous generation
+// kept open for the current one. They are removed from the environment
+// so that they do not leak into child processes.
+var inheritedFDs = readInheritedFDs()
+
+func readInheritedFDs() []int {
+	value, ok := os.LookupEnv(envInheritFDs)
+	if !ok {
+		return nil
+	}
+	os.Unsetenv(envInheritFDs)
+
+	var fds []int
+	for _, s := range strings.Split(value, ",") {
+		if fd, err := strconv.Atoi(s); err == nil {
+			fds = append(fds, fd)
+		}
+	}
+	return fds
+}
+
+// WithInheritFDs keeps the file descriptors open across reloads, such as
+// a log pipe or a socket handed to the process by its parent, which
+// would otherwise be closed if they are close-on-exec. The new process
+// finds them with InheritedFDs. A descriptor that is not open when the
+// executable changes aborts the reload. It is not supported on Windows.
+func WithInheritFDs(fds ...int) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.inheritFDs = append(autoReloader.inheritFDs, fds...)
+	}
+}
+
+// InheritedFDs returns the file descriptors that the previous generation
+// kept open with WithInheritFDs. A descriptor keeps its number across a
+// re-exec, but is renumbered from 3 when the new process is started as
+// a blue/green candidate.
+func InheritedFDs() []int {
+	return append([]int(nil), inheritedFDs...)
+}
+
+// prepareInheritFDs clears close-on-exec on the descriptors to inherit,
+// failing if one of them is not open.
+func (ar AutoReloader) prepareInheritFDs() error {
+	for _, fd := range ar.inheritFDs {
+		if err := clearCloseOnExec(fd); err != nil {
+			return fmt.Errorf("cannot inherit file descriptor %d: %w", fd, err)
+		}
+	}
+	return nil
+}
+
+// inheritEnv returns the environment with the descriptors that the new
+// process inherits, as numbered in the new process.
+func inheritEnv(env []string, fds []int) []string {
+	out := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if envKey(kv) != envInheritFDs {
+			out = append(out, kv)
+		}
+	}
+	if len(fds) == 0 {
+		return out
+	}
+	values := make([]string, len(fds))
+	for i, fd := range fds {
+		values[i] = strconv.Itoa(fd)
+	}
+	return append(out, envInheritFDs+"="+strings.Join(values, ","))
+}
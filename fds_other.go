@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !solaris
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!solaris
+
+package autoreload
+
+import "os"
+
+func clearCloseOnExec(fd int) error {
+	return ErrUnsupportedPlatform
+}
+
+func dupFile(fd int) (*os.File, error) {
+	return nil, ErrUnsupportedPlatform
+}
@@ -0,0 +1,69 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestInheritFDs checks that a reload clears close-on-exec on the
+// descriptors to inherit and records them for the next generation,
+// which finds them with InheritedFDs.
+func TestInheritFDs(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	fd := int(w.Fd())
+
+	h := newTestReloader(t, WithInheritFDs(fd))
+	h.Start()
+	h.write("v2")
+	call := h.exec()
+	if call.err != nil {
+		t.Fatal(call.err)
+	}
+
+	flags, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags&unix.FD_CLOEXEC != 0 {
+		t.Error("close-on-exec is still set")
+	}
+	want := fmt.Sprintf("%s=%d", envInheritFDs, fd)
+	found := false
+	for _, kv := range call.envv {
+		found = found || kv == want
+	}
+	if !found {
+		t.Errorf("the environment does not contain %s", want)
+	}
+	if report := nextGeneration(t, call); !reflect.DeepEqual(report.InheritedFDs, []int{fd}) {
+		t.Errorf("InheritedFDs() = %v, want [%d]", report.InheritedFDs, fd)
+	}
+}
+
+// TestInheritClosedFD checks that a descriptor to inherit that is not
+// open aborts the reload.
+func TestInheritClosedFD(t *testing.T) {
+	const fd = 999
+	if _, err := unix.FcntlInt(fd, unix.F_GETFD, 0); err == nil {
+		t.Skipf("file descriptor %d is open", fd)
+	}
+	h := newTestReloader(t, WithInheritFDs(fd))
+	h.Start()
+	h.write("v2")
+	h.quiet()
+	if want := fmt.Sprintf("Reload aborted: cannot inherit file descriptor %d", fd); !h.logger.contains(want) {
+		t.Errorf("log does not contain %q", want)
+	}
+}
@@ -0,0 +1,116 @@
+package autoreload
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FlagValues holds the values of the flags registered by RegisterFlags.
+type FlagValues struct {
+	Enabled      bool
+	Command      string
+	Watch        []string
+	Debounce     time.Duration
+	MaxAttempts  int
+	PollInterval time.Duration
+	Verbosity    Verbosity
+}
+
+// RegisterFlags registers a standard set of flags for configuring an
+// AutoReloader on the flag set, which defaults to flag.CommandLine when
+// nil. The flag names begin with the prefix, which defaults to
+// "autoreload":
+//
+//	-autoreload                 enable autoreload
+//	-autoreload-command         command executable to watch
+//	-autoreload-watch           additional path to watch (repeatable)
+//	-autoreload-debounce        time to wait for changes to settle
+//	-autoreload-max-attempts    attempts to reload the application
+//	-autoreload-poll-interval   poll for changes at this interval
+//	-autoreload-verbosity       logging verbosity (default or debug)
+//
+// Autoreload is disabled unless the first flag is set.
+func RegisterFlags(fs *flag.FlagSet, prefix string) *FlagValues {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	if prefix == "" {
+		prefix = "autoreload"
+	}
+	values := &FlagValues{
+		Debounce:    defaultDebounce,
+		MaxAttempts: defaultMaxAttempts,
+	}
+	fs.BoolVar(&values.Enabled, prefix, false, "enable autoreload")
+	fs.StringVar(&values.Command, prefix+"-command", "", "command executable to watch (default: the running executable)")
+	fs.Var((*stringsFlag)(&values.Watch), prefix+"-watch", "additional `path` to watch; may be repeated")
+	fs.DurationVar(&values.Debounce, prefix+"-debounce", values.Debounce, "time to wait for changes to settle before reloading")
+	fs.IntVar(&values.MaxAttempts, prefix+"-max-attempts", values.MaxAttempts, "attempts to reload the application; -1 for unlimited")
+	fs.DurationVar(&values.PollInterval, prefix+"-poll-interval", 0, "poll for changes at this interval instead of using file system notifications")
+	fs.Var(&values.Verbosity, prefix+"-verbosity", "logging verbosity: default or debug")
+	return values
+}
+
+// Options returns the options described by the flag values and whether
+// autoreload is enabled.
+func (v *FlagValues) Options() ([]Option, bool) {
+	opts := []Option{
+		WithDebounce(v.Debounce),
+		WithMaxAttempts(v.MaxAttempts),
+		WithVerbosity(v.Verbosity),
+	}
+	if v.Command != "" {
+		opts = append(opts, WithCommand(v.Command))
+	}
+	for _, path := range v.Watch {
+		opts = append(opts, WithPath(path, ActionReload))
+	}
+	if v.PollInterval > 0 {
+		opts = append(opts, WithPollInterval(v.PollInterval))
+	}
+	return opts, v.Enabled
+}
+
+// stringsFlag is a flag.Value that collects every occurrence of a
+// repeated flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// String returns the name of the verbosity.
+func (v Verbosity) String() string {
+	switch v {
+	case VerbosityDefault:
+		return "default"
+	case VerbosityDebug:
+		return "debug"
+	default:
+		return fmt.Sprintf("Verbosity(%d)", int(v))
+	}
+}
+
+// Set parses the name of a verbosity, allowing a Verbosity to be used as
+// a flag.Value.
+func (v *Verbosity) Set(value string) error {
+	switch value {
+	case "default":
+		*v = VerbosityDefault
+	case "debug":
+		*v = VerbosityDebug
+	default:
+		return fmt.Errorf("unknown verbosity %q: expected default or debug", value)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package autoreload
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// flagConfig is the part of an AutoReloader that the flags configure.
+type flagConfig struct {
+	Cmd          string
+	Debounce     time.Duration
+	MaxAttempts  int
+	Verbosity    Verbosity
+	PollInterval time.Duration
+	Paths        map[string]Action
+}
+
+func configOf(opts []Option) flagConfig {
+	ar := newAutoReloader(opts)
+	return flagConfig{
+		Cmd:          ar.cmd,
+		Debounce:     ar.debounce,
+		MaxAttempts:  ar.maxAttempts,
+		Verbosity:    ar.verbosity,
+		PollInterval: ar.pollInterval,
+		Paths:        ar.state.paths,
+	}
+}
+
+// parseFlags registers the flags with the prefix on a new flag set and
+// parses the arguments.
+func parseFlags(t *testing.T, prefix string, args []string) (*flag.FlagSet, *FlagValues) {
+	t.Helper()
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	values := RegisterFlags(fs, prefix)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parsing %q: %v", args, err)
+	}
+	return fs, values
+}
+
+// flagArgs renders the flags that were set as arguments, one for each
+// occurrence of a repeated flag.
+func flagArgs(fs *flag.FlagSet) []string {
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		if paths, ok := f.Value.(*stringsFlag); ok {
+			for _, path := range *paths {
+				args = append(args, "-"+f.Name+"="+path)
+			}
+			return
+		}
+		args = append(args, "-"+f.Name+"="+f.Value.String())
+	})
+	return args
+}
+
+// TestFlagsRoundTrip checks that the flags configure the AutoReloader as
+// the equivalent options do, and still do once rendered back to
+// arguments and parsed again.
+func TestFlagsRoundTrip(t *testing.T) {
+	watch := filepath.Join(t.TempDir(), "templates")
+	tests := []struct {
+		name    string
+		prefix  string
+		args    []string
+		enabled bool
+		opts    []Option
+	}{
+		{name: "defaults"},
+		{
+			name:    "enabled",
+			args:    []string{"-autoreload"},
+			enabled: true,
+		},
+		{
+			name: "every flag",
+			args: []string{
+				"-autoreload", "-autoreload-command=/bin/app", "-autoreload-watch=" + watch,
+				"-autoreload-watch=config.yaml", "-autoreload-debounce=1s", "-autoreload-max-attempts=3",
+				"-autoreload-poll-interval=2s", "-autoreload-verbosity=debug",
+			},
+			enabled: true,
+			opts: []Option{
+				WithCommand("/bin/app"), WithPath(watch, ActionReload), WithPath("config.yaml", ActionReload),
+				WithDebounce(time.Second), WithMaxAttempts(3), WithPollInterval(2 * time.Second),
+				WithVerbosity(VerbosityDebug),
+			},
+		},
+		{
+			name:    "unlimited attempts",
+			args:    []string{"-autoreload", "-autoreload-max-attempts=-1"},
+			enabled: true,
+			opts:    []Option{WithMaxAttempts(UnlimitedAttempts)},
+		},
+		{
+			name:    "prefix",
+			prefix:  "reload",
+			args:    []string{"-reload", "-reload-debounce=50ms", "-reload-verbosity=default"},
+			enabled: true,
+			opts:    []Option{WithDebounce(50 * time.Millisecond), WithVerbosity(VerbosityDefault)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := configOf(tt.opts)
+			fs, _ := parseFlags(t, tt.prefix, tt.args)
+			for _, args := range [][]string{tt.args, flagArgs(fs)} {
+				_, values := parseFlags(t, tt.prefix, args)
+				opts, enabled := values.Options()
+				if enabled != tt.enabled {
+					t.Errorf("%q: enabled = %v, want %v", args, enabled, tt.enabled)
+				}
+				if got := configOf(opts); !reflect.DeepEqual(got, want) {
+					t.Errorf("%q: configured %+v, want %+v", args, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFlagsInvalidVerbosity(t *testing.T) {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	RegisterFlags(fs, "")
+	if err := fs.Parse([]string{"-autoreload-verbosity=loud"}); err == nil {
+		t.Error("parsed an unknown verbosity")
+	}
+}
+
+func TestVerbosityString(t *testing.T) {
+	for _, v := range []Verbosity{VerbosityDefault, VerbosityDebug} {
+		var parsed Verbosity
+		if err := parsed.Set(v.String()); err != nil || parsed != v {
+			t.Errorf("Set(%q) = %v, %v; want %v", v.String(), parsed, err, v)
+		}
+	}
+	if s := Verbosity(7).String(); s != "Verbosity(7)" {
+		t.Errorf("String of an unknown verbosity = %q", s)
+	}
+}
@@ -0,0 +1,73 @@
+package autoreload
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables used to pass information about the reload to
+// the re-executed process.
+const (
+	envGeneration = "AUTORELOAD_GENERATION"
+	envReloadTime = "AUTORELOAD_RELOAD_TIME"
+	envReloadPath = "AUTORELOAD_RELOAD_PATH"
+)
+
+var (
+	// processStart is the time at which the current process started.
+	processStart = time.Now()
+
+	// generation counts the processes in the reload chain, starting at 1
+	// for the process that was not started by a reload.
+	generation = envGenerationValue()
+
+	// lastReloadTime and lastReloadPaths describe the reload that
+	// started the current process, if any.
+	lastReloadTime, _ = time.Parse(time.RFC3339Nano, os.Getenv(envReloadTime))
+	lastReloadPaths   = filepath.SplitList(os.Getenv(envReloadPath))
+)
+
+// lastReloadPath returns the path whose change triggered the reload that
+// started the current process.
+func lastReloadPath() string {
+	if len(lastReloadPaths) == 0 {
+		return ""
+	}
+	return lastReloadPaths[0]
+}
+
+func envGenerationValue() int {
+	n, err := strconv.Atoi(os.Getenv(envGeneration))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// reloadEnv returns the environment for the re-executed process,
+// describing the reload that was triggered by the supplied paths.
+func reloadEnv(environ []string, paths ...string) []string {
+	env := make([]string, 0, len(environ)+3)
+	for _, kv := range environ {
+		switch envKey(kv) {
+		case envGeneration, envReloadTime, envReloadPath, envHandoff, envReloadRecord:
+		default:
+			env = append(env, kv)
+		}
+	}
+	return append(env,
+		envGeneration+"="+strconv.Itoa(generation+1),
+		envReloadTime+"="+time.Now().Format(time.RFC3339Nano),
+		envReloadPath+"="+strings.Join(paths, string(filepath.ListSeparator)),
+	)
+}
+
+func envKey(kv string) string {
+	if i := strings.IndexByte(kv, '='); i >= 0 {
+		return kv[:i]
+	}
+	return kv
+}
@@ -2,4 +2,7 @@ module github.com/agschwender/autoreload
 
 go 1.16
 
-require github.com/fsnotify/fsnotify v1.6.0
+require (
+	github.com/fsnotify/fsnotify v1.6.0
+	golang.org/x/sys v0.0.0-20220908164124-27713097b956
+)
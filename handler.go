@@ -0,0 +1,29 @@
+package autoreload
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// StatusHandler returns a read-only http.Handler that serves the status
+// of the AutoReloader in its JSON form. It cannot be used to trigger a
+// reload, so it is safe to mount on an application's own server. This is
+// useful for a frontend that polls to detect that the server has been
+// reloaded. As with GenerationHandler, it sets GenerationHeader, so it
+// can serve the health URL of a blue/green reload.
+func (ar AutoReloader) StatusHandler() http.Handler {
+	return GenerationHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		w.Header().Set("Expires", "0")
+		if err := json.NewEncoder(w).Encode(ar.Status()); err != nil {
+			ar.log().Error("Failed to write status", err)
+		}
+	}))
+}
@@ -0,0 +1,97 @@
+package autoreload
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// maxHandoffSize caps the size of the serialized handoff data so that it
+// cannot exhaust the space available for the environment.
+const maxHandoffSize = 32 * 1024
+
+const envHandoff = "AUTORELOAD_HANDOFF"
+
+// ErrHandoffTooLarge is returned when the handoff data would exceed the
+// size limit.
+var ErrHandoffTooLarge = errors.New("handoff data too large")
+
+// handoff holds the data handed off by the previous generation. It is
+// removed from the environment so that it does not leak into child
+// processes or later generations.
+var handoff, handoffOK = readHandoff()
+
+func readHandoff() (map[string]string, bool) {
+	value, ok := os.LookupEnv(envHandoff)
+	if !ok {
+		return nil, false
+	}
+	os.Unsetenv(envHandoff)
+
+	var data map[string]string
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// IsReloaded reports whether the current process was started by a
+// reload.
+func IsReloaded() bool {
+	return generation > 1
+}
+
+// Handoff returns the data that the previous generation stashed with
+// SetHandoff. The boolean reports whether any data was handed off. The
+// data is only available to the generation immediately following the
+// one that set it.
+func Handoff() (map[string]string, bool) {
+	if !handoffOK {
+		return nil, false
+	}
+	data := make(map[string]string, len(handoff))
+	for k, v := range handoff {
+		data[k] = v
+	}
+	return data, true
+}
+
+// SetHandoff stashes a key/value pair to hand off to the next
+// generation, which can read it with Handoff. The data is passed through
+// the environment, so its total serialized size is limited to 32KiB.
+func (ar AutoReloader) SetHandoff(key, value string) error {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+
+	data := make(map[string]string, len(ar.state.handoff)+1)
+	for k, v := range ar.state.handoff {
+		data[k] = v
+	}
+	data[key] = value
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if len(encoded) > maxHandoffSize {
+		return ErrHandoffTooLarge
+	}
+	ar.state.handoff = data
+	return nil
+}
+
+// execEnv returns the environment for the next generation, describing
+// the reload triggered by path and carrying any handoff data.
+func (ar AutoReloader) execEnv(paths ...string) []string {
+	env := inheritEnv(reloadEnv(os.Environ(), paths...), ar.inheritFDs)
+
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	if len(ar.state.handoff) == 0 {
+		return env
+	}
+	encoded, err := json.Marshal(ar.state.handoff)
+	if err != nil {
+		return env
+	}
+	return append(env, envHandoff+"="+string(encoded))
+}
@@ -0,0 +1,63 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestHandoff checks that the next generation knows it was reloaded and
+// receives the handoff data, which it does not pass on unless set again.
+func TestHandoff(t *testing.T) {
+	h := newTestReloader(t)
+	if err := h.SetHandoff("token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	h.Start()
+	h.write("v2")
+	call := h.exec()
+
+	report := nextGeneration(t, call)
+	if !report.Reloaded {
+		t.Error("IsReloaded is false in the next generation")
+	}
+	if want := map[string]string{"token": "abc"}; !report.HandoffOK || !reflect.DeepEqual(report.Handoff, want) {
+		t.Errorf("Handoff = %v, %v in the next generation, want %v", report.Handoff, report.HandoffOK, want)
+	}
+	if report.Passed {
+		t.Error("the handoff would be passed on to the generation after")
+	}
+}
+
+// TestNoHandoff checks that the next generation receives no handoff data
+// if none was set.
+func TestNoHandoff(t *testing.T) {
+	h := newTestReloader(t)
+	h.Start()
+	h.write("v2")
+
+	report := nextGeneration(t, h.exec())
+	if !report.Reloaded {
+		t.Error("IsReloaded is false in the next generation")
+	}
+	if report.HandoffOK {
+		t.Errorf("Handoff = %v in the next generation, want none", report.Handoff)
+	}
+}
+
+func TestSetHandoffTooLarge(t *testing.T) {
+	ar := New(WithLogger(nil))
+	if err := ar.SetHandoff("small", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ar.SetHandoff("large", strings.Repeat("x", maxHandoffSize)); !errors.Is(err, ErrHandoffTooLarge) {
+		t.Errorf("SetHandoff of %d bytes: %v, want ErrHandoffTooLarge", maxHandoffSize, err)
+	}
+	if env := strings.Join(ar.execEnv(), "\n"); !strings.Contains(env, envHandoff+`={"small":"value"}`) {
+		t.Error("the handoff lost the data set before the one that was too large")
+	}
+}
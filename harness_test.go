@@ -0,0 +1,260 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// envCandidate names the file that the test binary, started as a
+// blue/green candidate by a reload, writes its pid to.
+const envCandidate = "AUTORELOAD_TEST_CANDIDATE"
+
+// envReport names the file that the test binary, started as the next
+// generation with the environment of a faked exec, reports what it
+// received to.
+const envReport = "AUTORELOAD_TEST_REPORT"
+
+func TestMain(m *testing.M) {
+	if path := os.Getenv(envCandidate); path != "" {
+		runCandidate(path)
+	}
+	if path := os.Getenv(envReport); path != "" {
+		runReport(path)
+	}
+	os.Exit(m.Run())
+}
+
+// runCandidate stands in for the new process of a blue/green reload,
+// which reports that it is ready once it has written its pid. It runs
+// until it is killed, or for a minute at most should the test not kill
+// it.
+func runCandidate(path string) {
+	if err := ioutil.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		os.Exit(1)
+	}
+	if err := NotifyReady(); err != nil {
+		os.Exit(1)
+	}
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+// generationReport is what the next generation received.
+type generationReport struct {
+	Reloaded  bool
+	Handoff   map[string]string
+	HandoffOK bool
+
+	// Passed reports whether the handoff would be passed on to the
+	// generation after.
+	Passed bool
+
+	InheritedFDs []int
+}
+
+// runReport stands in for the next generation, which reports what it
+// received and exits.
+func runReport(path string) {
+	report := generationReport{Reloaded: IsReloaded(), InheritedFDs: InheritedFDs()}
+	report.Handoff, report.HandoffOK = Handoff()
+	for _, kv := range New(WithLogger(nil)).execEnv() {
+		if strings.HasPrefix(kv, envHandoff+"=") {
+			report.Passed = true
+		}
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// nextGeneration runs the test binary as the generation started by the
+// exec, and returns what it received.
+func nextGeneration(t *testing.T, call execCall) generationReport {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "report")
+	cmd := exec.Command(call.argv0)
+	cmd.Env = append(append([]string{}, call.envv...), envReport+"="+path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("next generation: %v: %s", err, out)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report generationReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// recordingLogger records the messages it is given.
+type recordingLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *recordingLogger) Info(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, msg)
+}
+
+func (l *recordingLogger) Error(msg string, err error) {
+	l.Info(fmt.Sprintf("%s: %v", msg, err))
+}
+
+// contains reports whether a message containing s was logged.
+func (l *recordingLogger) contains(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, msg := range l.msgs {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// execCall is a call of the faked execve.
+type execCall struct {
+	argv0 string
+	argv  []string
+	envv  []string
+	err   error
+}
+
+// testReloader is an AutoReloader watching a command in a temporary
+// directory, whose exec and exit are faked. A successful exec or an exit
+// ends the goroutine that performed it, as replacing the process would.
+type testReloader struct {
+	AutoReloader
+	t      testing.TB
+	cmd    string
+	logger *recordingLogger
+	execs  chan execCall
+	exits  chan int
+
+	mu       sync.Mutex
+	execErrs []error
+}
+
+// newTestReloader creates a testReloader. The supplied options are
+// applied after those of the harness, which they may override. The
+// AutoReloader is stopped at the end of the test.
+func newTestReloader(t testing.TB, opts ...Option) *testReloader {
+	t.Helper()
+	h := &testReloader{
+		t:      t,
+		cmd:    filepath.Join(t.TempDir(), "cmd"),
+		logger: &recordingLogger{},
+		execs:  make(chan execCall, 64),
+		exits:  make(chan int, 64),
+	}
+	h.write("v1")
+	defaults := []Option{
+		WithCommand(h.cmd),
+		WithLogger(h.logger),
+		WithDebounce(20 * time.Millisecond),
+		WithReplacementTimeout(time.Second),
+		WithWatcherPool(NewWatcherPool()),
+		h.fake,
+	}
+	h.AutoReloader = New(append(defaults, opts...)...)
+	t.Cleanup(h.Stop)
+	return h
+}
+
+// fake replaces the exec and exit of the AutoReloader.
+func (h *testReloader) fake(ar *AutoReloader) {
+	ar.execve = func(argv0 string, argv []string, envv []string) error {
+		h.mu.Lock()
+		var err error
+		if len(h.execErrs) > 0 {
+			err, h.execErrs = h.execErrs[0], h.execErrs[1:]
+		}
+		h.mu.Unlock()
+		h.execs <- execCall{argv0: argv0, argv: argv, envv: envv, err: err}
+		if err == nil {
+			runtime.Goexit()
+		}
+		return err
+	}
+	ar.exit = func(code int) {
+		h.exits <- code
+		runtime.Goexit()
+	}
+}
+
+// failExecs makes the next execs fail with the errors, in order.
+func (h *testReloader) failExecs(errs ...error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.execErrs = append(h.execErrs, errs...)
+}
+
+// write replaces the command, as a build would.
+func (h *testReloader) write(content string) {
+	h.t.Helper()
+	tmp := h.cmd + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte("#!/bin/sh\n# "+content+"\n"), 0755); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := os.Rename(tmp, h.cmd); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// exec waits for the next exec.
+func (h *testReloader) exec() execCall {
+	h.t.Helper()
+	select {
+	case call := <-h.execs:
+		return call
+	case <-time.After(10 * time.Second):
+		h.t.Fatal("timed out waiting for an exec")
+	}
+	return execCall{}
+}
+
+// exit waits for the next exit and returns its code.
+func (h *testReloader) exit() int {
+	h.t.Helper()
+	select {
+	case code := <-h.exits:
+		return code
+	case <-time.After(10 * time.Second):
+		h.t.Fatal("timed out waiting for an exit")
+	}
+	return 0
+}
+
+// quiet checks that neither an exec nor an exit happens for a while.
+func (h *testReloader) quiet() {
+	h.t.Helper()
+	select {
+	case call := <-h.execs:
+		h.t.Fatalf("unexpected exec of %s", call.argv0)
+	case code := <-h.exits:
+		h.t.Fatalf("unexpected exit with code %d", code)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
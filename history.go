@@ -0,0 +1,296 @@
+package autoreload
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultHistorySize = 10
+
+// historyFileSize is the number of reloads that the history file keeps.
+const historyFileSize = 100
+
+// envReloadRecord passes the record of the reload that executes it to
+// the new process, which adds it to the history.
+const envReloadRecord = "AUTORELOAD_RELOAD_RECORD"
+
+// ReloadOutcome describes how a reload ended.
+type ReloadOutcome string
+
+const (
+	// ReloadExecuted indicates that the process was re-executed. It is
+	// recorded by the new process, once the exec has succeeded.
+	ReloadExecuted ReloadOutcome = "executed"
+
+	// ReloadExited indicates that the process was about to exit so that
+	// a supervisor could restart it.
+	ReloadExited ReloadOutcome = "exited"
+
+	// ReloadAborted indicates that the reload was abandoned and the
+	// AutoReloader continued watching.
+	ReloadAborted ReloadOutcome = "aborted"
+
+	// ReloadFailed indicates that the process could not be re-executed.
+	ReloadFailed ReloadOutcome = "failed"
+)
+
+// ReloadInfo describes a single reload.
+type ReloadInfo struct {
+	// Name identifies the AutoReloader that performed the reload.
+	Name string
+
+	// Time is when the reload was recorded.
+	Time time.Time
+
+	// Path is the watched path whose change triggered the reload. It is
+	// empty for reloads requested with Reload.
+	Path string
+
+	// Paths lists every watched path that changed while the reload was
+	// settling, beginning with Path.
+	Paths []string
+
+	// Outcome describes how the reload ended.
+	Outcome ReloadOutcome
+
+	// Attempts is the number of exec attempts made.
+	Attempts int
+
+	// Err is the reason an aborted or failed reload did not succeed.
+	Err error
+
+	// FromBuild describes the build of the process that performed the
+	// reload. It is nil if the build is unknown.
+	FromBuild *BuildInfo
+
+	// ToBuild describes the build of the executable being reloaded. It
+	// is nil if the executable is not a Go executable.
+	ToBuild *BuildInfo
+}
+
+// reloadRecord is the JSON representation of a ReloadInfo.
+type reloadRecord struct {
+	Name      string        `json:"name"`
+	Time      time.Time     `json:"time"`
+	Path      string        `json:"path"`
+	Paths     []string      `json:"paths,omitempty"`
+	Outcome   ReloadOutcome `json:"outcome"`
+	Attempts  int           `json:"attempts"`
+	Error     string        `json:"error,omitempty"`
+	FromBuild *BuildInfo    `json:"from_build,omitempty"`
+	ToBuild   *BuildInfo    `json:"to_build,omitempty"`
+}
+
+func (info ReloadInfo) record() reloadRecord {
+	r := reloadRecord{
+		Name:      info.Name,
+		Time:      info.Time,
+		Path:      info.Path,
+		Paths:     info.Paths,
+		Outcome:   info.Outcome,
+		Attempts:  info.Attempts,
+		FromBuild: info.FromBuild,
+		ToBuild:   info.ToBuild,
+	}
+	if info.Err != nil {
+		r.Error = info.Err.Error()
+	}
+	return r
+}
+
+func (r reloadRecord) info() ReloadInfo {
+	info := ReloadInfo{
+		Name:      r.Name,
+		Time:      r.Time,
+		Path:      r.Path,
+		Paths:     r.Paths,
+		Outcome:   r.Outcome,
+		Attempts:  r.Attempts,
+		FromBuild: r.FromBuild,
+		ToBuild:   r.ToBuild,
+	}
+	if r.Error != "" {
+		info.Err = errors.New(r.Error)
+	}
+	return info
+}
+
+// WithHistorySize defines how many reloads the AutoReloader remembers
+// for History. By default, this is 10. A size of 0 or less disables the
+// history.
+func WithHistorySize(size int) Option {
+	if size < 0 {
+		size = 0
+	}
+	return func(autoReloader *AutoReloader) {
+		autoReloader.historySize = size
+	}
+}
+
+// WithHistoryFile defines a file to which every reload is appended as a
+// line of JSON. Since re-executing the process discards its memory, the
+// file allows the history to survive reloads: it is read back when the
+// AutoReloader is created. The file keeps the 100 most recent reloads.
+func WithHistoryFile(path string) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.historyFile = path
+	}
+}
+
+// History returns the most recent reloads, oldest first.
+func (ar AutoReloader) History() []ReloadInfo {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	history := make([]ReloadInfo, len(ar.state.history))
+	copy(history, ar.state.history)
+	return history
+}
+
+// remember adds the reload to the history, appending it to the history
+// file if one was supplied.
+func (ar AutoReloader) remember(info ReloadInfo) {
+	info.Name = ar.name()
+
+	ar.state.mu.Lock()
+	ar.appendHistory(info)
+	ar.state.mu.Unlock()
+
+	if ar.historyFile == "" {
+		return
+	}
+	if err := appendHistoryFile(ar.historyFile, info); err != nil {
+		ar.log().Error("Failed to write history file", err)
+	}
+}
+
+// executedEnv returns the environment for the process executed by the
+// reload, which carries the record of the reload for rememberExecuted.
+func (ar AutoReloader) executedEnv(info ReloadInfo) []string {
+	env := ar.execEnv(info.Paths...)
+	info.Name = ar.name()
+	data, err := json.Marshal(info.record())
+	if err != nil {
+		return env
+	}
+	return append(env, envReloadRecord+"="+string(data))
+}
+
+// rememberExecuted adds the reload that executed the current process to
+// the history, if the previous generation passed on its record.
+func (ar AutoReloader) rememberExecuted() {
+	var r reloadRecord
+	if err := json.Unmarshal([]byte(os.Getenv(envReloadRecord)), &r); err != nil || r.Name != ar.name() {
+		return
+	}
+	os.Unsetenv(envReloadRecord)
+	ar.remember(r.info())
+}
+
+// appendHistory adds the reload to the in-memory history, discarding the
+// oldest entries beyond the history size. The caller must hold the state
+// lock.
+func (ar AutoReloader) appendHistory(info ReloadInfo) {
+	if ar.historySize == 0 {
+		return
+	}
+	ar.state.history = append(ar.state.history, info)
+	if n := len(ar.state.history) - ar.historySize; n > 0 {
+		ar.state.history = append(ar.state.history[:0:0], ar.state.history[n:]...)
+	}
+}
+
+// loadHistory populates the in-memory history from the history file.
+func (ar AutoReloader) loadHistory() {
+	if ar.historyFile == "" {
+		return
+	}
+	infos, err := readHistoryFile(ar.historyFile)
+	if err != nil {
+		ar.log().Error("Failed to read history file", err)
+	}
+
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	for _, info := range infos {
+		ar.appendHistory(info)
+	}
+}
+
+// appendHistoryFile appends the reload to the history file, discarding
+// the oldest reloads beyond historyFileSize. The file is replaced rather
+// than written in place, so that a reader never sees it truncated.
+func appendHistoryFile(path string, info ReloadInfo) error {
+	data, err := json.Marshal(info.record())
+	if err != nil {
+		return err
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return err
+	}
+	lines = append(lines, data)
+	if n := len(lines) - historyFileSize; n > 0 {
+		lines = lines[n:]
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(bytes.Join(lines, []byte("\n")), '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// readLines returns the nonempty lines of the file, which may not exist.
+func readLines(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+func readHistoryFile(path string) ([]ReloadInfo, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var infos []ReloadInfo
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r reloadRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return infos, err
+		}
+		infos = append(infos, r.info())
+	}
+	return infos, scanner.Err()
+}
@@ -0,0 +1,133 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// envValue returns the value of the variable in the environment.
+func envValue(env []string, key string) (string, bool) {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return kv[len(key)+1:], true
+		}
+	}
+	return "", false
+}
+
+// TestExecutedRecordedByNewProcess checks that an executed reload is
+// only recorded once the exec succeeded, by the new process, with the
+// attempts it took.
+func TestExecutedRecordedByNewProcess(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	h := newTestReloader(t, WithHistoryFile(file))
+	h.failExecs(syscall.ENOENT)
+	h.Start()
+
+	h.write("v2")
+	if call := h.exec(); call.err == nil {
+		t.Fatal("first exec succeeded")
+	}
+	call := h.exec()
+	if history := h.History(); len(history) != 0 {
+		t.Errorf("the old process recorded %+v", history)
+	}
+
+	record, ok := envValue(call.envv, envReloadRecord)
+	if !ok {
+		t.Fatalf("no %s in the environment of the new process", envReloadRecord)
+	}
+	os.Setenv(envReloadRecord, record)
+	defer os.Unsetenv(envReloadRecord)
+	next := New(WithCommand(h.cmd), WithHistoryFile(file), WithLogger(nil))
+	history := next.History()
+	if len(history) != 1 {
+		t.Fatalf("the new process recorded %+v, want one reload", history)
+	}
+	if info := history[0]; info.Outcome != ReloadExecuted || info.Attempts != 2 || info.Path != h.cmd {
+		t.Errorf("recorded %+v, want an executed reload of %s after 2 attempts", info, h.cmd)
+	}
+	if _, ok := os.LookupEnv(envReloadRecord); ok {
+		t.Errorf("%s is still set", envReloadRecord)
+	}
+	if infos, err := readHistoryFile(file); err != nil || len(infos) != 1 {
+		t.Errorf("history file has %d reloads (%v), want 1", len(infos), err)
+	}
+}
+
+// TestFailedAttempts checks that a failed reload records the attempts
+// that were made.
+func TestFailedAttempts(t *testing.T) {
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+	}{
+		{"max attempts", []error{syscall.ENOENT, syscall.ETXTBSY, syscall.ENOENT}, 3},
+		{"fatal error", []error{syscall.ENOENT, syscall.E2BIG}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestReloader(t, WithMaxAttempts(3))
+			h.failExecs(tt.errs...)
+			h.Start()
+
+			h.write("v2")
+			if code := h.exit(); code != 1 {
+				t.Errorf("exit code = %d, want 1", code)
+			}
+			if n := len(h.execs); n != tt.attempts {
+				t.Errorf("%d execs, want %d", n, tt.attempts)
+			}
+			history := h.History()
+			if len(history) != 1 {
+				t.Fatalf("recorded %+v, want one reload", history)
+			}
+			if info := history[0]; info.Outcome != ReloadFailed || info.Attempts != tt.attempts {
+				t.Errorf("recorded %+v, want a failed reload after %d attempts", info, tt.attempts)
+			}
+		})
+	}
+}
+
+// TestHistoryFileSize checks that the history file keeps the most recent
+// reloads.
+func TestHistoryFileSize(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	ar := New(WithHistoryFile(file), WithLogger(nil))
+	total := historyFileSize + 50
+	for i := 0; i < total; i++ {
+		ar.remember(ReloadInfo{Path: strconv.Itoa(i), Outcome: ReloadAborted})
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != historyFileSize {
+		t.Errorf("history file has %d lines, want %d", n, historyFileSize)
+	}
+	infos, err := readHistoryFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first, last := infos[0].Path, infos[len(infos)-1].Path; first != strconv.Itoa(total-historyFileSize) || last != strconv.Itoa(total-1) {
+		t.Errorf("history file holds reloads %s to %s, want %d to %d", first, last, total-historyFileSize, total-1)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0644 {
+		t.Errorf("history file mode = %v, want %v", mode, os.FileMode(0644))
+	}
+}
@@ -0,0 +1,73 @@
+package autoreload
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// hook wraps a reload hook so that it can be identified for removal.
+type hook struct {
+	fn func()
+}
+
+// AddOnReload registers a hook that is executed just prior to reloading
+// the application. Hooks are executed in the order that they were
+// registered, after the callback defined by WithOnReload. A hook that
+// panics is logged and does not prevent the remaining hooks from
+// running, but the reload is then aborted. Hooks may be added before or
+// after the AutoReloader is started. The returned function removes the
+// hook.
+func (ar AutoReloader) AddOnReload(fn func()) (remove func()) {
+	if fn == nil {
+		return func() {}
+	}
+	h := &hook{fn: fn}
+
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	ar.state.hooks = append(ar.state.hooks, h)
+
+	return func() {
+		ar.state.mu.Lock()
+		defer ar.state.mu.Unlock()
+		for i, other := range ar.state.hooks {
+			if other == h {
+				ar.state.hooks = append(ar.state.hooks[:i:i], ar.state.hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// runHooks executes the WithOnReload callback followed by the registered
+// hooks. It reports whether all of them completed without panicking.
+func (ar AutoReloader) runHooks() bool {
+	ar.state.mu.Lock()
+	hooks := make([]func(), 0, len(ar.state.hooks)+1)
+	hooks = append(hooks, ar.onReload)
+	for _, h := range ar.state.hooks {
+		hooks = append(hooks, h.fn)
+	}
+	ar.state.mu.Unlock()
+
+	ok := true
+	for _, fn := range hooks {
+		if !ar.safely("Reload hook panicked", fn) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// safely executes fn, recovering from and logging any panic along with
+// its stack. It reports whether fn completed without panicking.
+func (ar AutoReloader) safely(msg string, fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ar.log().Error(msg, fmt.Errorf("%v\n%s", r, debug.Stack()))
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
@@ -0,0 +1,162 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// calls records the hooks that ran, in order.
+type calls struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (c *calls) hook(name string) func() {
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.names = append(c.names, name)
+	}
+}
+
+func (c *calls) list() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.names...)
+}
+
+// lastOutcome returns the outcome of the latest reload.
+func lastOutcome(t *testing.T, ar AutoReloader) ReloadOutcome {
+	t.Helper()
+	history := ar.History()
+	if len(history) == 0 {
+		t.Fatal("no reload recorded")
+	}
+	return history[len(history)-1].Outcome
+}
+
+// TestHookOrder checks that the WithOnReload callback runs first,
+// followed by the hooks in registration order, and that a removed hook
+// does not run.
+func TestHookOrder(t *testing.T) {
+	var c calls
+	h := newTestReloader(t, WithOnReload(c.hook("onReload")))
+	h.AddOnReload(c.hook("first"))
+	remove := h.AddOnReload(c.hook("removed"))
+	h.AddOnReload(c.hook("second"))
+	remove()
+	h.Start()
+
+	h.write("v2")
+	h.exec()
+	if got, want := c.list(), []string{"onReload", "first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hooks ran as %v, want %v", got, want)
+	}
+}
+
+// TestPanickingHookAbortsReload checks that a panicking hook does not
+// stop the later hooks, but aborts the reload, and that the next change
+// reloads once the hook is removed.
+func TestPanickingHookAbortsReload(t *testing.T) {
+	var c calls
+	h := newTestReloader(t)
+	remove := h.AddOnReload(func() { panic("boom") })
+	h.AddOnReload(c.hook("after"))
+	h.Start()
+
+	h.write("v2")
+	h.quiet()
+	if got := c.list(); !reflect.DeepEqual(got, []string{"after"}) {
+		t.Errorf("hooks ran as %v, want [after]", got)
+	}
+	if outcome := lastOutcome(t, h.AutoReloader); outcome != ReloadAborted {
+		t.Errorf("outcome = %v, want %v", outcome, ReloadAborted)
+	}
+
+	remove()
+	h.write("v3")
+	h.exec()
+}
+
+// blueGreen starts the reloader, configured for blue/green reloads,
+// whose candidate writes its pid to the returned file before it is
+// ready.
+func blueGreen(t *testing.T, h *testReloader) string {
+	t.Helper()
+	pidFile := filepath.Join(t.TempDir(), "candidate")
+	os.Setenv(envCandidate, pidFile)
+	t.Cleanup(func() { os.Unsetenv(envCandidate) })
+	h.Start()
+	return pidFile
+}
+
+// candidate returns the pid of the blue/green candidate, which is killed
+// at the end of the test.
+func candidate(t *testing.T, pidFile string) int {
+	t.Helper()
+	b, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("the candidate did not start: %v", err)
+	}
+	pid, err := strconv.Atoi(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Kill(pid, syscall.SIGKILL) })
+	return pid
+}
+
+// TestBlueGreenHooks checks that the hooks run once the blue/green
+// candidate is ready, after which the old process exits.
+func TestBlueGreenHooks(t *testing.T) {
+	var c calls
+	h := newTestReloader(t, WithBlueGreen(BlueGreenConfig{ReadySignal: syscall.SIGUSR1}), WithOnReload(c.hook("onReload")))
+	h.AddOnReload(c.hook("hook"))
+	pidFile := blueGreen(t, h)
+
+	h.write("v2")
+	if code := h.exit(); code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+	candidate(t, pidFile)
+	if got, want := c.list(), []string{"onReload", "hook"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hooks ran as %v, want %v", got, want)
+	}
+	if outcome := lastOutcome(t, h.AutoReloader); outcome != ReloadExited {
+		t.Errorf("outcome = %v, want %v", outcome, ReloadExited)
+	}
+}
+
+// TestBlueGreenPanickingHook checks that a panicking hook kills the
+// blue/green candidate and keeps the old process running.
+func TestBlueGreenPanickingHook(t *testing.T) {
+	h := newTestReloader(t, WithBlueGreen(BlueGreenConfig{ReadySignal: syscall.SIGUSR1}))
+	h.AddOnReload(func() { panic("boom") })
+	pidFile := blueGreen(t, h)
+
+	h.write("v2")
+	deadline := time.Now().Add(10 * time.Second)
+	for len(h.History()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the reload")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h.quiet()
+	if outcome := lastOutcome(t, h.AutoReloader); outcome != ReloadAborted {
+		t.Errorf("outcome = %v, want %v", outcome, ReloadAborted)
+	}
+	if pid := candidate(t, pidFile); syscall.Kill(pid, 0) != syscall.ESRCH {
+		t.Errorf("candidate %d still running", pid)
+	}
+}
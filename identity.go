@@ -0,0 +1,161 @@
+package autoreload
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileSnapshot records the identity and state of a watched path. It is
+// used to detect changes missed by the watcher, to skip events that did
+// not change the file and to notice when a path was replaced by a new
+// file.
+type fileSnapshot struct {
+	info os.FileInfo
+	sum  []byte
+}
+
+func snapshotFile(path string) fileSnapshot {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileSnapshot{}
+	}
+	return fileSnapshot{info: info}
+}
+
+func (s fileSnapshot) exists() bool {
+	return s.info != nil
+}
+
+// same reports whether both snapshots describe the same, unchanged file:
+// the same inode with the same size and modified time.
+func (s fileSnapshot) same(other fileSnapshot) bool {
+	if !s.exists() || !other.exists() {
+		return s.exists() == other.exists()
+	}
+	return os.SameFile(s.info, other.info) &&
+		s.info.Size() == other.info.Size() &&
+		s.info.ModTime().Equal(other.info.ModTime())
+}
+
+// withSum returns the snapshot with the checksum of the content of the
+// regular file at path, reusing that of the earlier snapshot if the file
+// is the same, unmodified file.
+func (s fileSnapshot) withSum(path string, earlier fileSnapshot) fileSnapshot {
+	if !s.exists() || !s.info.Mode().IsRegular() {
+		return s
+	}
+	if earlier.sum != nil && s.same(earlier) {
+		s.sum = earlier.sum
+		return s
+	}
+	s.sum = checksum(path)
+	return s
+}
+
+// unchanged reports whether the snapshot describes the same file as the
+// earlier one, with the same content: the same inode and size, and
+// either the same modified time or, as after a touch, the same
+// checksum.
+func (s fileSnapshot) unchanged(earlier fileSnapshot) bool {
+	if s.same(earlier) {
+		return true
+	}
+	if !s.exists() || !earlier.exists() {
+		return false
+	}
+	return os.SameFile(s.info, earlier.info) && s.info.Size() == earlier.info.Size() &&
+		s.sum != nil && bytes.Equal(s.sum, earlier.sum)
+}
+
+// checksum returns the SHA-256 of the content of the file, or nil if it
+// cannot be read.
+func checksum(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil
+	}
+	return h.Sum(nil)
+}
+
+// replaced reports whether the path now refers to a different file than
+// in the earlier snapshot, as happens when a file is replaced by a
+// rename.
+func (s fileSnapshot) replaced(earlier fileSnapshot) bool {
+	if !s.exists() {
+		return false
+	}
+	return !earlier.exists() || !os.SameFile(s.info, earlier.info)
+}
+
+// watchedPaths returns the executable paths followed by the added
+// paths. The caller must hold the state lock.
+func (ar AutoReloader) watchedPaths() []string {
+	paths := make([]string, 0, len(ar.state.paths)+len(ar.state.commandPaths)+1)
+	paths = append(paths, ar.state.watchPath)
+	paths = append(paths, ar.state.commandPaths...)
+	for path := range ar.state.paths {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// takeSnapshots records the state of every watched path.
+func (ar AutoReloader) takeSnapshots() {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	ar.snapshotPaths()
+}
+
+// snapshotPaths records the state of every watched path. A path that
+// now refers to a new file is watched again, since the watch on the
+// file it replaced no longer applies. The caller must hold the state
+// lock.
+func (ar AutoReloader) snapshotPaths() {
+	snapshots := map[string]fileSnapshot{}
+	for _, path := range ar.watchedPaths() {
+		earlier, ok := ar.state.snapshots[path]
+		snapshot := snapshotFile(path).withSum(path, earlier)
+		if ok && snapshot.replaced(earlier) && ar.state.watcher != nil {
+			ar.debug(fmt.Sprintf("Watching replaced file: %s", path))
+			ar.state.watcher.Remove(path)
+			if err := ar.state.watcher.Add(path); err != nil {
+				ar.log().Error(fmt.Sprintf("Failed to watch path: %s", path), err)
+			}
+		}
+		snapshots[path] = snapshot
+	}
+	ar.state.snapshots = snapshots
+}
+
+// changedPaths returns the watched paths that were replaced or whose
+// content changed since their last snapshot.
+func (ar AutoReloader) changedPaths() []string {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	var changed []string
+	for _, path := range ar.watchedPaths() {
+		earlier := ar.state.snapshots[path]
+		if !snapshotFile(path).withSum(path, earlier).unchanged(earlier) {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// identityUnchanged reports whether the path is a watched path that
+// still refers to the same file, with the same content, as when it was
+// last snapshotted, as is the case after a touch.
+func (ar AutoReloader) identityUnchanged(path string) bool {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	earlier, ok := ar.state.snapshots[path]
+	return ok && earlier.exists() && snapshotFile(path).withSum(path, earlier).unchanged(earlier)
+}
@@ -0,0 +1,59 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestTouchSkipsReload checks that a file that was touched, or rewritten
+// in place with the same content, does not reload.
+func TestTouchSkipsReload(t *testing.T) {
+	tests := []struct {
+		name  string
+		touch func(path string) error
+	}{
+		{"touch", func(path string) error {
+			later := time.Now().Add(time.Hour)
+			return os.Chtimes(path, later, later)
+		}},
+		{"rewrite", func(path string) error {
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			time.Sleep(10 * time.Millisecond)
+			return ioutil.WriteFile(path, content, 0755)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestReloader(t, WithVerbosity(VerbosityDebug))
+			h.Start()
+			if err := tt.touch(h.cmd); err != nil {
+				t.Fatal(err)
+			}
+			h.quiet()
+			if !h.logger.contains("Skipping reload: file identity unchanged") {
+				t.Error("the skipped reload was not logged")
+			}
+		})
+	}
+}
+
+// TestRewriteReloads checks that a file rewritten in place with new
+// content of the same size reloads.
+func TestRewriteReloads(t *testing.T) {
+	h := newTestReloader(t)
+	h.Start()
+	if err := ioutil.WriteFile(h.cmd, []byte("#!/bin/sh\n# v2\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+}
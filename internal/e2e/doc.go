@@ -0,0 +1,6 @@
+// Package e2e holds end-to-end tests that build real binaries, run them
+// under the autoreloader and replace them on disk. They only build with
+// the e2e tag:
+//
+//	go test -tags e2e ./internal/e2e
+package e2e
@@ -0,0 +1,31 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import "testing"
+
+// fixture is the package of the binary that the tests replace.
+const fixture = "./fixture"
+
+// TestReplace replaces a running fixture with each strategy in turn,
+// executing a copy of the new binary.
+func TestReplace(t *testing.T) {
+	for _, strategy := range Strategies {
+		strategy := strategy
+		t.Run(strategy.Name, func(t *testing.T) {
+			if err := Run(t.TempDir(), fixture, strategy, "copy"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestDirectExecTextBusy replaces a running fixture in place while
+// keeping the binary open for writing, so that executing it directly
+// fails with ETXTBSY until the writer is done.
+func TestDirectExecTextBusy(t *testing.T) {
+	if err := Run(t.TempDir(), fixture, HoldOpen, "direct"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,34 @@
+// Command fixture is a small program used by the end-to-end harness. It
+// writes its version and pid to the file named by E2E_VERSION_FILE and
+// then waits to be reloaded. E2E_EXEC selects how a changed binary is
+// executed: from a copy in E2E_EXEC_DIR, the default, or directly.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/agschwender/autoreload"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+func main() {
+	strategy := autoreload.CopyExec(os.Getenv("E2E_EXEC_DIR"))
+	if os.Getenv("E2E_EXEC") == "direct" {
+		strategy = autoreload.DirectExec()
+	}
+	autoreload.New(
+		autoreload.WithExecStrategy(strategy),
+		autoreload.WithDebounce(100*time.Millisecond),
+	).Start()
+
+	data := fmt.Sprintf("%s %d\n", version, os.Getpid())
+	if err := os.WriteFile(os.Getenv("E2E_VERSION_FILE"), []byte(data), 0644); err != nil {
+		log.Fatalf("Failed to write version file: %v", err)
+	}
+	select {}
+}
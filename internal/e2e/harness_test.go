@@ -0,0 +1,209 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Strategy replaces the file at dst with the file at src, mimicking how a
+// build tool writes a new binary.
+type Strategy struct {
+	Name    string
+	Replace func(src, dst string) error
+}
+
+// Strategies lists the ways in which the harness replaces binaries.
+var Strategies = []Strategy{
+	{Name: "mv", Replace: move},
+	{Name: "cp", Replace: copyOver},
+	{Name: "truncate-and-write", Replace: truncateAndWrite},
+	{Name: "delete-then-create", Replace: deleteThenCreate},
+}
+
+// HoldOpen rewrites the binary in place and keeps it open for writing
+// for a while, as a slow linker does. Executing it in the meantime fails
+// with ETXTBSY.
+var HoldOpen = Strategy{Name: "hold-open", Replace: holdOpen}
+
+// Build compiles the Go package at pkg into output, setting main.version
+// to the supplied version.
+func Build(pkg, output, version string) error {
+	cmd := osexec.Command("go", "build", "-o", output, "-ldflags", "-X main.version="+version, pkg)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Process is a fixture binary running under the autoreloader.
+type Process struct {
+	Bin         string
+	VersionFile string
+	cmd         *osexec.Cmd
+}
+
+// Start runs the binary at bin, which must have been built from the
+// fixture package, executing changed binaries with the exec strategy:
+// copy, as the fixture does by default, or direct. The process is
+// started from a copy of the binary so that bin can be overwritten in
+// place.
+func Start(dir, bin, exec string) (*Process, error) {
+	running := filepath.Join(dir, "running-"+filepath.Base(bin))
+	if err := copyFile(bin, running); err != nil {
+		return nil, err
+	}
+	p := &Process{Bin: bin, VersionFile: filepath.Join(dir, "version")}
+	p.cmd = &osexec.Cmd{
+		Path:   running,
+		Args:   []string{bin},
+		Env:    append(os.Environ(), "E2E_VERSION_FILE="+p.VersionFile, "E2E_EXEC_DIR="+dir, "E2E_EXEC="+exec),
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	return p, p.cmd.Start()
+}
+
+// WaitForVersion waits until the process reports the version, returning
+// the pid that reported it.
+func (p *Process) WaitForVersion(version string, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		data, err := os.ReadFile(p.VersionFile)
+		if err == nil {
+			fields := strings.Fields(string(data))
+			if len(fields) == 2 && fields[0] == version {
+				return strconv.Atoi(fields[1])
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return 0, fmt.Errorf("timed out waiting for version %s", version)
+}
+
+// Stop kills the process.
+func (p *Process) Stop() {
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
+
+// Run builds two versions of the fixture, starts the first and replaces
+// it with the second using the strategy, verifying that the process was
+// re-executed with the new version by the exec strategy.
+func Run(dir, fixture string, strategy Strategy, exec string) error {
+	bin := filepath.Join(dir, "fixture")
+	next := filepath.Join(dir, "fixture.next")
+	if err := Build(fixture, bin, "v1"); err != nil {
+		return err
+	}
+	if err := Build(fixture, next, "v2"); err != nil {
+		return err
+	}
+
+	p, err := Start(dir, bin, exec)
+	if err != nil {
+		return err
+	}
+	defer p.Stop()
+
+	pid, err := p.WaitForVersion("v1", 10*time.Second)
+	if err != nil {
+		return err
+	}
+	if err := strategy.Replace(next, bin); err != nil {
+		return err
+	}
+	newPid, err := p.WaitForVersion("v2", 10*time.Second)
+	if err != nil {
+		return err
+	}
+	if newPid != pid {
+		return fmt.Errorf("expected pid %d to be re-executed, got pid %d", pid, newPid)
+	}
+	return nil
+}
+
+func move(src, dst string) error {
+	tmp := dst + ".tmp"
+	if err := copyFile(src, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func copyOver(src, dst string) error {
+	return osexec.Command("cp", src, dst).Run()
+}
+
+func truncateAndWrite(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	// Write in two halves so that the reloader may observe a partially
+	// written binary.
+	half := len(data) / 2
+	if _, err := f.Write(data[:half]); err != nil {
+		f.Close()
+		return err
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := f.Write(data[half:]); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func holdOpen(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	time.Sleep(500 * time.Millisecond)
+	return f.Close()
+}
+
+func deleteThenCreate(src, dst string) error {
+	if err := os.Remove(dst); err != nil {
+		return err
+	}
+	time.Sleep(50 * time.Millisecond)
+	return copyFile(src, dst)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,95 @@
+package autoreload
+
+import "log"
+
+// Logger defines an interface for logging info and fatal errors out of
+// the autoreloader process.
+type Logger interface {
+	// Info is intended to log an informational message
+	Info(string)
+
+	// Error is intended to log an error message
+	Error(string, error)
+}
+
+type defaultLogger struct{}
+
+func (l *defaultLogger) Info(msg string) {
+	log.Println(msg)
+}
+
+func (l *defaultLogger) Error(msg string, err error) {
+	log.Printf("%s: %v\n", msg, err)
+}
+
+type noopLogger struct{}
+
+func (l *noopLogger) Info(msg string)             {} // nolint: unparam
+func (l *noopLogger) Error(msg string, err error) {} // nolint: unparam
+
+// Verbosity defines how much detail the AutoReloader logs.
+type Verbosity int
+
+const (
+	// VerbosityDefault logs changes, reloads and errors.
+	VerbosityDefault Verbosity = iota
+
+	// VerbosityDebug additionally logs every file event and every reload
+	// attempt.
+	VerbosityDebug
+)
+
+// WithVerbosity defines how much detail the AutoReloader logs. By
+// default, only changes, reloads and errors are logged.
+func WithVerbosity(verbosity Verbosity) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.verbosity = verbosity
+	}
+}
+
+// loggerValue wraps a Logger so that loggers of different concrete types
+// can be stored in the same atomic.Value.
+type loggerValue struct {
+	Logger
+}
+
+// SetLogger replaces the logger that the AutoReloader uses. It is safe
+// to call while the AutoReloader is running. As with WithLogger, a nil
+// logger disables logging.
+func (ar AutoReloader) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = &noopLogger{}
+	}
+	ar.state.logger.Store(loggerValue{logger})
+}
+
+// namedLogger prefixes every message with the name of the AutoReloader.
+type namedLogger struct {
+	Logger
+	prefix string
+}
+
+func (l namedLogger) Info(msg string) {
+	l.Logger.Info(l.prefix + msg)
+}
+
+func (l namedLogger) Error(msg string, err error) {
+	l.Logger.Error(l.prefix+msg, err)
+}
+
+// log returns the logger currently in use, prefixing messages with the
+// name of the AutoReloader if one was given with WithName.
+func (ar AutoReloader) log() Logger {
+	logger := ar.state.logger.Load().(loggerValue).Logger
+	if ar.label == "" {
+		return logger
+	}
+	return namedLogger{Logger: logger, prefix: "[" + ar.label + "] "}
+}
+
+// debug logs the message when debug verbosity is enabled.
+func (ar AutoReloader) debug(msg string) {
+	if ar.verbosity >= VerbosityDebug {
+		ar.log().Info(msg)
+	}
+}
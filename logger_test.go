@@ -0,0 +1,33 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLogPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		prefix string
+	}{
+		{"unnamed", nil, ""},
+		{"named", []Option{WithName("api")}, "[api] "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			ar := New(append(tt.opts, WithLogger(logger))...)
+			ar.log().Info("started")
+			ar.log().Error("failed", errors.New("boom"))
+
+			want := []string{tt.prefix + "started", tt.prefix + "failed: boom"}
+			if len(logger.msgs) != len(want) || logger.msgs[0] != want[0] || logger.msgs[1] != want[1] {
+				t.Errorf("logged %q, want %q", logger.msgs, want)
+			}
+		})
+	}
+}
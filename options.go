@@ -0,0 +1,36 @@
+package autoreload
+
+import "time"
+
+// Options composes several options into one. This allows a set of
+// options to be shared, for example across the services of a project.
+func Options(opts ...Option) Option {
+	return func(autoReloader *AutoReloader) {
+		for _, opt := range opts {
+			if opt != nil {
+				opt(autoReloader)
+			}
+		}
+	}
+}
+
+// Defaults returns the options that New uses when none are supplied. It
+// is useful for restoring the defaults after a composed set of options.
+func Defaults() Option {
+	return Options(
+		WithDebounce(defaultDebounce),
+		WithMaxAttempts(defaultMaxAttempts),
+		WithHistorySize(defaultHistorySize),
+		WithReplacementTimeout(defaultReplacementTimeout),
+	)
+}
+
+// Aggressive returns options that reload as quickly as possible: the
+// debounce is short and the AutoReloader keeps attempting to reload until
+// it succeeds.
+func Aggressive() Option {
+	return Options(
+		WithDebounce(50*time.Millisecond),
+		WithMaxAttempts(UnlimitedAttempts),
+	)
+}
@@ -0,0 +1,136 @@
+package autoreload
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// Action defines how the AutoReloader responds to a change of a watched
+// path.
+type Action int
+
+const (
+	// ActionReload reloads the application when the path changes.
+	ActionReload Action = iota
+
+	// ActionLog logs the change without reloading the application.
+	ActionLog
+
+	// ActionPlugin calls the callback registered with WithPluginPath
+	// without reloading the application.
+	ActionPlugin
+)
+
+// String returns the name of the action.
+func (a Action) String() string {
+	switch a {
+	case ActionReload:
+		return "reload"
+	case ActionLog:
+		return "log"
+	case ActionPlugin:
+		return "plugin"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the action as its name.
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+var (
+	// ErrPrimaryPath is returned when attempting to remove the path of
+	// the executable that the AutoReloader watches.
+	ErrPrimaryPath = errors.New("cannot remove the executable path")
+
+	// ErrNotWatched is returned when attempting to remove a path that is
+	// not being watched.
+	ErrNotWatched = errors.New("path is not watched")
+)
+
+// WithPath adds a file or directory to the set of watched paths, as
+// with AddPath. A path that cannot be resolved is reported when the
+// AutoReloader starts.
+func WithPath(path string, action Action) Option {
+	return func(autoReloader *AutoReloader) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		autoReloader.state.paths[path] = action
+	}
+}
+
+// AddPath adds a file or directory to the set of watched paths. When the
+// path, or a file directly within a watched directory, changes, the
+// supplied action is taken. Paths may be added before or after the
+// AutoReloader is started and take effect for subsequent events. Adding
+// a path that is already watched replaces its action. Relative paths are
+// resolved against the current working directory.
+func (ar AutoReloader) AddPath(path string, action Action) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	if _, ok := ar.state.paths[path]; !ok && ar.state.watcher != nil {
+		if err := ar.state.watcher.Add(path); err != nil {
+			return err
+		}
+	}
+	ar.state.paths[path] = action
+	return nil
+}
+
+// RemovePath removes a path previously added with AddPath. The path of
+// the executable itself cannot be removed.
+func (ar AutoReloader) RemovePath(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	if path == ar.primaryPath() {
+		return ErrPrimaryPath
+	}
+	if _, ok := ar.state.paths[path]; !ok {
+		return ErrNotWatched
+	}
+	if ar.state.watcher != nil {
+		if err := ar.state.watcher.Remove(path); err != nil {
+			return err
+		}
+	}
+	delete(ar.state.paths, path)
+	return nil
+}
+
+// primaryPath returns the path of the watched executable. The caller
+// must hold the state lock.
+func (ar AutoReloader) primaryPath() string {
+	if ar.state.watchPath != "" {
+		return ar.state.watchPath
+	}
+	path, _ := resolvePath(ar.command())
+	return path
+}
+
+// actionFor returns the action to take for a change of the supplied
+// path. Changes to unknown paths, such as the executable itself, reload
+// the application.
+func (ar AutoReloader) actionFor(path string) Action {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	if action, ok := ar.state.paths[path]; ok {
+		return action
+	}
+	if action, ok := ar.state.paths[filepath.Dir(path)]; ok {
+		return action
+	}
+	return ActionReload
+}
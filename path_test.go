@@ -0,0 +1,90 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// watchedAction returns the action of the path in the status, if it is
+// watched.
+func watchedAction(ar AutoReloader, path string) (Action, bool) {
+	for _, p := range ar.Status().Paths {
+		if p.Path == path {
+			return p.Action, true
+		}
+	}
+	return 0, false
+}
+
+// touchIn writes a file in the directory.
+func touchIn(t *testing.T, dir string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, "asset"), []byte(t.Name()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAddPathWhileRunning checks that a path added to a running
+// AutoReloader is watched, and that adding it again replaces its action.
+func TestAddPathWhileRunning(t *testing.T) {
+	h := newTestReloader(t)
+	h.Start()
+	dir := t.TempDir()
+
+	if err := h.AddPath(dir, ActionLog); err != nil {
+		t.Fatal(err)
+	}
+	if action, ok := watchedAction(h.AutoReloader, dir); !ok || action != ActionLog {
+		t.Fatalf("status has %s: %v with action %s, want log", dir, ok, action)
+	}
+	touchIn(t, dir)
+	h.quiet()
+	if !h.logger.contains("Path changed: " + filepath.Join(dir, "asset")) {
+		t.Error("the change was not logged")
+	}
+
+	if err := h.AddPath(dir, ActionReload); err != nil {
+		t.Fatal(err)
+	}
+	if action, _ := watchedAction(h.AutoReloader, dir); action != ActionReload {
+		t.Errorf("status has action %s after adding again, want reload", action)
+	}
+	touchIn(t, dir)
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+}
+
+// TestRemovePathWhileRunning checks that a path removed from a running
+// AutoReloader is no longer watched, and that the executable cannot be
+// removed.
+func TestRemovePathWhileRunning(t *testing.T) {
+	dir := t.TempDir()
+	h := newTestReloader(t, WithPath(dir, ActionReload))
+	h.Start()
+
+	if err := h.RemovePath(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := watchedAction(h.AutoReloader, dir); ok {
+		t.Errorf("status has %s after removing it", dir)
+	}
+	touchIn(t, dir)
+	h.quiet()
+
+	if err := h.RemovePath(dir); !errors.Is(err, ErrNotWatched) {
+		t.Errorf("removing again: %v, want ErrNotWatched", err)
+	}
+	if err := h.RemovePath(h.cmd); !errors.Is(err, ErrPrimaryPath) {
+		t.Errorf("removing the executable: %v, want ErrPrimaryPath", err)
+	}
+	h.write("v2")
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+}
@@ -0,0 +1,18 @@
+package autoreload
+
+import "errors"
+
+// ErrUnsupportedPlatform is logged by Start on platforms where the
+// executable cannot be watched, such as js/wasm and plan9, and on
+// Windows unless WithExitInsteadOfExec or WithBlueGreen is used, as it
+// cannot re-execute the process. The package still builds there, so
+// that it may be imported by programs that also target them.
+var ErrUnsupportedPlatform = errors.New("autoreload is not supported on this platform")
+
+// canReload reports whether the new executable can be started on this
+// platform: by replacing the process, where exec is supported, or else
+// beside it with WithBlueGreen or by a supervisor with
+// WithExitInsteadOfExec.
+func (ar AutoReloader) canReload() bool {
+	return execSupported || ar.exitCode != nil || ar.blueGreen != nil
+}
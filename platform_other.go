@@ -0,0 +1,61 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !solaris && !windows
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!solaris,!windows
+
+package autoreload
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const platformSupported = false
+
+// fileOp is the kind of change of a fileEvent.
+type fileOp uint32
+
+const (
+	opCreate fileOp = 1 << iota
+	opWrite
+	opRemove
+)
+
+// fileEvent is a change to a watched path.
+type fileEvent struct {
+	Name string
+	Op   fileOp
+}
+
+func (e fileEvent) String() string {
+	return fmt.Sprintf("%q: %d", e.Name, e.Op)
+}
+
+// fsWatcher stands in for the file system watcher, which is not
+// available on this platform.
+type fsWatcher struct {
+	Events chan fileEvent
+	Errors chan error
+}
+
+// errEventOverflow reports that events were dropped.
+var errEventOverflow = errors.New("event queue overflow")
+
+func newFSWatcher() (*fsWatcher, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func (w *fsWatcher) Add(path string) error    { return ErrUnsupportedPlatform }
+func (w *fsWatcher) Remove(path string) error { return ErrUnsupportedPlatform }
+func (w *fsWatcher) Close() error             { return nil }
+
+// signalNumber returns the number of the signal. Signals cannot be
+// relayed between processes on this platform.
+func signalNumber(sig os.Signal) (int, bool) {
+	return 0, false
+}
+
+// writable reports whether the directory can be written to. It is not
+// checked on this platform.
+func writable(dir string) bool {
+	return true
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris || windows
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris windows
+
+package autoreload
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const platformSupported = true
+
+// fileEvent is a change to a watched path.
+type fileEvent = fsnotify.Event
+
+// fsWatcher is the file system watcher shared by a WatcherPool.
+type fsWatcher = fsnotify.Watcher
+
+const (
+	opCreate = fsnotify.Create
+	opWrite  = fsnotify.Write
+	opRemove = fsnotify.Remove
+)
+
+// errEventOverflow reports that events were dropped.
+var errEventOverflow = fsnotify.ErrEventOverflow
+
+func newFSWatcher() (*fsWatcher, error) {
+	return fsnotify.NewWatcher()
+}
+
+// signalNumber returns the number of the signal, if it is a
+// syscall.Signal.
+func signalNumber(sig os.Signal) (int, bool) {
+	s, ok := sig.(syscall.Signal)
+	return int(s), ok
+}
@@ -0,0 +1,103 @@
+package autoreload
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// WithPluginPath watches a Go plugin, or other shared library, and calls
+// onRebuilt instead of reloading the application when it changes. The
+// callback is only called once the file has stopped changing and looks
+// like a complete binary, since loading a partially written plugin can
+// crash the process. Replacing the file by renaming over it, as go build
+// does, is handled.
+//
+// Since the dynamic loader caches libraries by path, the callback should
+// typically copy the plugin to a versioned file name before opening it:
+//
+//	autoreload.WithPluginPath("handlers.so", func(path string) {
+//		versioned := fmt.Sprintf("/tmp/handlers-%d.so", time.Now().UnixNano())
+//		if err := copyFile(path, versioned); err != nil {
+//			return
+//		}
+//		p, err := plugin.Open(versioned)
+//		// Look up symbols in p and swap them in.
+//	})
+func WithPluginPath(path string, onRebuilt func(path string)) Option {
+	return func(autoReloader *AutoReloader) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		autoReloader.state.paths[path] = ActionPlugin
+		autoReloader.state.plugins[path] = onRebuilt
+	}
+}
+
+// rebuildPlugin waits for the plugin at path to stop changing and then
+// calls its callback. The changes of other paths made in the meantime
+// are handled afterwards.
+func (ar AutoReloader) rebuildPlugin(path string, watcher watcher, execPath string) {
+	ar.state.mu.Lock()
+	onRebuilt := ar.state.plugins[path]
+	ar.state.mu.Unlock()
+	if onRebuilt == nil {
+		return
+	}
+
+	pending, err := ar.waitForStableFile(path, watcher.Events())
+	if err != nil {
+		ar.log().Error(fmt.Sprintf("Plugin not loaded: %s", path), err)
+	} else {
+		ar.log().Info(fmt.Sprintf("Plugin rebuilt: %s", path))
+		ar.safely("Plugin callback panicked", func() {
+			onRebuilt(path)
+		})
+	}
+	for _, event := range pending {
+		ar.handle(event, watcher, execPath)
+	}
+}
+
+// waitForStableFile waits until the file at path exists, is unchanged
+// across a debounce window and begins with the magic of a native binary.
+// It gives up after the replacement timeout. It returns the first event
+// received for each other path in the meantime.
+func (ar AutoReloader) waitForStableFile(path string, events <-chan fileEvent) ([]fileEvent, error) {
+	deadline := time.Now().Add(ar.replacementTimeout)
+	var pending []fileEvent
+	seen := map[string]bool{path: true}
+	for {
+		before := snapshotFile(path)
+		timer := time.NewTimer(ar.debounce)
+	wait:
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					// The watch loop replaces the closed watcher.
+					events = nil
+					continue
+				}
+				if !seen[event.Name] {
+					seen[event.Name] = true
+					pending = append(pending, event)
+				}
+			case <-timer.C:
+				break wait
+			}
+		}
+		after := snapshotFile(path)
+
+		err := fmt.Errorf("%w: %s is still changing", ErrInvalidExecutable, path)
+		if after.exists() && after.same(before) {
+			if err = verifyBinary(path); err == nil {
+				return pending, nil
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return pending, err
+		}
+		ar.debug(fmt.Sprintf("Waiting for plugin to stabilize: %v", err))
+	}
+}
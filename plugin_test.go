@@ -0,0 +1,44 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestPluginKeepsOtherChanges checks that a change made while a plugin
+// stabilizes is handled once the plugin is loaded, rather than lost.
+func TestPluginKeepsOtherChanges(t *testing.T) {
+	plugin := filepath.Join(t.TempDir(), "handlers.so")
+	if err := ioutil.WriteFile(plugin, []byte(nativeMagic()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt := make(chan string, 1)
+	h := newTestReloader(t, WithPluginPath(plugin, func(path string) { rebuilt <- path }))
+	h.Start()
+
+	// The partial plugin is not loaded until it is complete.
+	if err := ioutil.WriteFile(plugin, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	h.write("v2")
+	time.Sleep(100 * time.Millisecond)
+	if err := ioutil.WriteFile(plugin, []byte(nativeMagic()+"complete"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case path := <-rebuilt:
+		if path != plugin {
+			t.Errorf("rebuilt %s, want %s", path, plugin)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the plugin to be rebuilt")
+	}
+	h.exec()
+}
@@ -0,0 +1,154 @@
+package autoreload
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WithPollInterval causes the AutoReloader to detect changes by
+// periodically checking the watched paths instead of relying on file
+// system notifications. This is useful on file systems that do not
+// deliver notifications, such as some container volume mounts and
+// network file systems. A directory is checked for changes to the files
+// directly within it. By default, notifications are used.
+func WithPollInterval(interval time.Duration) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.pollInterval = interval
+	}
+}
+
+// pollWatcher implements watcher by periodically comparing snapshots of
+// the watched paths.
+type pollWatcher struct {
+	mu       sync.Mutex
+	paths    map[string]map[string]fileSnapshot
+	events   chan fileEvent
+	errors   chan error
+	done     chan struct{}
+	once     sync.Once
+	interval time.Duration
+}
+
+func newPollWatcher(interval time.Duration) *pollWatcher {
+	w := &pollWatcher{
+		paths:    map[string]map[string]fileSnapshot{},
+		events:   make(chan fileEvent, subscriberBuffer),
+		errors:   make(chan error, 1),
+		done:     make(chan struct{}),
+		interval: interval,
+	}
+	go w.run()
+	return w
+}
+
+func (w *pollWatcher) Add(path string) error {
+	path = filepath.Clean(path)
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.paths[path]; !ok {
+		w.paths[path] = pollSnapshot(path)
+	}
+	return nil
+}
+
+func (w *pollWatcher) Remove(path string) error {
+	path = filepath.Clean(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.paths[path]; !ok {
+		return errors.New("can't remove non-existent watch: " + path)
+	}
+	delete(w.paths, path)
+	return nil
+}
+
+func (w *pollWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	return nil
+}
+
+func (w *pollWatcher) Events() <-chan fileEvent { return w.events }
+func (w *pollWatcher) Errors() <-chan error     { return w.errors }
+
+func (w *pollWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, event := range w.poll() {
+				select {
+				case w.events <- event:
+				case <-w.done:
+					return
+				}
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// poll compares every watched path with its previous snapshot and
+// returns an event for each change.
+func (w *pollWatcher) poll() []fileEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var events []fileEvent
+	for path, earlier := range w.paths {
+		current := pollSnapshot(path)
+		events = append(events, diffSnapshots(earlier, current)...)
+		w.paths[path] = current
+	}
+	return events
+}
+
+// pollSnapshot records the state of the path and, if it is a directory,
+// of the files directly within it, keyed by path.
+func pollSnapshot(path string) map[string]fileSnapshot {
+	snapshots := map[string]fileSnapshot{path: snapshotFile(path)}
+	if info := snapshots[path].info; info == nil || !info.IsDir() {
+		return snapshots
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return snapshots
+	}
+	for _, entry := range entries {
+		name := filepath.Join(path, entry.Name())
+		snapshots[name] = snapshotFile(name)
+	}
+	return snapshots
+}
+
+func diffSnapshots(earlier, current map[string]fileSnapshot) []fileEvent {
+	var events []fileEvent
+	for name, snapshot := range current {
+		before, ok := earlier[name]
+		switch {
+		case !snapshot.exists() && (!ok || !before.exists()):
+		case !snapshot.exists():
+			events = append(events, fileEvent{Name: name, Op: opRemove})
+		case !ok || !before.exists():
+			events = append(events, fileEvent{Name: name, Op: opCreate})
+		case !snapshot.same(before):
+			events = append(events, fileEvent{Name: name, Op: opWrite})
+		}
+	}
+	for name, before := range earlier {
+		if _, ok := current[name]; !ok && before.exists() {
+			events = append(events, fileEvent{Name: name, Op: opRemove})
+		}
+	}
+	return events
+}
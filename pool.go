@@ -0,0 +1,261 @@
+package autoreload
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// subscriberBuffer is the number of events buffered for each subscriber
+// of a WatcherPool. Events beyond it are dropped and reported to the
+// subscriber as an overflow, so that a subscriber busy reloading cannot
+// stall the others.
+const subscriberBuffer = 64
+
+// defaultPool is the WatcherPool used by AutoReloaders that are not
+// given one, so that a process only uses a single fsnotify watcher.
+var defaultPool = NewWatcherPool()
+
+// WatcherPool multiplexes a single fsnotify watcher across several
+// AutoReloader instances. Each AutoReloader only receives events for the
+// paths it watches. The underlying watcher is created when the first
+// AutoReloader using the pool starts and closed when the last one stops.
+// By default, all AutoReloaders in a process share a single pool.
+type WatcherPool struct {
+	mu      sync.Mutex
+	watcher *fsWatcher
+	paths   map[string]int
+	subs    map[*poolWatcher]struct{}
+}
+
+// NewWatcherPool creates an empty WatcherPool.
+func NewWatcherPool() *WatcherPool {
+	return &WatcherPool{
+		paths: map[string]int{},
+		subs:  map[*poolWatcher]struct{}{},
+	}
+}
+
+// WithWatcherPool defines a WatcherPool that the AutoReloader should use
+// instead of the pool shared by the process.
+func WithWatcherPool(pool *WatcherPool) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.pool = pool
+	}
+}
+
+// subscribe returns a watcher that receives the events of the pool for
+// its own paths, creating the underlying watcher if necessary.
+func (p *WatcherPool) subscribe() (watcher, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.watcher == nil {
+		w, err := newFSWatcher()
+		if err != nil {
+			return nil, err
+		}
+		p.watcher = w
+		go p.dispatch(w)
+	}
+	sub := &poolWatcher{
+		pool:   p,
+		paths:  map[string]struct{}{},
+		events: make(chan fileEvent, subscriberBuffer),
+		errors: make(chan error, 1),
+		done:   make(chan struct{}),
+	}
+	p.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// dispatch forwards the events of the supplied watcher to the
+// subscribers watching the affected path. Errors are forwarded to all
+// subscribers.
+func (p *WatcherPool) dispatch(w *fsWatcher) {
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				p.detach(w)
+				return
+			}
+			for _, sub := range p.subscribers() {
+				if sub.matches(event.Name) {
+					sub.sendEvent(event)
+				}
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				p.detach(w)
+				return
+			}
+			for _, sub := range p.subscribers() {
+				sub.sendError(err)
+			}
+		}
+	}
+}
+
+// detach handles the unexpected closure of the underlying watcher by
+// closing the event channels of all subscribers, which then subscribe
+// again to recreate it.
+func (p *WatcherPool) detach(w *fsWatcher) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.watcher != w {
+		return
+	}
+	p.watcher = nil
+	p.paths = map[string]int{}
+	for sub := range p.subs {
+		sub.mu.Lock()
+		sub.paths = map[string]struct{}{}
+		sub.detached = true
+		close(sub.events)
+		sub.mu.Unlock()
+		delete(p.subs, sub)
+	}
+}
+
+func (p *WatcherPool) subscribers() []*poolWatcher {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subs := make([]*poolWatcher, 0, len(p.subs))
+	for sub := range p.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// poolWatcher is a subscription to a WatcherPool.
+type poolWatcher struct {
+	pool   *WatcherPool
+	mu     sync.Mutex
+	paths  map[string]struct{}
+	events chan fileEvent
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+
+	// detached is set when the underlying watcher was closed
+	// unexpectedly.
+	detached bool
+}
+
+func (w *poolWatcher) Add(path string) error {
+	path = filepath.Clean(path)
+
+	w.pool.mu.Lock()
+	defer w.pool.mu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.detached {
+		return errors.New("watcher closed")
+	}
+	if _, ok := w.paths[path]; ok {
+		return nil
+	}
+	if w.pool.paths[path] == 0 {
+		if err := w.pool.watcher.Add(path); err != nil {
+			return err
+		}
+	}
+	w.pool.paths[path]++
+	w.paths[path] = struct{}{}
+	return nil
+}
+
+func (w *poolWatcher) Remove(path string) error {
+	path = filepath.Clean(path)
+
+	w.pool.mu.Lock()
+	defer w.pool.mu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.paths[path]; !ok {
+		return errors.New("can't remove non-existent watch: " + path)
+	}
+	return w.pool.release(w, path)
+}
+
+// release drops the reference of the subscriber to the path, removing it
+// from the underlying watcher if no other subscriber watches it. The
+// caller must hold the pool and subscriber locks.
+func (p *WatcherPool) release(w *poolWatcher, path string) error {
+	delete(w.paths, path)
+	p.paths[path]--
+	if p.paths[path] > 0 {
+		return nil
+	}
+	delete(p.paths, path)
+	return p.watcher.Remove(path)
+}
+
+// Close removes the subscription from the pool, closing the underlying
+// watcher if it was the last one.
+func (w *poolWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+
+		w.pool.mu.Lock()
+		defer w.pool.mu.Unlock()
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for path := range w.paths {
+			if rerr := w.pool.release(w, path); rerr != nil && err == nil {
+				err = rerr
+			}
+		}
+		delete(w.pool.subs, w)
+		if len(w.pool.subs) == 0 && w.pool.watcher != nil {
+			if cerr := w.pool.watcher.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+			w.pool.watcher = nil
+		}
+	})
+	return err
+}
+
+func (w *poolWatcher) Events() <-chan fileEvent { return w.events }
+func (w *poolWatcher) Errors() <-chan error     { return w.errors }
+
+// matches reports whether the path is, or is directly within, one of the
+// paths watched by the subscriber.
+func (w *poolWatcher) matches(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for watched := range w.paths {
+		if path == watched || strings.HasPrefix(path, watched+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendEvent delivers the event without blocking. If the subscriber's
+// buffer is full, the event is dropped and an overflow is reported
+// instead, prompting the subscriber to check its paths for changes.
+func (w *poolWatcher) sendEvent(event fileEvent) {
+	select {
+	case w.events <- event:
+	default:
+		select {
+		case w.errors <- errEventOverflow:
+		default:
+		}
+	}
+}
+
+// sendError delivers the error without blocking, so that a subscriber
+// busy reloading cannot stall the others. If an error is already
+// pending, the subscriber is about to check its paths anyway, and the
+// error is dropped.
+func (w *poolWatcher) sendError(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
@@ -0,0 +1,78 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// siblingReloaders creates two AutoReloaders sharing a WatcherPool,
+// whose commands are in the same directory.
+func siblingReloaders(t *testing.T) (pool *WatcherPool, a, b *testReloader) {
+	pool = NewWatcherPool()
+	a = newTestReloader(t, WithWatcherPool(pool))
+	sibling := filepath.Join(filepath.Dir(a.cmd), "sibling")
+	b = newTestReloader(t, WithWatcherPool(pool), WithCommand(sibling))
+	b.cmd = sibling
+	b.write("v1")
+	return pool, a, b
+}
+
+// TestPoolReloadsOnlyOwner checks that of two AutoReloaders sharing a
+// pool, a change of the command of one only reloads that one.
+func TestPoolReloadsOnlyOwner(t *testing.T) {
+	_, a, b := siblingReloaders(t)
+	a.Start()
+	b.Start()
+
+	b.write("v2")
+	if call := b.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+	a.quiet()
+
+	a.write("v2")
+	if call := a.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+	b.quiet()
+}
+
+// TestPoolClosesWatcherWithLastReloader checks that the fsnotify watcher
+// of a pool stays open while any AutoReloader uses it and is closed once
+// the last one stops.
+func TestPoolClosesWatcherWithLastReloader(t *testing.T) {
+	pool, a, b := siblingReloaders(t)
+	a.Start()
+	b.Start()
+	pool.mu.Lock()
+	fsw := pool.watcher
+	pool.mu.Unlock()
+	if fsw == nil {
+		t.Fatal("no fsnotify watcher while running")
+	}
+
+	a.Stop()
+	pool.mu.Lock()
+	open, paths := pool.watcher == fsw, len(pool.paths)
+	pool.mu.Unlock()
+	if !open {
+		t.Fatal("the fsnotify watcher was closed while an AutoReloader uses it")
+	}
+	if paths != 1 {
+		t.Errorf("pool watches %d paths after one AutoReloader stopped, want 1", paths)
+	}
+
+	b.Stop()
+	pool.mu.Lock()
+	open, paths = pool.watcher != nil, len(pool.paths)
+	pool.mu.Unlock()
+	if open || paths != 0 {
+		t.Errorf("pool has a watcher: %v, and watches %d paths after the last AutoReloader stopped", open, paths)
+	}
+	if err := fsw.Add(filepath.Dir(a.cmd)); err == nil {
+		t.Error("the fsnotify watcher is still open")
+	}
+}
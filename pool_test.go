@@ -0,0 +1,84 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris || windows
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris windows
+
+package autoreload
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestPoolErrorsDoNotStallSiblings checks that a subscriber that does
+// not read its errors, as when its AutoReloader is busy reloading, does
+// not stop the pool from delivering events to a subscriber watching a
+// sibling path.
+func TestPoolErrorsDoNotStallSiblings(t *testing.T) {
+	dir := t.TempDir()
+	busyDir, idleDir := filepath.Join(dir, "busy"), filepath.Join(dir, "idle")
+	for _, d := range []string{busyDir, idleDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool := NewWatcherPool()
+	busy, err := pool.subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	idle, err := pool.subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	if err := busy.Add(busyDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := idle.Add(idleDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// More errors than either subscriber buffers, none of which the busy
+	// subscriber reads.
+	for i := 0; i < 3; i++ {
+		select {
+		case pool.watcher.Errors <- errors.New("queue overflow"):
+		case <-time.After(5 * time.Second):
+			t.Fatal("the pool stopped dispatching errors")
+		}
+	}
+	drain(idle.Errors())
+
+	path := filepath.Join(idleDir, "file")
+	if err := ioutil.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-idle.Events():
+			if event.Name == path {
+				return
+			}
+		case <-idle.Errors():
+		case <-deadline:
+			t.Fatal("no event for the sibling path while the other subscriber was busy")
+		}
+	}
+}
+
+// drain discards the errors currently buffered on the channel.
+func drain(errs <-chan error) {
+	for {
+		select {
+		case <-errs:
+		default:
+			return
+		}
+	}
+}
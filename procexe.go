@@ -0,0 +1,62 @@
+package autoreload
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultReplacementTimeout = 10 * time.Second
+
+// WithReplacementTimeout defines how long the AutoReloader waits for a
+// changed executable to become valid, or for a deleted executable to be
+// replaced, before giving up on the reload. By default, this is 10
+// seconds.
+func WithReplacementTimeout(timeout time.Duration) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.replacementTimeout = timeout
+	}
+}
+
+// WithProcSelfExeFallback defines whether the AutoReloader re-executes
+// the currently running binary through /proc/self/exe when the
+// executable is deleted and not replaced within the replacement timeout.
+// This restarts the old version of the application, which is useful when
+// the reload callback has already shut the application down. It is only
+// supported on Linux: elsewhere, New ignores it and NewE reports it as a
+// problem.
+func WithProcSelfExeFallback(enabled bool) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.procSelfExeFallback = enabled
+	}
+}
+
+// waitForExecutable waits for the executable at path to exist, swallowing
+// events in the interim. It reports whether the executable exists before
+// the replacement timeout expires.
+func (ar AutoReloader) waitForExecutable(path string, events <-chan fileEvent) bool {
+	deadline := time.Now().Add(ar.replacementTimeout)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		ar.debug(fmt.Sprintf("Waiting for executable to be replaced: %s", path))
+		ar.sleep(100*time.Millisecond, events)
+	}
+}
+
+// execSelf re-executes the currently running binary if the fallback is
+// enabled and supported. It only returns if it was unable to do so.
+func (ar AutoReloader) execSelf(path string) {
+	self, ok := procSelfExe()
+	if !ar.procSelfExeFallback || !ok {
+		return
+	}
+	ar.log().Info(fmt.Sprintf("Executable %s is missing; restarting the old binary via %s", path, self))
+	if err := ar.tryExec(self, execArgs(path), ar.execEnv(path)); err != nil {
+		ar.log().Error(fmt.Sprintf("syscall.Exec: %s", self), err)
+	}
+}
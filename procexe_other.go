@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package autoreload
+
+func procSelfExe() (string, bool) {
+	return "", false
+}
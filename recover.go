@@ -0,0 +1,71 @@
+package autoreload
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxWatcherRebuilds is the number of times the AutoReloader attempts to
+// replace a watcher that was closed unexpectedly before giving up.
+const maxWatcherRebuilds = 5
+
+// handleError responds to an error reported by the watcher. When the
+// event queue overflowed, the watched paths are compared against their
+// snapshots so that missed changes still trigger their action. Other
+// errors are fatal.
+func (ar AutoReloader) handleError(err error, watcher watcher, execPath string) {
+	if !errors.Is(err, errEventOverflow) {
+		ar.must(err, "Error watching file")
+		return
+	}
+	ar.log().Error("File events were lost; checking watched paths for changes", err)
+	for _, path := range ar.changedPaths() {
+		ar.handle(fileEvent{Name: path, Op: opWrite}, watcher, execPath)
+	}
+}
+
+// replaceWatcher creates a new watcher for all watched paths after the
+// previous one was closed unexpectedly. It makes a bounded number of
+// attempts before exiting through the fatal path. It returns nil if the
+// AutoReloader was stopped in the meantime.
+func (ar AutoReloader) replaceWatcher(done <-chan struct{}) watcher {
+	var err error
+	for i := 0; i < maxWatcherRebuilds; i++ {
+		if i > 0 {
+			time.Sleep(ar.debounce)
+		}
+		ar.log().Info(fmt.Sprintf("File watcher closed; recreating it (attempt %d of %d)", i+1, maxWatcherRebuilds))
+
+		var w watcher
+		if w, err = ar.rebuildWatcher(done); err == nil {
+			return w
+		}
+	}
+	ar.fatal("Failed to recreate file watcher", err)
+	return nil
+}
+
+func (ar AutoReloader) rebuildWatcher(done <-chan struct{}) (watcher, error) {
+	w, err := ar.newWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+	for _, path := range ar.watchedPaths() {
+		if err := w.Add(path); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	select {
+	case <-done:
+		w.Close()
+		return nil, nil
+	default:
+	}
+	ar.state.watcher = w
+	return w, nil
+}
@@ -0,0 +1,170 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWatcher is a watcher whose events, errors and closure are
+// simulated by the test.
+type fakeWatcher struct {
+	events chan fileEvent
+	errors chan error
+
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+func (w *fakeWatcher) Add(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paths[path] = true
+	return nil
+}
+
+func (w *fakeWatcher) Remove(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.paths, path)
+	return nil
+}
+
+func (w *fakeWatcher) Close() error             { return nil }
+func (w *fakeWatcher) Events() <-chan fileEvent { return w.events }
+func (w *fakeWatcher) Errors() <-chan error     { return w.errors }
+
+// watching reports whether the path was added to the watcher.
+func (w *fakeWatcher) watching(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.paths[path]
+}
+
+// fakeWatchers creates the fake watchers of an AutoReloader.
+type fakeWatchers struct {
+	t       *testing.T
+	created chan *fakeWatcher
+
+	mu  sync.Mutex
+	err error
+}
+
+func newFakeWatchers(t *testing.T) *fakeWatchers {
+	return &fakeWatchers{t: t, created: make(chan *fakeWatcher, 16)}
+}
+
+// option makes the AutoReloader use the fake watchers.
+func (f *fakeWatchers) option(ar *AutoReloader) {
+	ar.openWatcher = f.open
+}
+
+func (f *fakeWatchers) open() (watcher, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	w := &fakeWatcher{
+		events: make(chan fileEvent, 16),
+		errors: make(chan error, 1),
+		paths:  map[string]bool{},
+	}
+	f.created <- w
+	return w, nil
+}
+
+// fail makes creating further watchers fail with the error.
+func (f *fakeWatchers) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// next waits for the next watcher to be created and for the path to be
+// added to it.
+func (f *fakeWatchers) next(path string) *fakeWatcher {
+	f.t.Helper()
+	var w *fakeWatcher
+	select {
+	case w = <-f.created:
+	case <-time.After(10 * time.Second):
+		f.t.Fatal("timed out waiting for a watcher to be created")
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for !w.watching(path) {
+		if time.Now().After(deadline) {
+			f.t.Fatalf("%s was not added to the watcher", path)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return w
+}
+
+// TestOverflowChecksWatchedPaths checks that after an overflow, the
+// watched paths are compared with their snapshots, and that a change
+// missed in the meantime reloads.
+func TestOverflowChecksWatchedPaths(t *testing.T) {
+	watchers := newFakeWatchers(t)
+	h := newTestReloader(t, watchers.option)
+	h.Start()
+	w := watchers.next(h.cmd)
+
+	w.errors <- errEventOverflow
+	h.quiet()
+	if !h.logger.contains("File events were lost") {
+		t.Error("the overflow was not logged")
+	}
+
+	h.write("v2")
+	w.errors <- errEventOverflow
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+}
+
+// TestClosedWatcherRebuilt checks that a watcher that was closed is
+// replaced by one watching the same paths.
+func TestClosedWatcherRebuilt(t *testing.T) {
+	watchers := newFakeWatchers(t)
+	h := newTestReloader(t, watchers.option)
+	h.Start()
+	w := watchers.next(h.cmd)
+
+	close(w.events)
+	w = watchers.next(h.cmd)
+	if !h.logger.contains("File watcher closed; recreating it (attempt 1 of 5)") {
+		t.Error("the closure was not logged")
+	}
+
+	h.write("v2")
+	w.events <- fileEvent{Name: h.cmd, Op: opWrite}
+	if call := h.exec(); call.err != nil {
+		t.Fatal(call.err)
+	}
+}
+
+// TestWatcherRebuildsExhausted checks that the AutoReloader exits once
+// it fails to replace a closed watcher a bounded number of times.
+func TestWatcherRebuildsExhausted(t *testing.T) {
+	watchers := newFakeWatchers(t)
+	h := newTestReloader(t, watchers.option)
+	h.Start()
+	w := watchers.next(h.cmd)
+
+	watchers.fail(errors.New("too many open files"))
+	close(w.errors)
+	if code := h.exit(); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	if !h.logger.contains("attempt 5 of 5") {
+		t.Error("the watcher was not recreated 5 times")
+	}
+	if !h.logger.contains("Failed to recreate file watcher: too many open files") {
+		t.Error("the failure was not reported")
+	}
+}
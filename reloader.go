@@ -0,0 +1,14 @@
+package autoreload
+
+// Reloader is the interface implemented by AutoReloader. Applications
+// can depend on it rather than on AutoReloader so that tests can supply
+// a fake, such as the one provided by the autoreloadtest package.
+type Reloader interface {
+	Start()
+	Stop()
+	Reload() error
+	Status() Status
+	AddOnReload(fn func()) (remove func())
+}
+
+var _ Reloader = AutoReloader{}
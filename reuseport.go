@@ -0,0 +1,44 @@
+package autoreload
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ErrReusePortUnsupported is returned by ListenReusePort on platforms
+// without SO_REUSEPORT.
+var ErrReusePortUnsupported = errors.New("SO_REUSEPORT is not supported on this platform")
+
+// ListenReusePort announces on the local network address like
+// net.Listen, but with SO_REUSEPORT set on the socket. This allows a new
+// process to bind the same address while the old one is still draining,
+// so there is no window in which connections are refused. It is most
+// useful with WithBlueGreen, where both processes are briefly running.
+// Since either may then answer the health URL, it must be served by
+// GenerationHandler:
+//
+//	ln, err := autoreload.ListenReusePort("tcp", ":8000")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	mux := http.NewServeMux()
+//	mux.Handle("/health", autoreload.GenerationHandler(http.HandlerFunc(health)))
+//	server := &http.Server{Handler: mux}
+//	autoreload.New(
+//		autoreload.WithBlueGreen(autoreload.BlueGreenConfig{HealthURL: "http://localhost:8000/health"}),
+//		autoreload.WithOnReload(func() { server.Shutdown(context.Background()) }),
+//	).Start()
+//	server.Serve(ln)
+//
+// On Linux, the kernel balances new connections between all sockets
+// bound to the address, and both processes must run as the same user.
+// On macOS and the BSDs, new connections usually go to the most recently
+// bound socket. On other platforms, ErrReusePortUnsupported is returned.
+func ListenReusePort(network, addr string) (net.Listener, error) {
+	if !reusePortSupported {
+		return nil, ErrReusePortUnsupported
+	}
+	lc := net.ListenConfig{Control: reusePortControl}
+	return lc.Listen(context.Background(), network, addr)
+}
@@ -0,0 +1,58 @@
+package autoreload
+
+import (
+	"net"
+	"os"
+)
+
+// WithSDNotify defines whether the AutoReloader notifies systemd of
+// reloads using the sd_notify protocol. When enabled, RELOADING=1 is sent
+// before the process is re-executed and STOPPING=1 is sent if the reload
+// fails and the process exits. The re-executed application should call
+// NotifyReady once it is serving again. Notifications are skipped when
+// the NOTIFY_SOCKET environment variable is unset.
+func WithSDNotify(enabled bool) Option {
+	return func(autoReloader *AutoReloader) {
+		autoReloader.sdNotify = enabled
+	}
+}
+
+// NotifyReady reports that the application is ready. It sends READY=1
+// to systemd using the sd_notify protocol when the NOTIFY_SOCKET
+// environment variable is set and, when the process was started as a
+// blue/green candidate, sends the ready signal to the old process. It
+// should be called once the application is serving, both on the initial
+// start and after every reload.
+func NotifyReady() error {
+	if err := notifyParent(); err != nil {
+		return err
+	}
+	return sdNotify("READY=1")
+}
+
+// notify sends the state to systemd if enabled, logging any failure.
+func (ar AutoReloader) notify(state string) {
+	if !ar.sdNotify {
+		return
+	}
+	if err := sdNotify(state); err != nil {
+		ar.log().Error("Failed to notify systemd", err)
+	}
+}
+
+// sdNotify sends the state as a datagram to the socket named by the
+// NOTIFY_SOCKET environment variable. Abstract socket names, which begin
+// with @, are handled by the net package.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,165 @@
+package autoreload
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// now returns the current time, against which uptimes and the time since
+// the last reload are measured.
+var now = time.Now
+
+// Status describes the current state of an AutoReloader.
+type Status struct {
+	// Name identifies the AutoReloader.
+	Name string
+
+	// Running reports whether the AutoReloader has been started and not
+	// since stopped.
+	Running bool
+
+	// Reloading reports whether a reload is in progress.
+	Reloading bool
+
+	// Generation counts the processes in the reload chain, starting at 1
+	// for the process that was not started by a reload.
+	Generation int
+
+	// StartTime is when the current process started.
+	StartTime time.Time
+
+	// LastReloadTime is when the reload that started the current process
+	// was triggered. It is zero in the first generation.
+	LastReloadTime time.Time
+
+	// LastReloadReason is the path whose change triggered the reload
+	// that started the current process.
+	LastReloadReason string
+
+	// LastReloadPaths lists every path that changed while the reload
+	// that started the current process was settling, beginning with
+	// LastReloadReason.
+	LastReloadPaths []string
+
+	// Paths lists the watched paths, beginning with the executable.
+	Paths []WatchedPath
+}
+
+// WatchedPath describes a path watched by the AutoReloader.
+type WatchedPath struct {
+	Path   string `json:"path"`
+	Action Action `json:"action"`
+}
+
+// statusJSON is the JSON form of a Status. Its shape is a stable contract
+// consumed by dashboards, so fields may be added but not changed.
+type statusJSON struct {
+	Name             string        `json:"name"`
+	State            string        `json:"state"`
+	Running          bool          `json:"running"`
+	Reloading        bool          `json:"reloading"`
+	Generation       int           `json:"generation"`
+	StartTime        string        `json:"start_time"`
+	Uptime           string        `json:"uptime"`
+	LastReloadTime   *string       `json:"last_reload_time"`
+	LastReloadReason string        `json:"last_reload_reason,omitempty"`
+	LastReloadPaths  []string      `json:"last_reload_paths,omitempty"`
+	Paths            []WatchedPath `json:"paths"`
+}
+
+// MarshalJSON encodes the status with times in RFC 3339 format and
+// durations as strings.
+func (s Status) MarshalJSON() ([]byte, error) {
+	resp := statusJSON{
+		Name:             s.Name,
+		State:            s.state(),
+		Running:          s.Running,
+		Reloading:        s.Reloading,
+		Generation:       s.Generation,
+		StartTime:        s.StartTime.Format(time.RFC3339),
+		Uptime:           now().Sub(s.StartTime).Round(time.Second).String(),
+		LastReloadReason: s.LastReloadReason,
+		LastReloadPaths:  s.LastReloadPaths,
+		Paths:            s.Paths,
+	}
+	if !s.LastReloadTime.IsZero() {
+		t := s.LastReloadTime.Format(time.RFC3339)
+		resp.LastReloadTime = &t
+	}
+	if resp.Paths == nil {
+		resp.Paths = []WatchedPath{}
+	}
+	return json.Marshal(resp)
+}
+
+// String returns a one line summary of the status.
+func (s Status) String() string {
+	lastReload := "never"
+	if !s.LastReloadTime.IsZero() {
+		lastReload = ago(now().Sub(s.LastReloadTime))
+	}
+	paths := "paths"
+	if len(s.Paths) == 1 {
+		paths = "path"
+	}
+	return fmt.Sprintf("watching %d %s, generation %d, last reload %s, state=%s",
+		len(s.Paths), paths, s.Generation, lastReload, s.state())
+}
+
+// state summarizes Running and Reloading in a single word.
+func (s Status) state() string {
+	switch {
+	case s.Reloading:
+		return "reloading"
+	case s.Running:
+		return "idle"
+	default:
+		return "stopped"
+	}
+}
+
+// ago formats d coarsely, such as "2m ago".
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	}
+}
+
+// Status returns a snapshot of the current state of the AutoReloader.
+func (ar AutoReloader) Status() Status {
+	ar.state.mu.Lock()
+	defer ar.state.mu.Unlock()
+
+	status := Status{
+		Name:             ar.name(),
+		Running:          ar.state.cancel != nil,
+		Reloading:        ar.state.reloading,
+		Generation:       generation,
+		StartTime:        processStart,
+		LastReloadTime:   lastReloadTime,
+		LastReloadReason: lastReloadPath(),
+		LastReloadPaths:  append([]string(nil), lastReloadPaths...),
+	}
+	if path := ar.primaryPath(); path != "" {
+		status.Paths = append(status.Paths, WatchedPath{Path: path, Action: ActionReload})
+	}
+	for _, path := range ar.state.commandPaths {
+		status.Paths = append(status.Paths, WatchedPath{Path: path, Action: ActionReload})
+	}
+	paths := make([]WatchedPath, 0, len(ar.state.paths))
+	for path, action := range ar.state.paths {
+		paths = append(paths, WatchedPath{Path: path, Action: action})
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].Path < paths[j].Path })
+	status.Paths = append(status.Paths, paths...)
+	return status
+}
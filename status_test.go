@@ -0,0 +1,88 @@
+package autoreload
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// golden compares the output with the golden file, which -update
+// rewrites instead.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n got: %s\nwant: %s", path, got, want)
+	}
+}
+
+// TestStatusGolden checks the JSON form and the summary of the status,
+// which dashboards and the CLI consume, against golden files.
+func TestStatusGolden(t *testing.T) {
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	paths := []WatchedPath{
+		{Path: "/srv/app/bin/server", Action: ActionReload},
+		{Path: "/srv/app/templates", Action: ActionLog},
+		{Path: "/srv/app/handlers.so", Action: ActionPlugin},
+	}
+	tests := []struct {
+		name   string
+		status Status
+	}{
+		{"stopped", Status{
+			Name:       "server",
+			Generation: 1,
+			StartTime:  clock.Add(-90 * time.Second),
+		}},
+		{"idle", Status{
+			Name:             "server",
+			Running:          true,
+			Generation:       4,
+			StartTime:        clock.Add(-2*time.Minute - 30*time.Second),
+			LastReloadTime:   clock.Add(-2*time.Minute - 31*time.Second),
+			LastReloadReason: "/srv/app/bin/server",
+			LastReloadPaths:  []string{"/srv/app/bin/server", "/srv/app/templates/index.html"},
+			Paths:            paths,
+		}},
+		{"reloading", Status{
+			Name:             "server",
+			Running:          true,
+			Reloading:        true,
+			Generation:       12,
+			StartTime:        clock.Add(-26 * time.Hour),
+			LastReloadTime:   clock.Add(-26 * time.Hour),
+			LastReloadReason: "/srv/app/bin/server",
+			LastReloadPaths:  []string{"/srv/app/bin/server"},
+			Paths:            paths[:1],
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.MarshalIndent(tt.status, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden(t, "status/"+tt.name+".json", append(data, '\n'))
+			golden(t, "status/"+tt.name+".txt", []byte(tt.status.String()+"\n"))
+		})
+	}
+}
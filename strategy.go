@@ -0,0 +1,133 @@
+package autoreload
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExecStrategy determines which file is executed when the application
+// is reloaded.
+type ExecStrategy interface {
+	// Prepare returns the path of the file to execute in place of the
+	// executable at path. It is called once the executable has stopped
+	// changing. An error causes the exec attempt to be retried.
+	Prepare(path string) (string, error)
+
+	// Cleanup removes anything left behind by previous generations. It
+	// is called when the AutoReloader starts.
+	Cleanup(path string) error
+}
+
+// WithExecStrategy defines how the AutoReloader executes the changed
+// executable. By default, the executable is executed in place.
+func WithExecStrategy(strategy ExecStrategy) Option {
+	if strategy == nil {
+		strategy = DirectExec()
+	}
+	return func(autoReloader *AutoReloader) {
+		autoReloader.execStrategy = strategy
+	}
+}
+
+type directExec struct{}
+
+// DirectExec returns an ExecStrategy that executes the executable in
+// place. This is the default.
+func DirectExec() ExecStrategy {
+	return directExec{}
+}
+
+func (directExec) Prepare(path string) (string, error) { return path, nil }
+func (directExec) Cleanup(path string) error           { return nil }
+
+type copyExec struct {
+	dir string
+}
+
+// CopyExec returns an ExecStrategy that copies the executable into dir
+// and executes the copy. Since the copy is never written to by the build
+// tool, this avoids "text file busy" errors and executing a binary that is
+// rewritten mid-exec, at the cost of a copy. Copies left by previous
+// generations are removed when the AutoReloader starts. If dir is empty,
+// the default temporary directory is used.
+func CopyExec(dir string) ExecStrategy {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return copyExec{dir: dir}
+}
+
+func (c copyExec) Prepare(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	dst, err := os.CreateTemp(c.dir, c.prefix(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if err := dst.Chmod(info.Mode().Perm()); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+// Cleanup removes the copies of the executable at path, other than the
+// one that the current process is running. The copies of an executable
+// whose name merely starts with the same name, such as app-server for
+// app, are kept.
+func (c copyExec) Cleanup(path string) error {
+	current, _ := os.Executable()
+	prefix := c.prefix(path)
+	matches, err := filepath.Glob(filepath.Join(c.dir, prefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if match == current || !isTempSuffix(strings.TrimPrefix(filepath.Base(match), prefix)) {
+			continue
+		}
+		if err := os.Remove(match); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c copyExec) prefix(path string) string {
+	return "autoreload-" + strings.ReplaceAll(filepath.Base(path), "*", "") + "-"
+}
+
+// isTempSuffix reports whether s is the random suffix that
+// os.CreateTemp appends to the prefix, which consists of digits.
+func isTempSuffix(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package autoreload
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestCopyExecPrepare checks that each prepared copy is a new file in
+// the directory with the content and permissions of the executable.
+func TestCopyExecPrepare(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "app")
+	content := []byte("#!/bin/sh\necho app\n")
+	if err := ioutil.WriteFile(src, content, 0750); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	strategy := CopyExec(dir)
+
+	first, err := strategy.Prepare(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := strategy.Prepare(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("both copies are %s", first)
+	}
+	for _, copied := range []string{first, second} {
+		if filepath.Dir(copied) != dir || !strings.HasPrefix(filepath.Base(copied), "autoreload-app-") {
+			t.Errorf("copy %s is not an autoreload-app- file in %s", copied, dir)
+		}
+		got, err := ioutil.ReadFile(copied)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("copy %s has content %q, want %q", copied, got, content)
+		}
+		info, err := os.Stat(copied)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != 0750 {
+			t.Errorf("copy %s has mode %v, want %v", copied, info.Mode().Perm(), os.FileMode(0750))
+		}
+	}
+}
+
+// TestCopyExecPrepareMissing checks that a missing executable fails the
+// attempt without leaving a copy behind.
+func TestCopyExecPrepareMissing(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := CopyExec(dir).Prepare(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Prepare of a missing executable = %v, want not exist", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d files left in %s", len(files), dir)
+	}
+}
+
+// TestCopyExecCleanup checks that the stale copies of the executable are
+// removed, while the copies of other executables and unrelated files
+// are kept.
+func TestCopyExecCleanup(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]bool{
+		"autoreload-app-123":       false,
+		"autoreload-app-456":       false,
+		"autoreload-app-server-78": true,
+		"autoreload-other-9":       true,
+		"app":                      true,
+	}
+	for name := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CopyExec(dir).Cleanup("/bin/app"); err != nil {
+		t.Fatal(err)
+	}
+	for name, kept := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s exists = %v, want %v", name, exists, kept)
+		}
+	}
+}
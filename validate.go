@@ -0,0 +1,92 @@
+package autoreload
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// ConfigError is returned by NewE when the supplied options are invalid.
+// It lists every problem found rather than just the first.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid autoreload configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// NewE creates a new AutoReloader with the supplied options, as New
+// does, returning a *ConfigError if the resulting configuration is
+// invalid. The following rules are enforced:
+//
+//   - WithBlueGreen cannot be combined with WithExitInsteadOfExec, since
+//     one starts the new process itself and the other leaves that to a
+//     supervisor.
+//   - WithBlueGreen cannot be combined with WithSDNotify, since the
+//     process that systemd tracks exits once the new process is ready.
+//   - WithBlueGreen requires the ready signal, if any, to be a
+//     syscall.Signal.
+//   - WithPollInterval cannot be combined with WithWatcherPool, since a
+//     polling AutoReloader does not use file system notifications.
+//   - WithProcSelfExeFallback is only supported on Linux.
+//   - WithReplacementTimeout cannot be negative.
+//   - On Windows, which cannot replace a running process, either
+//     WithExitInsteadOfExec or WithBlueGreen is required.
+//   - WithInheritFDs requires non-negative descriptors and is not
+//     supported on Windows.
+//
+// Other options are corrected silently where doing so is harmless, as
+// documented on each option: for example, a nil logger disables logging
+// and a maxAttempts of less than 1 is treated as 1.
+func NewE(opts ...Option) (AutoReloader, error) {
+	autoReloader := newAutoReloader(opts)
+	if err := autoReloader.validate(); err != nil {
+		return AutoReloader{}, err
+	}
+	autoReloader.setup()
+	return *autoReloader, nil
+}
+
+// validate checks the configuration for options that conflict or are
+// not supported.
+func (ar AutoReloader) validate() error {
+	var problems []string
+	if ar.blueGreen != nil {
+		if ar.exitCode != nil {
+			problems = append(problems, "blue/green reloads cannot be combined with exiting instead of exec'ing")
+		}
+		if ar.sdNotify {
+			problems = append(problems, "blue/green reloads cannot be combined with sd_notify")
+		}
+		if sig := ar.blueGreen.ReadySignal; sig != nil {
+			if _, ok := signalNumber(sig); !ok {
+				problems = append(problems, fmt.Sprintf("unsupported blue/green ready signal: %v", sig))
+			}
+		}
+	}
+	if ar.pollInterval > 0 && ar.pool != nil {
+		problems = append(problems, "polling cannot be combined with a watcher pool")
+	}
+	if ar.procSelfExeFallback && runtime.GOOS != "linux" {
+		problems = append(problems, fmt.Sprintf("the /proc/self/exe fallback is not supported on %s", runtime.GOOS))
+	}
+	for _, fd := range ar.inheritFDs {
+		if fd < 0 {
+			problems = append(problems, fmt.Sprintf("invalid file descriptor to inherit: %d", fd))
+		}
+	}
+	if len(ar.inheritFDs) > 0 && runtime.GOOS == "windows" {
+		problems = append(problems, "inheriting file descriptors is not supported on windows")
+	}
+	if platformSupported && !ar.canReload() {
+		problems = append(problems, fmt.Sprintf("re-executing is not supported on %s; use WithExitInsteadOfExec or WithBlueGreen", runtime.GOOS))
+	}
+	if ar.replacementTimeout < 0 {
+		problems = append(problems, fmt.Sprintf("replacement timeout cannot be negative: %s", ar.replacementTimeout))
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris
+
+package autoreload
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// otherSignal is an os.Signal that is not a syscall.Signal.
+type otherSignal struct{}
+
+func (otherSignal) String() string { return "other" }
+func (otherSignal) Signal()        {}
+
+func TestNewE(t *testing.T) {
+	var procSelfExeProblems []string
+	if runtime.GOOS != "linux" {
+		procSelfExeProblems = []string{"/proc/self/exe fallback is not supported"}
+	}
+	tests := []struct {
+		name     string
+		opts     []Option
+		problems []string
+	}{
+		{"defaults", nil, nil},
+		{"exit instead of exec", []Option{WithExitInsteadOfExec(3)}, nil},
+		{"blue/green", []Option{WithBlueGreen(BlueGreenConfig{ReadySignal: syscall.SIGUSR1})}, nil},
+		{
+			"blue/green and exit",
+			[]Option{WithBlueGreen(BlueGreenConfig{}), WithExitInsteadOfExec(3)},
+			[]string{"blue/green reloads cannot be combined with exiting"},
+		},
+		{
+			"blue/green and sd_notify",
+			[]Option{WithBlueGreen(BlueGreenConfig{}), WithSDNotify(true)},
+			[]string{"blue/green reloads cannot be combined with sd_notify"},
+		},
+		{
+			"blue/green ready signal",
+			[]Option{WithBlueGreen(BlueGreenConfig{ReadySignal: otherSignal{}})},
+			[]string{"unsupported blue/green ready signal: other"},
+		},
+		{"polling", []Option{WithPollInterval(time.Second)}, nil},
+		{
+			"polling and pool",
+			[]Option{WithPollInterval(time.Second), WithWatcherPool(NewWatcherPool())},
+			[]string{"polling cannot be combined with a watcher pool"},
+		},
+		{"/proc/self/exe fallback", []Option{WithProcSelfExeFallback(true)}, procSelfExeProblems},
+		{"inherited descriptors", []Option{WithInheritFDs(3, 4)}, nil},
+		{
+			"negative descriptors",
+			[]Option{WithInheritFDs(3, -1, -2)},
+			[]string{"invalid file descriptor to inherit: -1", "invalid file descriptor to inherit: -2"},
+		},
+		{"replacement timeout", []Option{WithReplacementTimeout(0)}, nil},
+		{
+			"negative replacement timeout",
+			[]Option{WithReplacementTimeout(-time.Second)},
+			[]string{"replacement timeout cannot be negative: -1s"},
+		},
+		{
+			"every problem",
+			[]Option{
+				WithBlueGreen(BlueGreenConfig{}), WithExitInsteadOfExec(3), WithSDNotify(true),
+				WithPollInterval(time.Second), WithWatcherPool(NewWatcherPool()),
+				WithReplacementTimeout(-time.Second),
+			},
+			[]string{
+				"blue/green reloads cannot be combined with exiting",
+				"blue/green reloads cannot be combined with sd_notify",
+				"polling cannot be combined with a watcher pool",
+				"replacement timeout cannot be negative",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar, err := NewE(append([]Option{WithLogger(nil)}, tt.opts...)...)
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("NewE = %v, want nil", err)
+				}
+				if ar.state == nil {
+					t.Error("NewE returned an unusable AutoReloader")
+				}
+				return
+			}
+
+			var cerr *ConfigError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("NewE = %v, want a *ConfigError", err)
+			}
+			if len(cerr.Problems) != len(tt.problems) {
+				t.Fatalf("problems = %q, want %q", cerr.Problems, tt.problems)
+			}
+			for i, problem := range tt.problems {
+				if !strings.Contains(cerr.Problems[i], problem) {
+					t.Errorf("problem %d = %q, want %q", i, cerr.Problems[i], problem)
+				}
+			}
+		})
+	}
+}
+
+// TestNewESameAsNew checks that NewE configures the AutoReloader as New
+// does.
+func TestNewESameAsNew(t *testing.T) {
+	opts := []Option{WithName("app"), WithCommand(os.Args[0]), WithMaxAttempts(3), WithLogger(nil)}
+	ar, err := NewE(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := New(opts...)
+	if ar.name() != want.name() || ar.command() != want.command() || ar.maxAttempts != want.maxAttempts {
+		t.Errorf("NewE = %s %s %d, want %s %s %d",
+			ar.name(), ar.command(), ar.maxAttempts, want.name(), want.command(), want.maxAttempts)
+	}
+	var _ Reloader = ar
+}
@@ -0,0 +1,128 @@
+package autoreload
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+// ErrInvalidExecutable is returned when the changed executable is not a
+// file that can be executed, such as a partially written binary.
+var ErrInvalidExecutable = errors.New("invalid executable")
+
+// verifyExecutable checks that the file at path looks like a complete
+// executable: it must be nonempty, have an executable bit set and begin
+// with either a shebang or the binary format magic of the platform.
+func verifyExecutable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir():
+		return fmt.Errorf("%w: %s is a directory", ErrInvalidExecutable, path)
+	case info.Size() == 0:
+		return fmt.Errorf("%w: %s is empty", ErrInvalidExecutable, path)
+	case runtime.GOOS != "windows" && info.Mode()&0111 == 0:
+		return fmt.Errorf("%w: %s is not executable (mode %s)", ErrInvalidExecutable, path, info.Mode())
+	}
+
+	header, err := readHeader(f)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(header, []byte("#!")) || hasExecutableMagic(header) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is not a %s executable", ErrInvalidExecutable, path, runtime.GOOS)
+}
+
+// verifyBinary checks that the file at path begins with the binary format
+// magic of the platform, as a shared library does. Unlike
+// verifyExecutable, it does not require an executable bit.
+func verifyBinary(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header, err := readHeader(f)
+	if err != nil {
+		return err
+	}
+	if !hasExecutableMagic(header) {
+		return fmt.Errorf("%w: %s is not a %s binary", ErrInvalidExecutable, path, runtime.GOOS)
+	}
+	return nil
+}
+
+// readHeader reads up to the first four bytes of the file.
+func readHeader(f *os.File) ([]byte, error) {
+	header := make([]byte, 4)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// hasExecutableMagic reports whether the header begins with the magic
+// number of the native binary format of the platform.
+func hasExecutableMagic(header []byte) bool {
+	return hasMagic(header, runtime.GOOS)
+}
+
+// hasMagic reports whether the header begins with the magic number of
+// the native binary format of goos.
+func hasMagic(header []byte, goos string) bool {
+	var magics [][]byte
+	switch goos {
+	case "darwin", "ios":
+		magics = [][]byte{
+			{0xfe, 0xed, 0xfa, 0xce}, {0xce, 0xfa, 0xed, 0xfe},
+			{0xfe, 0xed, 0xfa, 0xcf}, {0xcf, 0xfa, 0xed, 0xfe},
+			{0xca, 0xfe, 0xba, 0xbe}, {0xbe, 0xba, 0xfe, 0xca},
+		}
+	case "windows":
+		magics = [][]byte{[]byte("MZ")}
+	default:
+		magics = [][]byte{[]byte("\x7fELF")}
+	}
+	for _, magic := range magics {
+		if bytes.HasPrefix(header, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForValidExecutable rechecks the executable at path until it is
+// valid or the replacement timeout expires, swallowing events in the
+// interim. This covers build tools that write the file and only later
+// set its executable bit. A missing executable is not treated as
+// invalid, since it is handled by waiting for its replacement.
+func (ar AutoReloader) waitForValidExecutable(path string, events <-chan fileEvent) error {
+	deadline := time.Now().Add(ar.replacementTimeout)
+	for {
+		err := verifyExecutable(path)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return err
+		}
+		ar.debug(fmt.Sprintf("Waiting for valid executable: %v", err))
+		ar.sleep(100*time.Millisecond, events)
+	}
+}
@@ -0,0 +1,136 @@
+package autoreload
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// nativeMagic is the magic number of the binary format of the platform.
+func nativeMagic() string {
+	switch runtime.GOOS {
+	case "darwin", "ios":
+		return "\xcf\xfa\xed\xfe"
+	case "windows":
+		return "MZ"
+	default:
+		return "\x7fELF"
+	}
+}
+
+func TestHasMagic(t *testing.T) {
+	tests := []struct {
+		goos   string
+		header string
+		want   bool
+	}{
+		{"linux", "\x7fELF", true},
+		{"linux", "\x7fEL", false},
+		{"linux", "\x7f", false},
+		{"linux", "", false},
+		{"linux", "ELF\x7f", false},
+		{"linux", "MZ\x90\x00", false},
+		{"linux", "\xcf\xfa\xed\xfe", false},
+		{"freebsd", "\x7fELF", true},
+		{"darwin", "\xfe\xed\xfa\xce", true},
+		{"darwin", "\xce\xfa\xed\xfe", true},
+		{"darwin", "\xfe\xed\xfa\xcf", true},
+		{"darwin", "\xcf\xfa\xed\xfe", true},
+		{"darwin", "\xca\xfe\xba\xbe", true},
+		{"darwin", "\xbe\xba\xfe\xca", true},
+		{"darwin", "\xcf\xfa\xed", false},
+		{"darwin", "\xca\xfe", false},
+		{"darwin", "\x7fELF", false},
+		{"darwin", "\x00\x00\x00\x00", false},
+		{"windows", "MZ\x90\x00", true},
+		{"windows", "MZ", true},
+		{"windows", "M", false},
+		{"windows", "ZM\x90\x00", false},
+		{"windows", "\x7fELF", false},
+	}
+	for _, tt := range tests {
+		if got := hasMagic([]byte(tt.header), tt.goos); got != tt.want {
+			t.Errorf("hasMagic(%q, %s) = %v, want %v", tt.header, tt.goos, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyExecutable(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+		valid   bool
+	}{
+		{"complete binary", nativeMagic() + "\x02\x01\x01", 0755, true},
+		{"header only", nativeMagic(), 0755, true},
+		{"script", "#!/bin/sh\necho hi\n", 0755, true},
+		{"empty", "", 0755, false},
+		{"truncated magic", nativeMagic()[:1], 0755, false},
+		{"garbage", "\x00\x01\x02\x03garbage", 0755, false},
+		{"text", "package main\n", 0755, false},
+		{"not executable", nativeMagic() + "\x02\x01\x01", 0644, runtime.GOOS == "windows"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(path, []byte(tt.content), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			err := verifyExecutable(path)
+			if tt.valid && err != nil {
+				t.Errorf("verifyExecutable = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidExecutable) {
+				t.Errorf("verifyExecutable = %v, want ErrInvalidExecutable", err)
+			}
+		})
+	}
+}
+
+func TestVerifyExecutableDirectory(t *testing.T) {
+	if err := verifyExecutable(t.TempDir()); !errors.Is(err, ErrInvalidExecutable) {
+		t.Errorf("verifyExecutable of a directory = %v, want ErrInvalidExecutable", err)
+	}
+}
+
+func TestVerifyExecutableMissing(t *testing.T) {
+	if err := verifyExecutable(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("verifyExecutable of a missing file = %v, want not exist", err)
+	}
+}
+
+func TestVerifyBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"library", nativeMagic() + "\x02\x01\x01", true},
+		{"truncated magic", nativeMagic()[:1], false},
+		{"empty", "", false},
+		{"script", "#!/bin/sh\n", false},
+		{"garbage", "\xff\xfe\xfd\xfc", false},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Shared libraries need not be executable.
+			path := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := verifyBinary(path)
+			if tt.valid && err != nil {
+				t.Errorf("verifyBinary = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidExecutable) {
+				t.Errorf("verifyBinary = %v, want ErrInvalidExecutable", err)
+			}
+		})
+	}
+}
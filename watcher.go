@@ -0,0 +1,12 @@
+package autoreload
+
+// watcher is the subset of fsnotify functionality used by the
+// AutoReloader. It is implemented by subscriptions to a WatcherPool and
+// allows the failure modes of the watcher to be simulated.
+type watcher interface {
+	Add(path string) error
+	Remove(path string) error
+	Close() error
+	Events() <-chan fileEvent
+	Errors() <-chan error
+}